@@ -9,6 +9,7 @@ const (
 	Ctx_Claims      = "claims"
 	Ctx_Token       = "token"
 	Ctx_Panic       = "panic"
+	Ctx_Permission  = "permission"
 )
 
 // var (
@@ -96,12 +96,14 @@ func RedirectAuthorizeEndpoint(ctx IHttpContext, oauthOptions *OAuthOptions, ret
 // 	return _idGenerator.GenerateString()
 // }
 
+// HandleErr responds 500 with an id of the logged error and a message translated by ctx.T, returns false if err is nil
 func HandleErr(err error, ctx IHttpContext) bool {
 	if err != nil {
 		ctx.SetStatusCode(http.StatusInternalServerError)
 		errID := xutils.GenerateStringID()
 		xlog.Errorf("[%s] %+v", errID, err)
-		ctx.WriteJsonBytes(xbytes.StrToBytes(`{"err":"` + errID + `"}`))
+		data, _ := json.Marshal(map[string]string{"err": errID, "msg": ctx.T("internal server error")})
+		ctx.WriteJsonBytes(data)
 
 		return true
 	}
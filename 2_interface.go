@@ -1,9 +1,11 @@
 package host
 
 import (
+	"context"
 	"embed"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 
 	"github.com/DreamvatLab/go/xconfig"
@@ -23,6 +25,15 @@ type (
 
 	IHost interface {
 		Run() error
+		// Shutdown stops the host gracefully, runs stopping/stopped hooks and cleanups, then Run returns
+		Shutdown(ctx context.Context) error
+		GetLifecycle() *Lifecycle
+	}
+
+	// IConfigWatcher is implemented by config providers which reload at runtime
+	IConfigWatcher interface {
+		// OnChange registers a callback fired after the value of key changes, empty key means any change
+		OnChange(key string, callback func(cp xconfig.IConfigProvider))
 	}
 
 	IBaseHost interface {
@@ -37,6 +48,7 @@ type (
 
 	IWebHost interface {
 		IHost
+		Serve(ln net.Listener) error
 		GET(path string, handlers ...RequestHandler)
 		POST(path string, handlers ...RequestHandler)
 		PUT(path string, handlers ...RequestHandler)
@@ -48,9 +60,12 @@ type (
 		AddGlobalPreHandlers(toTail bool, handlers ...RequestHandler)
 		AppendGlobalSufHandlers(toTail bool, handlers ...RequestHandler)
 		AddActionGroups(actionGroups ...*ActionGroup)
+		AddActionGroupsE(actionGroups ...*ActionGroup) error
 		AddActions(actions ...*Action)
+		AddActionsE(actions ...*Action) error
 		AddAction(route, routeKey string, handlers ...RequestHandler)
 		RegisterActionsToRouter(action *Action)
+		GetRoutes() []*RouteInfo
 		NewFSHandler(root string, stripSlashes int) RequestHandler
 	}
 
@@ -72,8 +87,16 @@ type (
 		RemoveCookie(key string, options ...func(*http.Cookie))
 
 		SetSession(key, value string)
+		SetSessionValue(key string, value interface{})
+		SetSessionStruct(key string, obj interface{}) error
+		GetSessionValue(key string) interface{}
 		GetSessionString(key string) string
+		GetSessionInt(key string) int
+		GetSessionInt64(key string) int64
+		GetSessionBool(key string) bool
+		GetSessionStruct(key string, objPtr interface{}) error
 		RemoveSession(key string)
+		RegenerateSession() error
 		EndSession()
 
 		GetFormString(key string) string
@@ -83,6 +106,7 @@ type (
 
 		GetBodyString() string
 		GetBodyBytes() []byte
+		GetBodyStream() io.Reader
 
 		GetParamString(key string) string
 		GetParamInt(key string) int
@@ -98,12 +122,20 @@ type (
 		SetHeader(key, value string)
 
 		SetStatusCode(statusCode int)
+		GetStatusCode() int
+		GetResponseHeader(key string) string
+		GetResponseBody() []byte
 		SetContentType(cType string)
 		WriteString(body string) (int, error)
 		WriteBytes(body []byte) (int, error)
 		WriteJsonBytes(body []byte) (int, error)
+		Render(name string, data interface{}) error
+		T(key string, args ...interface{}) string
 
+		RequestMethod() string
 		RequestURL() string
+		RequestScheme() string
+		RequestHost() string
 		RequestPath() string
 		GetRemoteIP() string
 		GetRealIP() string
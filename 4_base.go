@@ -1,6 +1,10 @@
 package host
 
 import (
+	"context"
+	"sort"
+	"strings"
+
 	"github.com/DreamvatLab/go/xbytes"
 	"github.com/DreamvatLab/go/xconfig"
 	"github.com/DreamvatLab/go/xerr"
@@ -17,53 +21,155 @@ type BaseHost struct {
 	URIKey             string
 	RouteKey           string
 	PermissionKey      string
+	RouteFile          string              // Json or yaml file of routes used instead of the redis hash of RouteKey, reloaded on changes
+	PermissionFile     string              // Json or yaml file of permissions used instead of the redis hash of PermissionKey, reloaded on changes
 	RedisConfig        *xredis.RedisConfig `json:"Redis,omitempty"`
 	ConfigProvider     xconfig.IConfigProvider
 	URLProvider        hurl.IURLProvider
 	PermissionProvider xsecurity.IPermissionProvider
 	RouteProvider      xsecurity.IRouteProvider
 	PermissionAuditor  xsecurity.IPermissionAuditor
+	PermissionReload   *PermissionReloadOptions // Reload route/permission data on redis notifications of 'ConnectionStrings.Redis', disabled if nil
+	LogSinks           []xlog.LogSink           // Passed to xlog.Init, kept when the log level changes at runtime
+	Lifecycle
 }
 
 func (x *BaseHost) BuildBaseHost() {
+	xerr.FatalIfErr(x.BuildBaseHostE())
+}
+
+// BuildBaseHostE builds the host, errors of all bad config keys are returned as ConfigErrors
+func (x *BaseHost) BuildBaseHostE() error {
+	var errs ConfigErrors
+
+	// Registered first to run last, so other cleanups can still log
+	x.AddCleanup("log", func(ctx context.Context) error {
+		xlog.Finalize()
+		return nil
+	})
+	for _, sink := range x.LogSinks {
+		x.AddCloser("log sink", sink) // Sinks holding connections are closed after other cleanups
+	}
+
 	if x.ConfigProvider == nil {
-		x.ConfigProvider = xconfig.NewJsonConfigProvider()
+		var err error
+		x.ConfigProvider, err = NewLayeredConfigProvider(nil)
+		if err != nil {
+			errs.Append("", err)
+			return errs.Err()
+		}
+	}
+	for _, key := range FindUnresolvedSecrets(x.ConfigProvider) {
+		errs.Add(key, "secret reference is not resolved, wrap the config provider by NewSecretConfigProvider")
 	}
 
 	redisConnStr := x.ConfigProvider.GetString("ConnectionStrings.Redis")
 	if redisConnStr != "" {
 		var err error
 		x.RedisConfig, err = xredis.ParseRedisConfig(redisConnStr)
-		xerr.FatalIfErr(err)
+		errs.Append("ConnectionStrings.Redis", err)
 	}
 
 	if x.URLProvider == nil && x.URIKey != "" && x.RedisConfig != nil {
 		var err error
 		x.URLProvider, err = hurl.NewRedisURLProvider(x.URIKey, x.RedisConfig)
 		if err != nil {
-			xlog.Fatal("failed to create redis url provider: " + err.Error())
+			errs.Add("URIKey", "failed to create redis url provider: "+err.Error())
+		} else {
+			x.AddCloser("URLProvider", x.URLProvider)
+		}
+	}
+
+	if x.PermissionProvider == nil && x.PermissionFile != "" {
+		provider, err := NewFilePermissionProvider(x.PermissionFile, 0)
+		if err != nil {
+			errs.Append("PermissionFile", err)
+		} else {
+			x.PermissionProvider = provider
+			x.AddCloser("PermissionProvider", provider)
+		}
+	}
+
+	if x.RouteProvider == nil && x.RouteFile != "" {
+		provider, err := NewFileRouteProvider(x.RouteFile, 0)
+		if err != nil {
+			errs.Append("RouteFile", err)
+		} else {
+			x.RouteProvider = provider
+			x.AddCloser("RouteProvider", provider)
 		}
 	}
 
 	if x.PermissionProvider == nil && x.PermissionKey != "" && x.RedisConfig != nil {
-		x.PermissionProvider = xsecurity.NewRedisPermissionProvider(x.PermissionKey, x.RedisConfig)
+		provider, err := NewRedisPermissionProvider(x.PermissionKey, x.RedisConfig)
+		if err != nil {
+			errs.Append("PermissionKey", err)
+		} else {
+			x.PermissionProvider = provider
+			x.AddCloser("PermissionProvider", provider)
+		}
 	}
 
 	if x.RouteProvider == nil && x.RouteKey != "" && x.RedisConfig != nil {
-		x.RouteProvider = xsecurity.NewRedisRouteProvider(x.RouteKey, x.RedisConfig)
+		provider, err := NewRedisRouteProvider(x.RouteKey, x.RedisConfig)
+		if err != nil {
+			errs.Append("RouteKey", err)
+		} else {
+			x.RouteProvider = provider
+			x.AddCloser("RouteProvider", provider)
+		}
+	}
+
+	if x.PermissionReload != nil && x.RedisConfig == nil {
+		errs.Add("PermissionReload", "reloading is notified by redis, 'ConnectionStrings.Redis' is required")
 	}
 
 	if x.PermissionAuditor == nil && x.PermissionProvider != nil { // RouteProvider can be empty
-		x.PermissionAuditor = xsecurity.NewPermissionAuditor(x.PermissionProvider, x.RouteProvider)
+		// Reloadable auditor loads data with errors returned, xsecurity's auditor exits the process on failures
+		auditor, err := NewReloadablePermissionAuditor(x.PermissionProvider, x.RouteProvider)
+		if err != nil {
+			errs.Append("PermissionKey", err)
+		} else {
+			if x.PermissionReload != nil && x.RedisConfig != nil {
+				err = auditor.WatchRedis(x.RedisConfig, x.RouteKey, x.PermissionKey, x.PermissionReload)
+				errs.Append("PermissionReload", err)
+			}
+			for _, provider := range []interface{}{x.PermissionProvider, x.RouteProvider} {
+				if watcher, ok := provider.(interface{ OnFileChange(func()) }); ok {
+					watcher.OnFileChange(auditor.reloadAndLog)
+				}
+			}
+			x.PermissionAuditor = auditor
+			x.AddCloser("PermissionAuditor", auditor)
+		}
 	}
 
 	var logConfig *xlog.LogConfig
 	err := x.ConfigProvider.GetStruct("Log", &logConfig)
-	xerr.FatalIfErr(err)
-
-	xlog.Init(logConfig)
+	if err != nil {
+		errs.Append("Log", err)
+	} else {
+		initLog(logConfig, x.LogSinks...)
+	}
+	if watcher, ok := x.ConfigProvider.(IConfigWatcher); ok {
+		watcher.OnChange("Log", func(cp xconfig.IConfigProvider) {
+			var logConfig *xlog.LogConfig
+			if err := cp.GetStruct("Log", &logConfig); err != nil {
+				xlog.Error(err)
+				return
+			}
+			initLog(logConfig, x.LogSinks...)
+		})
+	}
 
 	ConfigHttpClient(x.ConfigProvider)
+
+	return errs.Err()
+}
+
+// GetLifecycle returns lifecycle of the host to register hooks and cleanups
+func (x *BaseHost) GetLifecycle() *Lifecycle {
+	return &x.Lifecycle
 }
 
 func (x BaseHost) GetDebug() bool {
@@ -82,6 +188,14 @@ func (x BaseHost) GetURLProvider() hurl.IURLProvider {
 func (x BaseHost) GetPermissionAuditor() xsecurity.IPermissionAuditor {
 	return x.PermissionAuditor
 }
+
+// GetPermissionReloadMetrics returns reload counters of PermissionAuditor, false if it's not reloadable
+func (x BaseHost) GetPermissionReloadMetrics() (PermissionReloadMetrics, bool) {
+	if auditor, ok := x.PermissionAuditor.(*ReloadablePermissionAuditor); ok {
+		return auditor.Metrics(), true
+	}
+	return PermissionReloadMetrics{}, false
+}
 func (x BaseHost) GetPermissionProvider() xsecurity.IPermissionProvider {
 	return x.PermissionProvider
 }
@@ -92,19 +206,44 @@ func (x BaseHost) GetRouteProvider() xsecurity.IRouteProvider {
 type BaseWebHost struct {
 	// BaseHost
 	ListenAddr        string
+	Listeners         []*ListenerOptions // Additional listeners, e.g. unix sockets or systemd sockets
+	AdminListener     *ListenerOptions   // Separate listener for admin endpoints (health, metrics, pprof ...), admin endpoints go to the main listeners if nil
 	CORS              *CORSOptions
+	IPFilter          *IPFilterOptions
+	ResponseCache     *ResponseCacheOptions
+	Idempotency       *IdempotencyOptions
+	ETag              *ETagOptions
+	Admin             *AdminOptions          // Admin endpoints (pprof, runtime stats, log level ...), disabled if nil
+	PermissionSync    *PermissionSyncOptions // Publish declared action permissions at startup, disabled if nil
 	CookieProtector   *securecookie.SecureCookie
 	GlobalPreHandlers []RequestHandler
 	GlobalSufHandlers []RequestHandler
 	Actions           map[string]*Action
+	routes            []*RouteInfo
+	routeErrs         ConfigErrors // Bad routes added by methods without error results, reported by RouteErr
 }
 
 func (x *BaseWebHost) BuildBaseWebHost() {
-	if x.ListenAddr == "" {
-		xlog.Fatal("ListenAddr cannot be empty")
+	xerr.FatalIfErr(x.BuildBaseWebHostE())
+}
+
+func (x *BaseWebHost) BuildBaseWebHostE() error {
+	var errs ConfigErrors
+	if x.ListenAddr == "" && len(x.Listeners) == 0 {
+		errs.Add("ListenAddr", "ListenAddr and Listeners cannot be both empty")
 	}
 
 	x.Actions = make(map[string]*Action)
+	return errs.Err()
+}
+
+// GetListenerOptions returns ListenAddr as a tcp listener followed by Listeners
+func (x *BaseWebHost) GetListenerOptions() []*ListenerOptions {
+	r := make([]*ListenerOptions, 0, len(x.Listeners)+1)
+	if x.ListenAddr != "" {
+		r = append(r, &ListenerOptions{Network: Network_TCP, Addr: x.ListenAddr})
+	}
+	return append(r, x.Listeners...)
 }
 
 // AddGlobalPreHandlers adds global pre-middleware, toTail: whether to append to the end of existing global pre-middleware
@@ -125,47 +264,120 @@ func (x *BaseWebHost) AppendGlobalSufHandlers(toTail bool, handlers ...RequestHa
 	}
 }
 
+// AddActionGroups adds actions of groups, bad actions are skipped and reported by RouteErr
 func (x *BaseWebHost) AddActionGroups(actionGroups ...*ActionGroup) {
+	x.routeErrs.Append("", x.AddActionGroupsE(actionGroups...))
+}
+
+// AddActionGroupsE adds actions of groups, bad actions are skipped and returned as ConfigErrors keyed by their routes
+func (x *BaseWebHost) AddActionGroupsE(actionGroups ...*ActionGroup) error {
+	var errs ConfigErrors
 	////////// Add Actions
 	for _, actionGroup := range actionGroups {
 		for _, action := range actionGroup.Actions {
-			// Add pre-execution middleware
-			if len(actionGroup.PreHandlers) > 0 {
-				action.Handlers = append(actionGroup.PreHandlers, action.Handlers...)
+			if len(action.Handlers) == 0 {
+				errs.Add(action.Route, "handlers are missing")
+				continue
 			}
-			// Add post-execution middleware
-			if len(actionGroup.AfterHandlers) > 0 {
-				action.Handlers = append(action.Handlers, actionGroup.AfterHandlers...)
+			// Add pre-execution and post-execution middleware, into a new slice as actions of the group must not share it
+			if len(actionGroup.PreHandlers) > 0 || len(actionGroup.AfterHandlers) > 0 {
+				handlers := make([]RequestHandler, 0, len(actionGroup.PreHandlers)+len(action.Handlers)+len(actionGroup.AfterHandlers))
+				handlers = append(handlers, actionGroup.PreHandlers...)
+				handlers = append(handlers, action.Handlers...)
+				action.Handlers = append(handlers, actionGroup.AfterHandlers...)
 			}
-
-			_, ok := x.Actions[action.Route]
-			if ok {
-				xlog.Fatal("duplicated route found: " + action.Route)
+			if action.Permission == nil {
+				action.Permission = actionGroup.Permission
 			}
-			x.Actions[action.Route] = action
+
+			errs.Append(action.Route, x.addAction(action))
 		}
 	}
+	return errs.Err()
 }
 
+// AddActions adds actions, bad actions are skipped and reported by RouteErr
 func (x *BaseWebHost) AddActions(actions ...*Action) {
+	x.routeErrs.Append("", x.AddActionsE(actions...))
+}
+
+// AddActionsE adds actions, bad actions are skipped and returned as ConfigErrors keyed by their routes
+func (x *BaseWebHost) AddActionsE(actions ...*Action) error {
+	var errs ConfigErrors
 	////////// Add Actions
 	for _, action := range actions {
-		_, ok := x.Actions[action.Route]
-		if ok {
-			xlog.Fatal("duplicated route found: " + action.Route)
+		if len(action.Handlers) == 0 {
+			errs.Add(action.Route, "handlers are missing")
+			continue
 		}
-		x.Actions[action.Route] = action
+		errs.Append(action.Route, x.addAction(action))
 	}
+	return errs.Err()
 }
 
 func (x *BaseWebHost) AddAction(route, routeKey string, handlers ...RequestHandler) {
 	////////// 添加Action
-	action := NewAction(route, routeKey, handlers...)
-	_, ok := x.Actions[action.Route]
-	if ok {
-		xlog.Fatal("duplicated route found: " + action.Route)
+	x.AddActions(NewAction(route, routeKey, handlers...))
+}
+
+func (x *BaseWebHost) addAction(action *Action) error {
+	if _, ok := x.Actions[action.Route]; ok {
+		return &ConfigError{Key: action.Route, Message: "duplicated route found"}
 	}
 	x.Actions[action.Route] = action
+	return nil
+}
+
+// AddRouteError records a bad route which is registered by a method without error result
+func (x *BaseWebHost) AddRouteError(route, message string) {
+	x.routeErrs.Add(route, message)
+}
+
+// RouteErr returns errors of bad routes added so far, nil if there is none. Hosts check it before serving
+func (x *BaseWebHost) RouteErr() error {
+	return x.routeErrs.Err()
+}
+
+// AddRouteInfo records a route, hosts call it for every route they register to the underlying router
+func (x *BaseWebHost) AddRouteInfo(method, path, routeKey string, handlers ...interface{}) {
+	x.routes = append(x.routes, NewRouteInfo(method, path, routeKey, handlers...))
+}
+
+// GetRoutes returns copies of all registered routes, Actions which have not been registered to the router yet are included
+func (x *BaseWebHost) GetRoutes() []*RouteInfo {
+	r := make([]*RouteInfo, 0, len(x.routes)+len(x.Actions))
+	registered := make(map[string]bool, len(x.routes))
+	for _, v := range x.routes {
+		c := *v
+		if action, ok := x.Actions[v.Method+v.Path]; ok {
+			c.ActionPermission = action.Permission
+		}
+		r = append(r, &c)
+		registered[v.Method+v.Path] = true
+	}
+
+	for _, action := range x.Actions {
+		index := strings.Index(action.Route, "/")
+		if index < 0 || registered[action.Route] {
+			continue
+		}
+		handlers := make([]interface{}, 0, len(action.Handlers))
+		for _, h := range action.Handlers {
+			handlers = append(handlers, h)
+		}
+		info := NewRouteInfo(action.Route[:index], action.Route[index:], action.RouteKey, handlers...)
+		info.ActionPermission = action.Permission
+		r = append(r, info)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Path == r[j].Path {
+			return r[i].Method < r[j].Method
+		}
+		return r[i].Path < r[j].Path
+	})
+
+	return r
 }
 
 type SecureCookieHost struct {
@@ -179,12 +391,21 @@ func (x *SecureCookieHost) GetCookieEncryptor() xsecurity.ICookieEncryptor {
 }
 
 func (x *SecureCookieHost) BuildSecureCookieHost() {
+	xerr.FatalIfErr(x.BuildSecureCookieHostE())
+}
+
+func (x *SecureCookieHost) BuildSecureCookieHostE() error {
+	var errs ConfigErrors
 	if x.BlockKey == "" {
-		xlog.Fatal("block key cannot be empty")
+		errs.Add("BlockKey", "block key cannot be empty")
 	}
 	if x.HashKey == "" {
-		xlog.Fatal("hash key cannot be empty")
+		errs.Add("HashKey", "hash key cannot be empty")
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 
 	x.cookieEncryptor = xsecurity.NewSecureCookieEncryptor(xbytes.StrToBytes(x.HashKey), xbytes.StrToBytes(x.BlockKey))
+	return nil
 }
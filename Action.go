@@ -2,14 +2,14 @@ package host
 
 import (
 	"strings"
-
-	"github.com/DreamvatLab/go/xlog"
 )
 
 type ActionGroup struct {
 	PreHandlers   []RequestHandler
 	Actions       []*Action
 	AfterHandlers []RequestHandler
+	// Default permission of actions which don't declare their own
+	Permission *ActionPermission
 }
 
 type Action struct {
@@ -19,6 +19,11 @@ type Action struct {
 	Controller string
 	Action     string
 	Handlers   []RequestHandler
+	// Max request body size of this action, 0 uses the host's limit.
+	// A limit above the host's MaxRequestBodySize only works when the host streams request bodies
+	MaxBodySize int
+	// Declared permission checked by AuthHandler, the route in PermissionAuditor is checked if nil
+	Permission *ActionPermission
 }
 
 func NewActionGroup(preHandlers []RequestHandler, actions []*Action, afterHandlers ...RequestHandler) *ActionGroup {
@@ -29,11 +34,8 @@ func NewActionGroup(preHandlers []RequestHandler, actions []*Action, afterHandle
 	}
 }
 
+// NewAction creates an action, an action without handlers is reported when it's added to a host
 func NewAction(route, routeKey string, handlers ...RequestHandler) *Action {
-	if len(handlers) == 0 {
-		xlog.Fatal("handlers are missing")
-	}
-
 	var area, controller, action string
 	routeArray := strings.Split("_", routeKey)
 	if len(routeArray) == 3 {
@@ -60,3 +62,9 @@ func NewAction(route, routeKey string, handlers ...RequestHandler) *Action {
 func (x *Action) AppendHandler(handlers ...RequestHandler) {
 	x.Handlers = append(x.Handlers, handlers...)
 }
+
+// SetMaxBodySize sets max request body size of this action
+func (x *Action) SetMaxBodySize(size int) *Action {
+	x.MaxBodySize = size
+	return x
+}
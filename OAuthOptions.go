@@ -1,7 +1,7 @@
 package host
 
 import (
-	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xerr"
 	"github.com/DreamvatLab/host/hurl"
 	"github.com/DreamvatLab/oauth2go"
 	"golang.org/x/oauth2"
@@ -19,20 +19,32 @@ type (
 )
 
 func (x *OAuthOptions) BuildOAuthOptions(urlProvider hurl.IURLProvider) {
+	xerr.FatalIfErr(x.BuildOAuthOptionsE(urlProvider))
+}
+
+func (x *OAuthOptions) BuildOAuthOptionsE(urlProvider hurl.IURLProvider) error {
+	var errs ConfigErrors
+	if x.Config == nil {
+		errs.Add("OAuth", "OAuth section in configuration is missing")
+		return errs
+	}
 	if x.Endpoint.AuthURL == "" {
-		xlog.Fatal("OAuth.Endpoint.AuthURL cannot be empty")
+		errs.Add("OAuth.Endpoint.AuthURL", "cannot be empty")
 	}
 	if x.Endpoint.TokenURL == "" {
-		xlog.Fatal("OAuth.Endpoint.TokenURL cannot be empty")
+		errs.Add("OAuth.Endpoint.TokenURL", "cannot be empty")
 	}
 	if x.RedirectURL == "" {
-		xlog.Fatal("OAuth.RedirectURL cannot be empty")
+		errs.Add("OAuth.RedirectURL", "cannot be empty")
 	}
 	if x.SignOutRedirectURL == "" {
-		xlog.Fatal("OAuth.SignOutRedirectURL cannot be empty")
+		errs.Add("OAuth.SignOutRedirectURL", "cannot be empty")
 	}
 	if x.EndSessionEndpoint == "" {
-		xlog.Fatal("OAuth.EndSessionEndpoint cannot be empty")
+		errs.Add("OAuth.EndSessionEndpoint", "cannot be empty")
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 
 	if urlProvider != nil {
@@ -51,4 +63,5 @@ func (x *OAuthOptions) BuildOAuthOptions(urlProvider hurl.IURLProvider) {
 			Scopes:       x.Scopes,
 		},
 	}
+	return nil
 }
@@ -0,0 +1,180 @@
+package host
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xsecurity"
+	"github.com/DreamvatLab/go/xslice"
+)
+
+type (
+	// ActionPermission declares who can call an action, AuthHandler checks it instead of the route in PermissionAuditor,
+	// so code is the source of truth. Semantics are the same as xdto.Permission
+	ActionPermission struct {
+		ID             string   // Permission ID in the permission store, default route key of the action
+		AllowAnonymous bool     // Requests without users are allowed
+		AllowAnyUser   bool     // Any signed in user is allowed
+		AllowedRoles   int64    // Users having any of the role bits are allowed
+		Level          int32    // Min level of users with allowed roles
+		Scopes         []string // Scopes users must all have, checked before others
+	}
+
+	// PermissionSyncOptions publishes declared action permissions to RouteProvider and PermissionProvider at startup,
+	// routes and permissions which differ from code are overwritten and logged
+	PermissionSyncOptions struct {
+		Exit bool // Exit after syncing instead of serving, e.g. set 'HOST__PermissionSync__Exit=true' in deployment jobs
+	}
+)
+
+// Check returns true if a user with roles, level and scopes is allowed, zero roles means no user
+func (x *ActionPermission) Check(roles int64, level int32, scopes []string) bool {
+	if len(x.Scopes) > 0 && (len(scopes) == 0 || !xslice.HasAllStr(scopes, x.Scopes)) {
+		return false
+	}
+	if x.AllowAnonymous {
+		return true
+	}
+	if x.AllowAnyUser {
+		return roles > 0
+	}
+	return x.AllowedRoles&roles > 0 && level >= x.Level
+}
+
+// ToPermission converts to the permission stored in PermissionProvider
+func (x *ActionPermission) ToPermission(id string) *xdto.Permission {
+	if x.ID != "" {
+		id = x.ID
+	}
+	return &xdto.Permission{
+		ID:             id,
+		Name:           id,
+		IsAllowGuest:   x.AllowAnonymous,
+		IsAllowAnyUser: x.AllowAnyUser,
+		AllowedRoles:   x.AllowedRoles,
+		Level:          x.Level,
+		Scopes:         x.Scopes,
+	}
+}
+
+// GetActionPermission returns the declared permission of the current action, nil if it's not declared
+func GetActionPermission(ctx IHttpContext) *ActionPermission {
+	r, _ := ctx.GetItem(Ctx_Permission).(*ActionPermission)
+	return r
+}
+
+// CheckActionPermission checks the declared permission of the current action, or the route in auditor if it's not declared
+func CheckActionPermission(ctx IHttpContext, auditor xsecurity.IPermissionAuditor, roles int64, level int32, scopes []string) bool {
+	if permission := GetActionPermission(ctx); permission != nil {
+		return permission.Check(roles, level, scopes)
+	}
+	area, controller, action := GetRoutesByKey(ctx.GetItemString(Ctx_RouteKey))
+	return auditor.CheckRouteWithLevel(area, controller, action, roles, level, scopes)
+}
+
+// SyncActionPermissions writes declared permissions of actions and their routes to the providers, returns count of written items.
+// Routes are keyed by 'area_controller_action' which PermissionAuditor looks up first.
+// Nothing is written if actions declare different permissions under the same ID
+func SyncActionPermissions(actions map[string]*Action, routeProvider xsecurity.IRouteProvider, permissionProvider xsecurity.IPermissionProvider) (int, error) {
+	if routeProvider == nil || permissionProvider == nil {
+		return 0, xerr.New("route provider and permission provider are required to sync permissions")
+	}
+
+	routes, err := routeProvider.GetRoutes()
+	if err != nil {
+		return 0, err
+	}
+	permissions, err := permissionProvider.GetPermissions()
+	if err != nil {
+		return 0, err
+	}
+
+	// Actions sharing a permission ID must declare the same permission, otherwise the stored one would depend on map order
+	keys := make([]string, 0, len(actions))
+	declared := make(map[string]*Action)
+	for key := range actions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		action := actions[key]
+		if action.Permission == nil {
+			continue
+		}
+		permission := action.Permission.ToPermission(action.RouteKey)
+		if other, ok := declared[permission.ID]; !ok {
+			declared[permission.ID] = action
+		} else if !sameSecurityItem(other.Permission.ToPermission(other.RouteKey), permission) {
+			return 0, xerr.Errorf("permission '%s' is declared differently by '%s' and '%s'", permission.ID, other.RouteKey, action.RouteKey)
+		}
+	}
+
+	count := 0
+	for _, key := range keys {
+		action := actions[key]
+		if action.Permission == nil {
+			continue
+		}
+		area, controller, actionName := GetRoutesByKey(action.RouteKey)
+		routeID := area + Seperator_Route + controller + Seperator_Route + actionName
+
+		permission := action.Permission.ToPermission(action.RouteKey)
+		existing := permissions[permission.ID]
+		if existing != nil && existing.Name != "" {
+			permission.Name = existing.Name // Names are maintained in the store
+		}
+		if !sameSecurityItem(existing, permission) {
+			if existing != nil {
+				xlog.Warnf("permission '%s' differs from code, overwritten: %s => %s", permission.ID, toSecurityJson(existing), toSecurityJson(permission))
+			}
+			if err := permissionProvider.UpdatePermission(permission); err != nil {
+				return count, err
+			}
+			permissions[permission.ID] = permission // Actions may share permissions
+			count++
+		}
+
+		route := &xdto.Route{
+			ID:            routeID,
+			Permission_ID: permission.ID,
+			Area:          area,
+			Controller:    controller,
+			Action:        actionName,
+		}
+		if existingRoute := routes[routeID]; !sameSecurityItem(existingRoute, route) {
+			if existingRoute != nil {
+				xlog.Warnf("route '%s' differs from code, overwritten: %s => %s", routeID, toSecurityJson(existingRoute), toSecurityJson(route))
+			}
+			if err := routeProvider.UpdateRoute(route); err != nil {
+				return count, err
+			}
+			routes[routeID] = route
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Require declares the permission of the action
+func (x *Action) Require(permission *ActionPermission) *Action {
+	x.Permission = permission
+	return x
+}
+
+// Require declares the permission of actions in the group which don't declare their own
+func (x *ActionGroup) Require(permission *ActionPermission) *ActionGroup {
+	x.Permission = permission
+	return x
+}
+
+func sameSecurityItem(a, b interface{}) bool {
+	return toSecurityJson(a) == toSecurityJson(b)
+}
+
+func toSecurityJson(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,86 @@
+package host_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestActionPermissionCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission *host.ActionPermission
+		roles      int64
+		level      int32
+		scopes     []string
+		expected   bool
+	}{
+		{"anonymous", &host.ActionPermission{AllowAnonymous: true}, 0, 0, nil, true},
+		{"anonymous with scopes", &host.ActionPermission{AllowAnonymous: true, Scopes: []string{"orders"}}, 0, 0, nil, false},
+		{"any user", &host.ActionPermission{AllowAnyUser: true}, 1, 0, nil, true},
+		{"any user without user", &host.ActionPermission{AllowAnyUser: true}, 0, 0, nil, false},
+		{"role", &host.ActionPermission{AllowedRoles: 4 | 8}, 8, 0, nil, true},
+		{"other role", &host.ActionPermission{AllowedRoles: 4 | 8}, 2, 0, nil, false},
+		{"level", &host.ActionPermission{AllowedRoles: 4, Level: 2}, 4, 2, nil, true},
+		{"low level", &host.ActionPermission{AllowedRoles: 4, Level: 2}, 4, 1, nil, false},
+		{"all scopes", &host.ActionPermission{AllowedRoles: 4, Scopes: []string{"a", "b"}}, 4, 0, []string{"b", "c", "a"}, true},
+		{"missing scope", &host.ActionPermission{AllowedRoles: 4, Scopes: []string{"a", "b"}}, 4, 0, []string{"a"}, false},
+		{"nothing allowed", &host.ActionPermission{}, 4, 9, nil, false},
+	}
+	for _, tt := range tests {
+		if actual := tt.permission.Check(tt.roles, tt.level, tt.scopes); actual != tt.expected {
+			t.Errorf("%s: Check(%d, %d, %v) = %v, expected %v", tt.name, tt.roles, tt.level, tt.scopes, actual, tt.expected)
+		}
+	}
+}
+
+func TestSyncActionPermissions(t *testing.T) {
+	shared := &host.ActionPermission{ID: "orders_read", AllowedRoles: 4}
+	actions := map[string]*host.Action{
+		"GET/orders":     host.NewAction("GET/orders", "api_orders_list", nil).Require(shared),
+		"GET/orders/:id": host.NewAction("GET/orders/:id", "api_orders_get", nil).Require(&host.ActionPermission{ID: "orders_read", AllowedRoles: 4}),
+		"POST/orders":    host.NewAction("POST/orders", "api_orders_create", nil).Require(&host.ActionPermission{AllowedRoles: 8}),
+		"GET/ping":       host.NewAction("GET/ping", "api_ping_get", nil),
+	}
+
+	// The store differs from code, code wins
+	permissions := hosttest.NewStubPermissions().AllowRoles("orders_read", 2, 0)
+	if _, err := host.SyncActionPermissions(actions, nil, permissions); err == nil {
+		t.Error("sync without route provider")
+	}
+
+	count, err := host.SyncActionPermissions(actions, permissions, permissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 { // 2 permissions and 3 routes
+		t.Errorf("sync wrote %d items, expected 5", count)
+	}
+	if permission, err := permissions.GetPermission("orders_read"); err != nil || permission.AllowedRoles != 4 {
+		t.Errorf("shared permission %+v, %v, expected overwritten by code", permission, err)
+	}
+	if route, err := permissions.GetRoute("api_orders_get"); err != nil || route.Permission_ID != "orders_read" {
+		t.Errorf("route %+v, %v, expected the shared permission", route, err)
+	}
+	if _, err := permissions.GetRoute("api_ping_get"); err == nil {
+		t.Error("route of an action without declared permission is synced")
+	}
+	if count, err := host.SyncActionPermissions(actions, permissions, permissions); err != nil || count != 0 {
+		t.Errorf("second sync wrote %d items: %v", count, err)
+	}
+
+	// Conflicting declarations under one ID fail before anything is written
+	actions["DELETE/orders/:id"] = host.NewAction("DELETE/orders/:id", "api_orders_delete", nil).Require(&host.ActionPermission{ID: "orders_read", AllowedRoles: 16})
+	permissions = hosttest.NewStubPermissions()
+	for i := 0; i < 5; i++ { // Map order doesn't matter
+		count, err = host.SyncActionPermissions(actions, permissions, permissions)
+		if err == nil || !strings.Contains(err.Error(), "orders_read") || count != 0 {
+			t.Fatalf("sync of conflicting permissions wrote %d items: %v", count, err)
+		}
+	}
+	if all, _ := permissions.GetPermissions(); len(all) != 0 {
+		t.Errorf("permissions %v, expected nothing written", all)
+	}
+}
@@ -0,0 +1,397 @@
+package host
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xbytes"
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xconv"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xhttp"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xsecurity/xrsa"
+	"github.com/DreamvatLab/go/xslice"
+	oauth2core "github.com/DreamvatLab/oauth2go/core"
+	"github.com/pascaldekloe/jwt"
+)
+
+const _redacted = "******"
+
+type (
+	// AdminOptions enables admin endpoints under PathPrefix:
+	//   GET  runtime, buildinfo, goroutines, config (secrets redacted), pprof/ and pprof/{profile}
+	//   GET  loglevel, PUT loglevel with {"Level":"debug","Seconds":600} to change the log level, reverted after Seconds if set
+	// Requests must come from AllowedIPs or carry a bearer token having any of Roles, at least one of them is required
+	AdminOptions struct {
+		PathPrefix     string         // Default '/_admin'
+		AllowedIPs     []string       // IPs or CIDRs
+		Roles          int64          // Role bits of bearer tokens, bearer tokens are not accepted if 0
+		PublicKeyPath  string         // Certificate verifying bearer tokens, oauth resource hosts default to their own public key
+		PublicKey      *rsa.PublicKey `json:"-"`
+		ValidIssuers   []string       // Checked if not empty
+		ValidAudiences []string       // Checked if not empty
+		RedactKeys     []string       // Additional config keys redacted in config dump, matched case-insensitively as substrings
+		ListenAddr     string         // Address of the admin http server of hosts without http listeners, e.g. grpc host
+	}
+
+	// Admin serves the admin endpoints by net/http, web hosts mount Handler behind AuthHandler
+	Admin struct {
+		options     *AdminOptions
+		cp          xconfig.IConfigProvider
+		allowedNets []*net.IPNet
+		redactKeys  []string
+		startTime   time.Time
+		mux         *http.ServeMux
+	}
+
+	logLevelRequest struct {
+		Level   string
+		Seconds int
+	}
+)
+
+var (
+	_defaultRedactKeys = []string{"password", "passwd", "pwd", "secret", "token", "privatekey", "apikey", "connectionstring", "credential", "hashkey", "blockkey"}
+	_logLock           sync.Mutex
+	_logConfig         = new(xlog.LogConfig)
+	_logSinks          []xlog.LogSink
+	_logBaseLevel      string // Level of the config, temporary levels revert to it
+	_logRevert         *time.Timer
+)
+
+// NewAdmin creates admin endpoints, cp is dumped by the config endpoint
+func NewAdmin(options *AdminOptions, cp xconfig.IConfigProvider) (*Admin, error) {
+	if options == nil {
+		return nil, xerr.New("admin options cannot be nil")
+	}
+	if len(options.AllowedIPs) == 0 && options.Roles == 0 {
+		return nil, xerr.New("admin endpoints require AllowedIPs or Roles")
+	}
+	if options.PathPrefix == "" {
+		options.PathPrefix = "/_admin"
+	}
+	options.PathPrefix = strings.TrimSuffix(options.PathPrefix, "/")
+
+	if options.Roles != 0 && options.PublicKey == nil {
+		if options.PublicKeyPath == "" {
+			return nil, xerr.New("admin Roles require PublicKeyPath to verify bearer tokens")
+		}
+		cert, err := xrsa.ReadCertFromFile(options.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, xerr.New("admin certificate does not contain a rsa public key")
+		}
+		options.PublicKey = publicKey
+	}
+
+	allowedNets, err := ParseIPNets(options.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Admin{
+		options:     options,
+		cp:          cp,
+		allowedNets: allowedNets,
+		redactKeys:  append([]string{}, _defaultRedactKeys...),
+		startTime:   time.Now(),
+		mux:         http.NewServeMux(),
+	}
+	for _, key := range options.RedactKeys {
+		r.redactKeys = append(r.redactKeys, strings.ToLower(key))
+	}
+
+	p := options.PathPrefix
+	r.mux.HandleFunc("GET "+p+"/runtime", r.runtimeHandler)
+	r.mux.HandleFunc("GET "+p+"/buildinfo", r.buildInfoHandler)
+	r.mux.HandleFunc("GET "+p+"/goroutines", r.goroutinesHandler)
+	r.mux.HandleFunc("GET "+p+"/config", r.configHandler)
+	r.mux.HandleFunc("GET "+p+"/loglevel", r.getLogLevelHandler)
+	r.mux.HandleFunc("PUT "+p+"/loglevel", r.setLogLevelHandler)
+	r.mux.HandleFunc("GET "+p+"/pprof/", pprof.Index)
+	r.mux.HandleFunc("GET "+p+"/pprof/cmdline", pprof.Cmdline)
+	r.mux.HandleFunc("GET "+p+"/pprof/profile", pprof.Profile)
+	r.mux.HandleFunc("GET "+p+"/pprof/symbol", pprof.Symbol)
+	r.mux.HandleFunc("GET "+p+"/pprof/trace", pprof.Trace)
+	r.mux.HandleFunc("GET "+p+"/pprof/{profile}", func(w http.ResponseWriter, req *http.Request) {
+		pprof.Handler(req.PathValue("profile")).ServeHTTP(w, req)
+	})
+
+	return r, nil
+}
+
+// GetPathPrefix returns the path all admin endpoints are under
+func (x *Admin) GetPathPrefix() string {
+	return x.options.PathPrefix
+}
+
+// Handler serves admin endpoints without authorization, mount it behind AuthHandler
+func (x *Admin) Handler() http.Handler {
+	return x.mux
+}
+
+// HTTPHandler serves admin endpoints to clients authorized by their peer address, used by standalone admin http servers
+func (x *Admin) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := x.Authorize(stripPort(r.RemoteAddr), r.Header.Get(xhttp.HEADER_AUTH)); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		x.mux.ServeHTTP(w, r)
+	})
+}
+
+// AuthHandler authorizes requests by real ip or bearer token
+func (x *Admin) AuthHandler(ctx IHttpContext) {
+	if status := x.Authorize(ctx.GetRealIP(), ctx.GetHeader(xhttp.HEADER_AUTH)); status != http.StatusOK {
+		ctx.SetStatusCode(status)
+		return
+	}
+	ctx.Next()
+}
+
+// Authorize returns 200 if ip is allowed or the bearer token has any of Roles, 401 or 403 otherwise
+func (x *Admin) Authorize(ip, authorization string) int {
+	if len(x.allowedNets) > 0 && ContainsIP(x.allowedNets, ip) {
+		return http.StatusOK
+	}
+
+	token, ok := strings.CutPrefix(authorization, AuthType_Bearer+" ")
+	if x.options.Roles == 0 || !ok {
+		xlog.Warnf("admin access denied. IP:[%s]", ip)
+		return http.StatusUnauthorized
+	}
+
+	claims, err := jwt.RSACheck(xbytes.StrToBytes(token), x.options.PublicKey)
+	if err != nil || !claims.Valid(time.Now().UTC()) {
+		xlog.Warnf("admin access denied, invalid token. IP:[%s]", ip)
+		return http.StatusUnauthorized
+	}
+	if len(x.options.ValidIssuers) > 0 && !xslice.HasStr(x.options.ValidIssuers, claims.Issuer) ||
+		len(x.options.ValidAudiences) > 0 && !xslice.HasAnyStr(x.options.ValidAudiences, claims.Audiences) {
+		xlog.Warnf("admin access denied, invalid issuer or audience. IP:[%s]", ip)
+		return http.StatusUnauthorized
+	}
+	if xconv.ToInt64(claims.Set[oauth2core.Claim_Role])&x.options.Roles == 0 {
+		xlog.Warnf("admin access denied, '%s' has no admin role. IP:[%s]", claims.Subject, ip)
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+func (x *Admin) runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	writeAdminJSON(w, map[string]interface{}{
+		"StartTime":    x.startTime,
+		"Uptime":       time.Since(x.startTime).Round(time.Second).String(),
+		"GoVersion":    runtime.Version(),
+		"NumCPU":       runtime.NumCPU(),
+		"GOMAXPROCS":   runtime.GOMAXPROCS(0),
+		"NumGoroutine": runtime.NumGoroutine(),
+		"LogLevel":     GetLogLevel(),
+		"Memory": map[string]interface{}{
+			"Alloc":        mem.Alloc,
+			"TotalAlloc":   mem.TotalAlloc,
+			"Sys":          mem.Sys,
+			"HeapAlloc":    mem.HeapAlloc,
+			"HeapInuse":    mem.HeapInuse,
+			"HeapObjects":  mem.HeapObjects,
+			"NumGC":        mem.NumGC,
+			"PauseTotalNs": mem.PauseTotalNs,
+		},
+	})
+}
+
+func (x *Admin) buildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info is not available", http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, info)
+}
+
+func (x *Admin) goroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL.RawQuery = "debug=2"
+	pprof.Handler("goroutine").ServeHTTP(w, r)
+}
+
+func (x *Admin) configHandler(w http.ResponseWriter, r *http.Request) {
+	if x.cp == nil {
+		http.Error(w, "config provider is not available", http.StatusNotFound)
+		return
+	}
+	var config map[string]interface{}
+	if err := x.cp.GetStruct("@this", &config); err != nil {
+		xlog.Error(err)
+		http.Error(w, "failed to read config", http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, x.Redact(config))
+}
+
+func (x *Admin) getLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, &logLevelRequest{Level: GetLogLevel()})
+}
+
+func (x *Admin) setLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var req *logLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := SetLogLevel(req.Level, time.Duration(req.Seconds)*time.Second); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	xlog.Warnf("log level changed to '%s' by admin endpoint, revert after %d seconds. IP:[%s]", req.Level, req.Seconds, stripPort(r.RemoteAddr))
+	writeAdminJSON(w, &logLevelRequest{Level: GetLogLevel(), Seconds: req.Seconds})
+}
+
+// Redact replaces values of secret keys in config, and values resolved from secrets if cp is a ISecretConfigProvider
+func (x *Admin) Redact(config map[string]interface{}) map[string]interface{} {
+	secretKeys := make(map[string]bool)
+	if cp, ok := x.cp.(ISecretConfigProvider); ok {
+		for _, key := range cp.GetSecretKeys() {
+			secretKeys[strings.ToLower(key)] = true
+		}
+	}
+	return x.redactMap("", config, secretKeys)
+}
+
+func (x *Admin) redactMap(prefix string, config map[string]interface{}, secretKeys map[string]bool) map[string]interface{} {
+	r := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		key := joinConfigKey(prefix, k)
+		if x.isSecretKey(k) || secretKeys[strings.ToLower(key)] {
+			r[k] = _redacted
+			continue
+		}
+		r[k] = x.redactValue(key, v, secretKeys)
+	}
+	return r
+}
+
+func (x *Admin) redactValue(key string, v interface{}, secretKeys map[string]bool) interface{} {
+	switch a := v.(type) {
+	case map[string]interface{}:
+		return x.redactMap(key, a, secretKeys)
+	case []interface{}:
+		r := make([]interface{}, len(a))
+		for i := range a {
+			itemKey := joinConfigKey(key, strconv.Itoa(i))
+			if secretKeys[strings.ToLower(itemKey)] {
+				r[i] = _redacted
+				continue
+			}
+			r[i] = x.redactValue(itemKey, a[i], secretKeys)
+		}
+		return r
+	default:
+		return v
+	}
+}
+
+func (x *Admin) isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range x.redactKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set(xhttp.HEADER_CTYPE, xhttp.CTYPE_JSON)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		xlog.Error(err)
+	}
+}
+
+// initLog initializes xlog and keeps the config and sinks, so the level can be changed at runtime.
+// A temporary level set by SetLogLevel is replaced
+func initLog(config *xlog.LogConfig, sinks ...xlog.LogSink) {
+	if config == nil {
+		config = new(xlog.LogConfig)
+	}
+	_logLock.Lock()
+	defer _logLock.Unlock()
+	if _logRevert != nil {
+		_logRevert.Stop()
+		_logRevert = nil
+	}
+	_logConfig = config
+	_logSinks = sinks
+	_logBaseLevel = config.Level
+	xlog.Init(config, sinks...)
+}
+
+// GetLogLevel returns the current log level
+func GetLogLevel() string {
+	_logLock.Lock()
+	defer _logLock.Unlock()
+	return _logConfig.Level
+}
+
+// SetLogLevel changes the log level at runtime, the level of the config is restored after revertAfter if it's positive
+func SetLogLevel(level string, revertAfter time.Duration) error {
+	level = strings.ToLower(level)
+	if _, ok := xlog.LogLevelMap[level]; !ok {
+		return xerr.Errorf("invalid log level '%s'", level)
+	}
+
+	_logLock.Lock()
+	defer _logLock.Unlock()
+	if _logRevert != nil {
+		_logRevert.Stop()
+		_logRevert = nil
+	}
+
+	setLogLevel(level)
+
+	if revertAfter > 0 {
+		var revert *time.Timer
+		revert = time.AfterFunc(revertAfter, func() {
+			_logLock.Lock()
+			defer _logLock.Unlock()
+			if _logRevert != revert {
+				return // Replaced by a later change
+			}
+			setLogLevel(_logBaseLevel)
+			_logRevert = nil
+		})
+		_logRevert = revert
+	} else {
+		_logBaseLevel = level
+	}
+	return nil
+}
+
+// setLogLevel rebuilds the logger with the same sinks, xlog can't change the level of its logger.
+// xlog doesn't close file writers of replaced loggers, their files are closed once they are garbage collected
+func setLogLevel(level string) {
+	if level == _logConfig.Level {
+		return
+	}
+	config := *_logConfig
+	config.Level = level
+	_logConfig = &config
+	xlog.Init(_logConfig, _logSinks...)
+}
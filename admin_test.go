@@ -0,0 +1,150 @@
+package host
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xlog"
+	oauth2core "github.com/DreamvatLab/oauth2go/core"
+	"github.com/pascaldekloe/jwt"
+)
+
+func TestAdminAuthorize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := NewAdmin(&AdminOptions{
+		AllowedIPs:     []string{"10.0.0.0/8"},
+		Roles:          4,
+		PublicKey:      &key.PublicKey,
+		ValidIssuers:   []string{"https://issuer"},
+		ValidAudiences: []string{"api"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mint := func(issuer string, roles int64, ttl time.Duration) string {
+		now := time.Now().UTC()
+		c := &jwt.Claims{Set: map[string]interface{}{oauth2core.Claim_Role: roles}}
+		c.Issuer = issuer
+		c.Audiences = []string{"api"}
+		c.Issued = jwt.NewNumericTime(now)
+		c.Expires = jwt.NewNumericTime(now.Add(ttl))
+		token, err := c.RSASign(jwt.PS256, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return AuthType_Bearer + " " + string(token)
+	}
+
+	tests := []struct {
+		name, ip, authorization string
+		expected                int
+	}{
+		{"allowed ip", "10.1.2.3", "", http.StatusOK},
+		{"no token", "192.168.1.1", "", http.StatusUnauthorized},
+		{"not bearer", "192.168.1.1", "Basic YTpi", http.StatusUnauthorized},
+		{"admin role", "192.168.1.1", mint("https://issuer", 4|1, time.Hour), http.StatusOK},
+		{"no admin role", "192.168.1.1", mint("https://issuer", 1, time.Hour), http.StatusForbidden},
+		{"invalid issuer", "192.168.1.1", mint("https://other", 4, time.Hour), http.StatusUnauthorized},
+		{"expired", "192.168.1.1", mint("https://issuer", 4, -time.Minute), http.StatusUnauthorized},
+		{"bad signature", "192.168.1.1", mint("https://issuer", 4, time.Hour) + "x", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if actual := admin.Authorize(tt.ip, tt.authorization); actual != tt.expected {
+			t.Errorf("%s: Authorize() = %d, expected %d", tt.name, actual, tt.expected)
+		}
+	}
+}
+
+func TestAdminRedact(t *testing.T) {
+	admin, err := NewAdmin(&AdminOptions{AllowedIPs: []string{"127.0.0.1"}, RedactKeys: []string{"Salt"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := admin.Redact(map[string]interface{}{
+		"Name":              "api",
+		"ConnectionStrings": map[string]interface{}{"Redis": "redis://:p@host"},
+		"OAuth":             map[string]interface{}{"ClientID": "web", "ClientSecret": "s"},
+		"Clients":           []interface{}{map[string]interface{}{"ID": "a", "Password": "p"}},
+		"PasswordSalt":      "x",
+	})
+	expected := map[string]interface{}{
+		"Name":              "api",
+		"ConnectionStrings": _redacted,
+		"OAuth":             map[string]interface{}{"ClientID": "web", "ClientSecret": _redacted},
+		"Clients":           []interface{}{map[string]interface{}{"ID": "a", "Password": _redacted}},
+		"PasswordSalt":      _redacted,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Redact() = %v, expected %v", actual, expected)
+	}
+}
+
+type testLogSink struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (x *testLogSink) WriteLog(entry *xlog.LogEntry) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.messages = append(x.messages, entry.Message)
+}
+
+func (x *testLogSink) has(msg string) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for _, m := range x.messages {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetLogLevel(t *testing.T) {
+	sink := new(testLogSink)
+	initLog(&xlog.LogConfig{Level: xlog.LogLevelWarn}, sink)
+	defer initLog(nil)
+
+	if err := SetLogLevel("verbose", 0); err == nil {
+		t.Fatal("invalid level is accepted")
+	}
+
+	xlog.Info("before")
+	if err := SetLogLevel("INFO", 0); err != nil {
+		t.Fatal(err)
+	}
+	xlog.Info("after")
+	if sink.has("before") || !sink.has("after") {
+		t.Fatalf("sink messages = %v, expected only 'after'", sink.messages)
+	}
+	if err := SetLogLevel(xlog.LogLevelWarn, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Temporary levels revert to the base level, not to each other
+	SetLogLevel(xlog.LogLevelDebug, time.Hour)
+	SetLogLevel(xlog.LogLevelInfo, 50*time.Millisecond)
+	if level := GetLogLevel(); level != xlog.LogLevelInfo {
+		t.Fatalf("level = %s, expected info", level)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if level := GetLogLevel(); level != xlog.LogLevelWarn {
+		t.Fatalf("reverted level = %s, expected warn", level)
+	}
+
+	xlog.Warn("reverted")
+	if !sink.has("reverted") {
+		t.Fatal("sink is dropped by level changes")
+	}
+}
@@ -0,0 +1,126 @@
+// Command hostsec syncs route and permission files with the redis hashes named by RouteKey and PermissionKey.
+//
+//	hostsec export [flags]   writes redis hashes to the files
+//	hostsec import [flags]   writes the files to redis hashes
+//
+// Redis, keys and files default to 'ConnectionStrings.Redis', 'RouteKey', 'PermissionKey', 'RouteFile' and 'PermissionFile' of the host config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/host"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%+v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: hostsec export|import [flags], run 'hostsec export -h' for flags")
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("hostsec "+command, flag.ContinueOnError)
+	configFile := flags.String("config", "configs.json", "host config file")
+	env := flags.String("env", "", "profile of the host config, e.g. 'dev' loads configs.dev.json too")
+	redisConnStr := flags.String("redis", "", "redis connection string, default 'ConnectionStrings.Redis' of the config")
+	routeKey := flags.String("route-key", "", "redis hash of routes, default 'RouteKey' of the config")
+	permissionKey := flags.String("permission-key", "", "redis hash of permissions, default 'PermissionKey' of the config")
+	routeFile := flags.String("routes", "", "json or yaml file of routes, default 'RouteFile' of the config")
+	permissionFile := flags.String("permissions", "", "json or yaml file of permissions, default 'PermissionFile' of the config")
+	prune := flags.Bool("prune", false, "remove items missing in the source")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cp, err := host.NewLayeredConfigProvider(&host.LayeredConfigOptions{
+		File: *configFile,
+		Env:  *env,
+	})
+	if err != nil {
+		return err
+	}
+	configDefault(redisConnStr, cp.GetString("ConnectionStrings.Redis"))
+	configDefault(routeKey, cp.GetString("RouteKey"))
+	configDefault(permissionKey, cp.GetString("PermissionKey"))
+	configDefault(routeFile, cp.GetString("RouteFile"))
+	configDefault(permissionFile, cp.GetString("PermissionFile"))
+
+	if *redisConnStr == "" {
+		return fmt.Errorf("redis connection string is required")
+	}
+	redisConfig, err := xredis.ParseRedisConfig(*redisConnStr)
+	if err != nil {
+		return err
+	}
+
+	synced := false
+	if *routeKey != "" && *routeFile != "" {
+		file, err := host.NewFileRouteProvider(*routeFile, 0)
+		if err != nil {
+			return err
+		}
+		redis, err := host.NewRedisRouteProvider(*routeKey, redisConfig)
+		if err != nil {
+			return err
+		}
+		defer redis.Close()
+		if command == "export" {
+			err = host.CopyRoutes(file, redis, *prune)
+		} else {
+			err = host.CopyRoutes(redis, file, *prune)
+		}
+		if err != nil {
+			return err
+		}
+		printSynced(command, "routes", *routeKey, *routeFile)
+		synced = true
+	}
+	if *permissionKey != "" && *permissionFile != "" {
+		file, err := host.NewFilePermissionProvider(*permissionFile, 0)
+		if err != nil {
+			return err
+		}
+		redis, err := host.NewRedisPermissionProvider(*permissionKey, redisConfig)
+		if err != nil {
+			return err
+		}
+		defer redis.Close()
+		if command == "export" {
+			err = host.CopyPermissions(file, redis, *prune)
+		} else {
+			err = host.CopyPermissions(redis, file, *prune)
+		}
+		if err != nil {
+			return err
+		}
+		printSynced(command, "permissions", *permissionKey, *permissionFile)
+		synced = true
+	}
+	if !synced {
+		return fmt.Errorf("nothing to %s, set a redis key and a file of routes or permissions", command)
+	}
+	return nil
+}
+
+func configDefault(v *string, configValue string) {
+	if *v == "" {
+		*v = configValue
+	}
+}
+
+func printSynced(command, kind, key, file string) {
+	if command == "export" {
+		fmt.Printf("%s exported from redis '%s' to '%s'\n", kind, key, file)
+	} else {
+		fmt.Printf("%s imported from '%s' to redis '%s'\n", kind, file, key)
+	}
+}
@@ -0,0 +1,62 @@
+package host
+
+import (
+	"errors"
+	"strings"
+)
+
+// ConfigError is an invalid configuration value, Key is the config key, e.g. 'OAuth.RedirectURL'
+type ConfigError struct {
+	Key     string
+	Message string
+}
+
+func (x *ConfigError) Error() string {
+	if x.Key == "" {
+		return x.Message
+	}
+	return x.Key + ": " + x.Message
+}
+
+// ConfigErrors aggregates every invalid configuration value found while building a host
+type ConfigErrors []*ConfigError
+
+func (x ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(x))
+	for _, v := range x {
+		msgs = append(msgs, v.Error())
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Add records an invalid config key
+func (x *ConfigErrors) Add(key, message string) {
+	*x = append(*x, &ConfigError{Key: key, Message: message})
+}
+
+// Append records err under key, ConfigErrors and ConfigError are merged with their own keys. nil is ignored
+func (x *ConfigErrors) Append(key string, err error) {
+	if err == nil {
+		return
+	}
+
+	var errs ConfigErrors
+	if errors.As(err, &errs) {
+		*x = append(*x, errs...)
+		return
+	}
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		*x = append(*x, configErr)
+		return
+	}
+	x.Add(key, err.Error())
+}
+
+// Err returns nil if there is no error, otherwise x itself
+func (x ConfigErrors) Err() error {
+	if len(x) == 0 {
+		return nil
+	}
+	return x
+}
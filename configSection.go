@@ -0,0 +1,114 @@
+package host
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/DreamvatLab/go/xconfig"
+)
+
+// sectionConfigProvider reads values of a section in place of root values, other values come from the root,
+// so hosts in one process share sections like ConnectionStrings and Log but have their own ListenAddr
+type sectionConfigProvider struct {
+	xconfig.IConfigProvider
+	section string
+}
+
+// NewSectionConfigProvider overlays the section of cp on its root, e.g. with section 'GRPC', 'ListenAddr' is read from 'GRPC.ListenAddr' if present
+func NewSectionConfigProvider(cp xconfig.IConfigProvider, section string) xconfig.IConfigProvider {
+	return &sectionConfigProvider{
+		IConfigProvider: cp,
+		section:         section,
+	}
+}
+
+// OnChange registers the callback to the root provider if it reloads at runtime, both key and the key in the section are watched
+func (x *sectionConfigProvider) OnChange(key string, callback func(cp xconfig.IConfigProvider)) {
+	watcher, ok := x.IConfigProvider.(IConfigWatcher)
+	if !ok {
+		return
+	}
+	if key == "" {
+		watcher.OnChange("", func(xconfig.IConfigProvider) { callback(x) })
+		return
+	}
+
+	// Fire once per reload even if both keys changed
+	var lock sync.Mutex
+	var last string
+	fire := func(cp xconfig.IConfigProvider) {
+		var raw json.RawMessage
+		x.GetStruct(key, &raw)
+		lock.Lock()
+		changed := string(raw) != last
+		last = string(raw)
+		lock.Unlock()
+		if changed {
+			callback(x)
+		}
+	}
+	var raw json.RawMessage
+	x.GetStruct(key, &raw)
+	last = string(raw)
+	watcher.OnChange(key, fire)
+	watcher.OnChange(x.section+"."+key, fire)
+}
+
+func (x *sectionConfigProvider) has(key string) bool {
+	var raw json.RawMessage
+	return x.IConfigProvider.GetStruct(x.section+"."+key, &raw) == nil
+}
+
+func (x *sectionConfigProvider) key(key string) string {
+	if x.has(key) {
+		return x.section + "." + key
+	}
+	return key
+}
+
+// GetStruct reads the root value then overlays the section value on it, '@this' overlays the whole section on the root
+func (x *sectionConfigProvider) GetStruct(key string, target interface{}) error {
+	sectionKey := x.section + "." + key
+	if key == "@this" {
+		sectionKey = x.section
+	}
+
+	err := x.IConfigProvider.GetStruct(key, target)
+	var raw json.RawMessage
+	if x.IConfigProvider.GetStruct(sectionKey, &raw) != nil {
+		return err
+	}
+	return x.IConfigProvider.GetStruct(sectionKey, target)
+}
+
+func (x *sectionConfigProvider) GetString(key string) string {
+	return x.IConfigProvider.GetString(x.key(key))
+}
+
+func (x *sectionConfigProvider) GetStringDefault(key string, defaultValue string) string {
+	return x.IConfigProvider.GetStringDefault(x.key(key), defaultValue)
+}
+
+func (x *sectionConfigProvider) GetBool(key string) bool {
+	return x.IConfigProvider.GetBool(x.key(key))
+}
+
+func (x *sectionConfigProvider) GetFloat64(key string) float64 {
+	return x.IConfigProvider.GetFloat64(x.key(key))
+}
+
+func (x *sectionConfigProvider) GetInt(key string) int {
+	return x.IConfigProvider.GetInt(x.key(key))
+}
+
+func (x *sectionConfigProvider) GetIntDefault(key string, defaultValue int) int {
+	return x.IConfigProvider.GetIntDefault(x.key(key), defaultValue)
+}
+
+func (x *sectionConfigProvider) GetStringSlice(key string) []string {
+	return x.IConfigProvider.GetStringSlice(x.key(key))
+}
+
+func (x *sectionConfigProvider) GetIntSlice(key string) []int {
+	return x.IConfigProvider.GetIntSlice(x.key(key))
+}
@@ -0,0 +1,62 @@
+package host
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/tidwall/gjson"
+)
+
+type (
+	// ConfigChangeNotifier keeps change callbacks of config providers which reload at runtime, embed it to implement IConfigWatcher
+	ConfigChangeNotifier struct {
+		lock      sync.Mutex
+		callbacks []*configChangeCallback
+	}
+
+	configChangeCallback struct {
+		key      string
+		callback func(cp xconfig.IConfigProvider)
+	}
+)
+
+// OnChange registers a callback fired after the value of key changes, empty key means any change
+func (x *ConfigChangeNotifier) OnChange(key string, callback func(cp xconfig.IConfigProvider)) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.callbacks = append(x.callbacks, &configChangeCallback{key: key, callback: callback})
+}
+
+// Notify fires callbacks of keys whose values differ between previous and current raw json, or which are, or contain, any of changedKeys.
+// changedKeys covers changes invisible in json, e.g. the content of a file whose path stays the same
+func (x *ConfigChangeNotifier) Notify(cp xconfig.IConfigProvider, previous, current []byte, changedKeys ...string) {
+	x.lock.Lock()
+	callbacks := x.callbacks
+	x.lock.Unlock()
+
+	for _, c := range callbacks {
+		if c.key == "" || gjson.GetBytes(previous, c.key).Raw != gjson.GetBytes(current, c.key).Raw || containsConfigKey(changedKeys, c.key) {
+			x.fire(cp, c)
+		}
+	}
+}
+
+func (x *ConfigChangeNotifier) fire(cp xconfig.IConfigProvider, c *configChangeCallback) {
+	defer func() {
+		if r := recover(); r != nil {
+			xlog.Errorf("config change callback of '%s' panicked: %v", c.key, r)
+		}
+	}()
+	c.callback(cp)
+}
+
+func containsConfigKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
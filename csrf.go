@@ -0,0 +1,53 @@
+package host
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/DreamvatLab/go/xlog"
+)
+
+const (
+	Session_CSRFToken = "_csrf"
+	Header_CSRFToken  = "X-CSRF-Token"
+	Form_CSRFToken    = "_csrf"
+)
+
+// GetCSRFToken returns the csrf token of current session, a new token is created if there is none
+func GetCSRFToken(ctx IHttpContext) string {
+	token := ctx.GetSessionString(Session_CSRFToken)
+	if token == "" {
+		data := make([]byte, 32)
+		rand.Read(data)
+		token = base64.RawURLEncoding.EncodeToString(data)
+		ctx.SetSession(Session_CSRFToken, token)
+	}
+	return token
+}
+
+// CSRFHandler is the middleware which rejects unsafe requests (POST, PUT, PATCH, DELETE) with 403,
+// unless they carry the session's token in 'X-CSRF-Token' header or '_csrf' form field
+func CSRFHandler(ctx IHttpContext) {
+	switch ctx.RequestMethod() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		ctx.Next()
+		return
+	}
+
+	token := ctx.GetHeader(Header_CSRFToken)
+	if token == "" {
+		token = ctx.GetFormString(Form_CSRFToken)
+	}
+
+	expected := ctx.GetSessionString(Session_CSRFToken)
+	if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+		ctx.Next()
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusForbidden)
+	ctx.WriteString(ctx.T("invalid csrf token"))
+	xlog.Warnf("invalid csrf token from '%s' to '%s'", ctx.GetRealIP(), ctx.GetRouteKey())
+}
@@ -0,0 +1,218 @@
+package host
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	Header_ETag              = "ETag"
+	Header_LastModified      = "Last-Modified"
+	Header_IfMatch           = "If-Match"
+	Header_IfNoneMatch       = "If-None-Match"
+	Header_IfModifiedSince   = "If-Modified-Since"
+	Header_IfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+type (
+	// ETagOptions configures the conditional request middleware
+	ETagOptions struct {
+		Weak            bool     // Generate weak etags, not allowed with IfMatchRequired or Current as If-Match is compared strongly
+		Routes          []string // RouteKeys using the middleware, 'area_controller_' and 'area__' apply to the whole controller/area, empty means all
+		IfMatchRequired bool     // Reject PUT/PATCH/DELETE without If-Match or If-Unmodified-Since by 428
+		// Current returns the etag (formatted by FormatETag) and update time of the resource of a PUT/PATCH/DELETE,
+		// the middleware checks If-Match/If-Unmodified-Since against them and responds 412 before the handler runs.
+		// If it's nil, preconditions are left to handlers calling CheckPreconditions
+		Current func(ctx IHttpContext) (etag string, lastModified time.Time)
+	}
+
+	// ETag is the conditional request middleware. For GET/HEAD it generates an etag over the body of 200 responses,
+	// unless the handler has set one by SetETag, then answers If-None-Match/If-Modified-Since by 304.
+	// For PUT/PATCH/DELETE it only evaluates If-Match when Current is set, as only the app knows the current version
+	ETag struct {
+		options *ETagOptions
+		routes  map[string]bool
+	}
+)
+
+// NewETag creates the middleware, options can be nil
+func NewETag(options *ETagOptions) *ETag {
+	r, err := NewETagE(options)
+	xerr.FatalIfErr(err)
+	return r
+}
+
+// NewETagE creates the middleware, options can be nil. Weak etags are rejected together with Current or IfMatchRequired,
+// as If-Match is compared strongly and never matches them
+func NewETagE(options *ETagOptions) (*ETag, error) {
+	if options == nil {
+		options = new(ETagOptions)
+	}
+	if options.Weak && (options.Current != nil || options.IfMatchRequired) {
+		return nil, &ConfigError{Key: "ETag.Weak", Message: "weak etags cannot be used with Current or IfMatchRequired, If-Match never matches them"}
+	}
+	r := &ETag{
+		options: options,
+		routes:  make(map[string]bool, len(options.Routes)),
+	}
+	for _, routeKey := range options.Routes {
+		r.routes[routeKey] = true
+	}
+	return r, nil
+}
+
+func (x *ETag) Handler(ctx IHttpContext) {
+	if !x.matchRoute(ctx.GetRouteKey()) {
+		ctx.Next()
+		return
+	}
+
+	switch ctx.RequestMethod() {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if x.options.IfMatchRequired && ctx.GetHeader(Header_IfMatch) == "" && ctx.GetHeader(Header_IfUnmodifiedSince) == "" {
+			ctx.SetStatusCode(http.StatusPreconditionRequired)
+			ctx.WriteString(ctx.T("If-Match header is required"))
+			return
+		}
+		if x.options.Current != nil {
+			if etag, lastModified := x.options.Current(ctx); !CheckPreconditions(ctx, etag, lastModified) {
+				return
+			}
+		}
+		ctx.Next()
+		return
+	default:
+		ctx.Next()
+		return
+	}
+
+	ctx.Next()
+
+	if ctx.GetStatusCode() != http.StatusOK {
+		return
+	}
+
+	etag := ctx.GetResponseHeader(Header_ETag)
+	if etag == "" {
+		hash := sha256.Sum256(ctx.GetResponseBody())
+		etag = FormatETag(hex.EncodeToString(hash[:16]), x.options.Weak)
+		ctx.SetHeader(Header_ETag, etag)
+	}
+
+	if isNotModified(ctx, etag) {
+		ctx.SetStatusCode(http.StatusNotModified) // Body is dropped by the server for 304
+	}
+}
+
+func (x *ETag) matchRoute(routeKey string) bool {
+	if len(x.routes) == 0 {
+		return true
+	}
+	if x.routes[routeKey] {
+		return true
+	}
+	area, controller, _ := GetRoutesByKey(routeKey)
+	return x.routes[area+Seperator_Route+controller+Seperator_Route] || x.routes[area+Seperator_Route+Seperator_Route]
+}
+
+// FormatETag quotes version as an etag, e.g. '"v1"' or 'W/"v1"'
+func FormatETag(version string, weak bool) string {
+	if weak {
+		return `W/"` + version + `"`
+	}
+	return `"` + version + `"`
+}
+
+// SetETag sets etag of the response by a version of the resource, e.g. its revision or update time,
+// the middleware won't hash the body then
+func SetETag(ctx IHttpContext, version string, weak bool) {
+	ctx.SetHeader(Header_ETag, FormatETag(version, weak))
+}
+
+// SetLastModified sets Last-Modified of the response, which is compared with If-Modified-Since
+func SetLastModified(ctx IHttpContext, t time.Time) {
+	ctx.SetHeader(Header_LastModified, t.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions evaluates If-Match and If-Unmodified-Since of an update against the current etag
+// (as given to SetETag, formatted by FormatETag) and update time of the resource. It responds 412 and returns false if they fail,
+// call it before updating. Empty etag or zero lastModified skips the related check
+func CheckPreconditions(ctx IHttpContext, etag string, lastModified time.Time) bool {
+	if ifMatch := ctx.GetHeader(Header_IfMatch); ifMatch != "" {
+		if !matchETag(ifMatch, etag, false) {
+			ctx.SetStatusCode(http.StatusPreconditionFailed)
+			ctx.WriteString(ctx.T("resource has been modified"))
+			return false
+		}
+		return true // If-Unmodified-Since is ignored when If-Match is present
+	}
+
+	if since := ctx.GetHeader(Header_IfUnmodifiedSince); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && lastModified.Truncate(time.Second).After(t) {
+			ctx.SetStatusCode(http.StatusPreconditionFailed)
+			ctx.WriteString(ctx.T("resource has been modified"))
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNotModified evaluates If-None-Match, or If-Modified-Since if there is no If-None-Match
+func isNotModified(ctx IHttpContext, etag string) bool {
+	if ifNoneMatch := ctx.GetHeader(Header_IfNoneMatch); ifNoneMatch != "" {
+		return matchETag(ifNoneMatch, etag, true)
+	}
+
+	since := ctx.GetHeader(Header_IfModifiedSince)
+	lastModified := ctx.GetResponseHeader(Header_LastModified)
+	if since == "" || lastModified == "" {
+		return false
+	}
+	sinceTime, err := http.ParseTime(since)
+	if err != nil {
+		return false
+	}
+	lastModifiedTime, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !lastModifiedTime.After(sinceTime)
+}
+
+// matchETag checks etag against a header value like '"a", W/"b"' or '*'.
+// Weak comparison ignores the 'W/' prefix, strong comparison never matches weak etags
+func matchETag(header, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
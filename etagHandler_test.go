@@ -0,0 +1,113 @@
+package host_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestETagHandler(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := host.NewETag(nil)
+	get := func(setup func(*hosttest.Context)) *hosttest.Context {
+		ctx := hosttest.NewContext(http.MethodGet, "/api/orders/1", etag.Handler, func(ctx host.IHttpContext) {
+			host.SetLastModified(ctx, modified)
+			ctx.WriteString("order 1")
+		})
+		setup(ctx)
+		return ctx.Run()
+	}
+
+	ctx := get(func(ctx *hosttest.Context) {})
+	tag := ctx.ResponseHeaders.Get(host.Header_ETag)
+	if ctx.StatusCode != http.StatusOK || tag == "" {
+		t.Fatalf("status %d, etag '%s', expected a generated etag", ctx.StatusCode, tag)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"etag matched", host.Header_IfNoneMatch, tag, http.StatusNotModified},
+		{"weak etag matched", host.Header_IfNoneMatch, `"x", W/` + tag, http.StatusNotModified},
+		{"etag changed", host.Header_IfNoneMatch, `"x"`, http.StatusOK},
+		{"not modified since", host.Header_IfModifiedSince, modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"modified since", host.Header_IfModifiedSince, modified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if ctx := get(func(ctx *hosttest.Context) { ctx.Headers.Set(tt.header, tt.value) }); ctx.StatusCode != tt.status {
+			t.Errorf("%s: status %d, expected %d", tt.name, ctx.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestETagHandlerPreconditions(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	current := host.FormatETag("v2", false)
+	strict := host.NewETag(&host.ETagOptions{
+		IfMatchRequired: true,
+		Current: func(ctx host.IHttpContext) (string, time.Time) {
+			return current, modified
+		},
+	})
+	loose := host.NewETag(&host.ETagOptions{Routes: []string{"api_orders_"}})
+
+	tests := []struct {
+		name    string
+		etag    *host.ETag
+		method  string
+		header  string
+		value   string
+		status  int
+		handled bool
+	}{
+		{"if-match required", strict, http.MethodPut, "", "", http.StatusPreconditionRequired, false},
+		{"if-match required by delete", strict, http.MethodDelete, "", "", http.StatusPreconditionRequired, false},
+		{"if-match mismatched", strict, http.MethodPatch, host.Header_IfMatch, `"v1"`, http.StatusPreconditionFailed, false},
+		{"if-match mismatched by delete", strict, http.MethodDelete, host.Header_IfMatch, `"v1"`, http.StatusPreconditionFailed, false},
+		{"if-match matched", strict, http.MethodPut, host.Header_IfMatch, `"v1", "v2"`, http.StatusOK, true},
+		{"if-match any", strict, http.MethodPut, host.Header_IfMatch, `*`, http.StatusOK, true},
+		{"weak if-match", strict, http.MethodPut, host.Header_IfMatch, `W/"v2"`, http.StatusPreconditionFailed, false},
+		{"unmodified since", strict, http.MethodPut, host.Header_IfUnmodifiedSince, modified.Format(http.TimeFormat), http.StatusOK, true},
+		{"modified since", strict, http.MethodPut, host.Header_IfUnmodifiedSince, modified.Add(-time.Second).Format(http.TimeFormat), http.StatusPreconditionFailed, false},
+		{"left to handlers without current", loose, http.MethodPut, host.Header_IfMatch, `"v1"`, http.StatusOK, true},
+		{"post is not checked", strict, http.MethodPost, "", "", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		handled := false
+		ctx := hosttest.NewContext(tt.method, "/api/orders/1", tt.etag.Handler, func(ctx host.IHttpContext) {
+			handled = true
+		})
+		ctx.SetItem(host.Ctx_RouteKey, "api_orders_update")
+		if tt.header != "" {
+			ctx.Headers.Set(tt.header, tt.value)
+		}
+		ctx.Run()
+		if ctx.StatusCode != tt.status || handled != tt.handled {
+			t.Errorf("%s: status %d, handled %v, expected %d, %v", tt.name, ctx.StatusCode, handled, tt.status, tt.handled)
+		}
+	}
+}
+
+func TestNewETagRejectsWeakIfMatch(t *testing.T) {
+	current := func(ctx host.IHttpContext) (string, time.Time) { return "", time.Time{} }
+	tests := []struct {
+		options *host.ETagOptions
+		valid   bool
+	}{
+		{&host.ETagOptions{Weak: true}, true},
+		{&host.ETagOptions{IfMatchRequired: true, Current: current}, true},
+		{&host.ETagOptions{Weak: true, Current: current}, false},
+		{&host.ETagOptions{Weak: true, IfMatchRequired: true}, false},
+	}
+	for i, tt := range tests {
+		if _, err := host.NewETagE(tt.options); (err == nil) != tt.valid {
+			t.Errorf("options %d: %v, expected valid %v", i, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package host
+
+import "testing"
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		header, etag string
+		weak         bool
+		expected     bool
+	}{
+		{`"a"`, `"a"`, false, true},
+		{`"b", "a"`, `"a"`, false, true},
+		{`W/"a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, false},
+		{`"a"`, `W/"a"`, true, true},
+		{`"a"`, `W/"a"`, false, false},
+		{`*`, `"a"`, false, true},
+		{`*`, ``, false, false},
+		{`"b"`, `"a"`, true, false},
+	}
+	for _, tt := range tests {
+		if actual := matchETag(tt.header, tt.etag, tt.weak); actual != tt.expected {
+			t.Errorf("matchETag(%s, %s, %v) = %v, expected %v", tt.header, tt.etag, tt.weak, actual, tt.expected)
+		}
+	}
+}
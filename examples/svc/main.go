@@ -13,10 +13,10 @@ import (
 func main() {
 	cp := xconfig.NewJsonConfigProvider()
 
-	hconsul.RegisterServiceInfo(cp)
-
 	host := hgrpc.NewGRPCServiceHost(cp)
 
+	hconsul.RegisterServiceOnReady(host.GetLifecycle(), cp)
+
 	shared.RegisterTestServiceServer(host.GetGRPCServer(), &TestService{})
 
 	xerr.FatalIfErr(host.Run())
@@ -61,7 +61,7 @@ func (x *OAuthClientHandler) SignInCallbackHandler(ctx host.IHttpContext) {
 	state := ctx.GetFormString(oauth2core.Form_State)
 	redirectUrl := ctx.GetSessionString(state)
 	if redirectUrl == "" {
-		ctx.WriteString("invalid state")
+		ctx.WriteString(ctx.T("invalid state"))
 		ctx.SetStatusCode(http.StatusBadRequest)
 		return
 	}
@@ -141,6 +141,13 @@ func (x *OAuthClientHandler) SignInCallbackHandler(ctx host.IHttpContext) {
 	// Convert string to token object
 	jwtToken, err := jwt.ParseWithoutCheck(xbytes.StrToBytes(oauth2Token.AccessToken))
 	if err == nil {
+		// Issue a new session id for the signed in user to prevent session fixation
+		if err = ctx.RegenerateSession(); xerr.LogError(err) {
+			ctx.WriteString(err.Error())
+			ctx.SetStatusCode(http.StatusInternalServerError)
+			return
+		}
+
 		userStr := xbytes.BytesToStr(jwtToken.Raw)
 		ctx.SetSession(x.UserJsonSessionkey, userStr)
 		if jwtToken.Subject != "" {
@@ -182,14 +189,14 @@ func (x *OAuthClientHandler) SignOutCallbackHandler(ctx host.IHttpContext) {
 	state := ctx.GetFormString(oauth2core.Form_State)
 	returnURL := ctx.GetSessionString(state)
 	if returnURL == "" {
-		ctx.WriteString("invalid state")
+		ctx.WriteString(ctx.T("invalid state"))
 		ctx.SetStatusCode(http.StatusBadRequest)
 		return
 	}
 
 	endSessionID := ctx.GetFormString(oauth2core.Form_EndSessionID)
 	if endSessionID == "" {
-		ctx.WriteString("missing es_id")
+		ctx.WriteString(ctx.T("missing es_id"))
 		ctx.SetStatusCode(http.StatusBadRequest)
 		return
 	}
@@ -3,12 +3,10 @@ package hclient
 import (
 	"context"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/DreamvatLab/go/xerr"
-	"github.com/DreamvatLab/go/xlog"
 	"github.com/DreamvatLab/go/xsecurity"
 	"github.com/DreamvatLab/host"
 	"github.com/muesli/cache2go"
@@ -48,12 +46,24 @@ type OAuthClientHost struct {
 }
 
 func (x *OAuthClientHost) BuildOAuthClientHost() {
-	x.BaseHost.BuildBaseHost()
+	xerr.FatalIfErr(x.BuildOAuthClientHostE())
+}
+
+func (x *OAuthClientHost) BuildOAuthClientHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BaseHost.BuildBaseHostE())
 
 	if x.OAuthOptions == nil {
-		xlog.Fatal("OAuth secion in configuration is missing")
+		errs.Add("OAuth", "OAuth section in configuration is missing")
+	} else {
+		errs.Append("OAuth", x.OAuthOptions.BuildOAuthOptionsE(x.URLProvider))
+	}
+	if x.CookieEncryptor == nil {
+		errs.Append("", x.SecureCookieHost.BuildSecureCookieHostE())
+	}
+	if len(errs) > 0 {
+		return errs
 	}
-	x.OAuthOptions.BuildOAuthOptions(x.URLProvider)
 
 	if x.SignInPath == "" {
 		x.SignInPath = "/signin"
@@ -87,7 +97,6 @@ func (x *OAuthClientHost) BuildOAuthClientHost() {
 
 	////////// CookieEncryptor
 	if x.CookieEncryptor == nil {
-		x.SecureCookieHost.BuildSecureCookieHost()
 		x.CookieEncryptor = x.GetCookieEncryptor()
 	}
 
@@ -105,6 +114,8 @@ func (x *OAuthClientHost) BuildOAuthClientHost() {
 	// if x.authMiddleware == nil {
 	// 	x.authMiddleware = newClientAuthMiddleware(x.UserJsonSessionKey, x.AccessDeniedPath, x.OAuthOptions, x.PermissionAuditor)
 	// }
+
+	return nil
 }
 
 func (x *OAuthClientHost) GetHttpClient() *http.Client {
@@ -206,31 +217,15 @@ func (x *OAuthClientHost) AuthHandler(ctx host.IHttpContext) {
 	routeKey := ctx.GetItemString(host.Ctx_RouteKey)
 	if routeKey == "" {
 		ctx.SetStatusCode(500)
-		ctx.WriteString("route key does not exist")
+		ctx.WriteString(ctx.T("route key does not exist"))
 		return
 	}
 
-	routes := strings.Split(routeKey, "_")
-
-	var area, controller, action string
-	count := len(routes)
-	if count == 0 || count > 3 {
-		xlog.Fatal("invalid routes array")
-	}
-
-	area = routes[0]
-	if count >= 2 {
-		controller = routes[1]
-	}
-	if count == 3 {
-		action = routes[2]
-	}
-
 	user := host.GetUser(ctx, x.UserJsonSessionKey)
 
 	// Check if request is allowed to access
 	if user != nil {
-		if x.PermissionAuditor.CheckRouteWithLevel(area, controller, action, user.Role, user.Level, user.Scopes) {
+		if host.CheckActionPermission(ctx, x.PermissionAuditor, user.Role, user.Level, user.Scopes) {
 			// Has permission
 			ctx.Next()
 			return
@@ -242,7 +237,7 @@ func (x *OAuthClientHost) AuthHandler(ctx host.IHttpContext) {
 	}
 
 	// Not logged in
-	allow := x.PermissionAuditor.CheckRouteWithLevel(area, controller, action, 0, 0, make([]string, 0))
+	allow := host.CheckActionPermission(ctx, x.PermissionAuditor, 0, 0, make([]string, 0))
 	if allow {
 		// Allow anonymous
 		ctx.Next()
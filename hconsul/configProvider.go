@@ -0,0 +1,265 @@
+package hconsul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/host"
+	"github.com/hashicorp/consul/api"
+	"github.com/jpillora/backoff"
+)
+
+var (
+	_ host.IConfigWatcher = (*KVConfigProvider)(nil)
+	_ host.IConfigSchema  = (*KVConfigProvider)(nil)
+)
+
+type (
+	// KVConfigProvider overlays consul KV under a prefix on a base provider (usually the json provider) and reloads it by blocking queries.
+	// The value of the prefix itself is a json document merged on the root, keys under it map to paths, e.g.
+	//   config/api            {"Log":{"Level":"info"}}
+	//   config/api/Log/Level  debug
+	// Values of keys are parsed as json if possible, strings otherwise.
+	// Hosts apply changes of 'Log' and 'CORS' at runtime, other keys are read once when hosts are built,
+	// register OnChange callbacks for keys the app reads by itself.
+	// Reads are recorded by the base provider, so '--config-schema' of a LayeredConfigProvider base works through it
+	KVConfigProvider struct {
+		kv      *api.KV
+		prefix  string
+		wait    time.Duration
+		lock    sync.RWMutex
+		current *xconfig.JsonConfigProvider
+		cancel  context.CancelFunc
+		done    chan struct{}
+		host.ConfigChangeNotifier
+		host.ConfigSchemaForwarder
+	}
+)
+
+// NewKVConfigProvider loads KV under prefix and watches it, waitSeconds is the blocking query wait, default 300
+func NewKVConfigProvider(base xconfig.IConfigProvider, consulConfig *ConsulConfig, prefix string, waitSeconds int) (*KVConfigProvider, error) {
+	if consulConfig == nil {
+		return nil, xerr.New("consul config cannot be nil")
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return nil, xerr.New("consul KV prefix cannot be empty")
+	}
+	if waitSeconds <= 0 {
+		waitSeconds = 300
+	}
+
+	config := api.DefaultConfig()
+	config.Address = consulConfig.Addr
+	config.Token = consulConfig.Token
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	r := &KVConfigProvider{
+		kv:                    client.KV(),
+		prefix:                prefix,
+		wait:                  time.Duration(waitSeconds) * time.Second,
+		done:                  make(chan struct{}),
+		ConfigSchemaForwarder: host.ConfigSchemaForwarder{Base: base},
+	}
+
+	pairs, meta, err := r.kv.List(prefix, nil)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	if r.current, err = r.build(pairs); err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	go r.watch(ctx, meta.LastIndex)
+	return r, nil
+}
+
+// Close stops watching
+func (x *KVConfigProvider) Close() {
+	x.cancel()
+	<-x.done
+}
+
+func (x *KVConfigProvider) watch(ctx context.Context, index uint64) {
+	defer close(x.done)
+	bck := &backoff.Backoff{
+		Factor: 2,
+		Jitter: true,
+		Min:    time.Second,
+		Max:    time.Minute,
+	}
+
+	for {
+		pairs, meta, err := x.kv.List(x.prefix, (&api.QueryOptions{WaitIndex: index, WaitTime: x.wait}).WithContext(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			xlog.Errorf("failed to watch consul KV '%s': %v", x.prefix, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(bck.Duration()):
+			}
+			continue
+		}
+		bck.Reset()
+
+		if meta.LastIndex == index {
+			continue // Wait timed out without changes
+		}
+		if meta.LastIndex < index {
+			index = 0 // Index went backwards, e.g. consul restored from snapshot, start over
+		} else {
+			index = meta.LastIndex
+		}
+
+		if err := x.reload(pairs); err != nil {
+			xlog.Errorf("failed to reload consul KV '%s', current config is kept: %+v", x.prefix, err)
+		}
+	}
+}
+
+func (x *KVConfigProvider) reload(pairs api.KVPairs) error {
+	current, err := x.build(pairs)
+	if err != nil {
+		return err
+	}
+
+	x.lock.Lock()
+	previous := x.current
+	x.current = current
+	x.lock.Unlock()
+
+	if bytes.Equal(previous.RawJson, current.RawJson) {
+		return nil
+	}
+	xlog.Infof("consul KV '%s' reloaded", x.prefix)
+	x.Notify(x, previous.RawJson, current.RawJson)
+	return nil
+}
+
+// build merges KV pairs on the base config
+func (x *KVConfigProvider) build(pairs api.KVPairs) (*xconfig.JsonConfigProvider, error) {
+	root := make(map[string]interface{})
+	if x.Base != nil {
+		if err := x.Base.GetStruct("@this", &root); err != nil {
+			return nil, err
+		}
+	}
+
+	// The prefix document first, so that keys under the prefix win
+	for _, pair := range pairs {
+		if strings.Trim(pair.Key, "/") != x.prefix || len(pair.Value) == 0 {
+			continue
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(pair.Value, &doc); err != nil {
+			return nil, xerr.WithMessage(err, "invalid json of consul key '"+pair.Key+"'")
+		}
+		host.MergeConfig(root, doc)
+	}
+	for _, pair := range pairs {
+		path, ok := strings.CutPrefix(pair.Key, x.prefix+"/")
+		if !ok || path == "" || strings.HasSuffix(path, "/") {
+			continue // The prefix document or folders
+		}
+		var value interface{}
+		if err := json.Unmarshal(pair.Value, &value); err != nil {
+			value = string(pair.Value)
+		}
+		setPath(root, strings.Split(path, "/"), value)
+	}
+
+	raw, err := json.Marshal(root)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	r := &xconfig.JsonConfigProvider{
+		RawJson:          raw,
+		MapConfiguration: xconfig.MapConfiguration(root),
+	}
+	return r, nil
+}
+
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, k := range path[:len(path)-1] {
+		child, ok := m[k].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[k] = child
+		}
+		m = child
+	}
+	last := path[len(path)-1]
+	if src, ok := value.(map[string]interface{}); ok {
+		if dst, ok := m[last].(map[string]interface{}); ok {
+			host.MergeConfig(dst, src)
+			return
+		}
+	}
+	m[last] = value
+}
+
+func (x *KVConfigProvider) get() *xconfig.JsonConfigProvider {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.current
+}
+
+func (x *KVConfigProvider) GetStruct(key string, target interface{}) error {
+	x.RecordRead(key, target)
+	return x.get().GetStruct(key, target)
+}
+
+func (x *KVConfigProvider) GetString(key string) string {
+	x.RecordRead(key, "")
+	return x.get().GetString(key)
+}
+
+func (x *KVConfigProvider) GetStringDefault(key string, defaultValue string) string {
+	x.RecordRead(key, "")
+	return x.get().GetStringDefault(key, defaultValue)
+}
+
+func (x *KVConfigProvider) GetBool(key string) bool {
+	x.RecordRead(key, false)
+	return x.get().GetBool(key)
+}
+
+func (x *KVConfigProvider) GetFloat64(key string) float64 {
+	x.RecordRead(key, float64(0))
+	return x.get().GetFloat64(key)
+}
+
+func (x *KVConfigProvider) GetInt(key string) int {
+	x.RecordRead(key, 0)
+	return x.get().GetInt(key)
+}
+
+func (x *KVConfigProvider) GetIntDefault(key string, defaultValue int) int {
+	x.RecordRead(key, 0)
+	return x.get().GetIntDefault(key, defaultValue)
+}
+
+func (x *KVConfigProvider) GetStringSlice(key string) []string {
+	x.RecordRead(key, []string(nil))
+	return x.get().GetStringSlice(key)
+}
+
+func (x *KVConfigProvider) GetIntSlice(key string) []int {
+	x.RecordRead(key, []int(nil))
+	return x.get().GetIntSlice(key)
+}
@@ -0,0 +1,143 @@
+package hconsul
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/host"
+)
+
+// fakeKV serves consul KV list requests, blocking queries wait until the index changes
+type fakeKV struct {
+	lock  sync.Mutex
+	index uint64
+	pairs map[string]string
+}
+
+func (x *fakeKV) set(key, value string) {
+	x.lock.Lock()
+	x.pairs[key] = value
+	x.index++
+	x.lock.Unlock()
+}
+
+func (x *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	var waitIndex uint64
+	fmt.Sscan(r.URL.Query().Get("index"), &waitIndex)
+
+	x.lock.Lock()
+	deadline := time.Now().Add(2 * time.Second)
+	for x.index == waitIndex && time.Now().Before(deadline) && r.Context().Err() == nil {
+		x.lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		x.lock.Lock()
+	}
+	var items []string
+	for k, v := range x.pairs {
+		if strings.HasPrefix(k, prefix) {
+			items = append(items, fmt.Sprintf(`{"Key":%q,"Value":%q}`, k, base64.StdEncoding.EncodeToString([]byte(v))))
+		}
+	}
+	index := x.index
+	x.lock.Unlock()
+
+	w.Header().Set("X-Consul-Index", fmt.Sprint(index))
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "["+strings.Join(items, ",")+"]")
+}
+
+func TestKVConfigProvider(t *testing.T) {
+	kv := &fakeKV{index: 1, pairs: map[string]string{
+		"config/api":           `{"CORS":{"AllowedOrigin":"*"},"Log":{"Level":"info"}}`,
+		"config/api/Log/Level": "warn",
+		"config/api/Timeout":   "30",
+	}}
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	base := &xconfig.JsonConfigProvider{RawJson: []byte(`{"ListenAddr":":80","Log":{"Level":"debug","TraceLevel":"error"}}`)}
+	base.MapConfiguration = xconfig.MapConfiguration{}
+
+	cp, err := NewKVConfigProvider(base, &ConsulConfig{Addr: strings.TrimPrefix(server.URL, "http://")}, "config/api", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	if v := cp.GetString("ListenAddr"); v != ":80" {
+		t.Errorf("ListenAddr = %s, expected base value", v)
+	}
+	if v := cp.GetString("Log.Level"); v != "warn" {
+		t.Errorf("Log.Level = %s, expected key under prefix to win", v)
+	}
+	if v := cp.GetString("Log.TraceLevel"); v != "error" {
+		t.Errorf("Log.TraceLevel = %s, expected merged base value", v)
+	}
+	if v := cp.GetInt("Timeout"); v != 30 {
+		t.Errorf("Timeout = %d, expected 30", v)
+	}
+
+	logChanged := make(chan string, 1)
+	corsChanged := make(chan string, 1)
+	cp.OnChange("Log", func(cp xconfig.IConfigProvider) {
+		logChanged <- cp.GetString("Log.Level")
+	})
+	cp.OnChange("CORS", func(cp xconfig.IConfigProvider) {
+		corsChanged <- cp.GetString("CORS.AllowedOrigin")
+	})
+
+	kv.set("config/api/Log/Level", "error")
+	select {
+	case level := <-logChanged:
+		if level != "error" {
+			t.Errorf("Log.Level = %s after change, expected error", level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change callback was not fired")
+	}
+	select {
+	case <-corsChanged:
+		t.Error("CORS callback fired without CORS changes")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestKVConfigProviderSchema(t *testing.T) {
+	kv := &fakeKV{index: 1, pairs: map[string]string{"config/api": `{"Log":{"Level":"info"}}`}}
+	server := httptest.NewServer(kv)
+	defer server.Close()
+
+	file := fp.Join(t.TempDir(), "configs.json")
+	os.WriteFile(file, []byte(`{"ListenAddr":":80"}`), 0644)
+	base, err := host.NewLayeredConfigProvider(&host.LayeredConfigOptions{File: file, Args: []string{"--config-schema"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := NewKVConfigProvider(base, &ConsulConfig{Addr: strings.TrimPrefix(server.URL, "http://")}, "config/api", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	if !cp.SchemaRequested() {
+		t.Error("schema request of the base provider is not forwarded")
+	}
+	cp.GetString("Log.Level")
+	var schema strings.Builder
+	if err := cp.DumpSchema(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(schema.String(), "Log.Level") {
+		t.Errorf("schema does not contain Log.Level read through the provider:\n%s", schema.String())
+	}
+}
@@ -1,14 +1,22 @@
 package hconsul
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/DreamvatLab/go/xconfig"
 	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
 	"github.com/hashicorp/consul/api"
 )
 
 func RegisterServiceInfo(cp xconfig.IConfigProvider) {
+	_, err := RegisterServiceInfoE(cp)
+	xerr.FatalIfErr(err)
+}
+
+// RegisterServiceInfoE registers the service in consul and returns a function which deregisters it
+func RegisterServiceInfoE(cp xconfig.IConfigProvider) (deregister func() error, err error) {
 	// Read configuration
 	consulAddr := cp.GetString("Consul.Addr")
 	consulToken := cp.GetString("Consul.Token")
@@ -24,7 +32,9 @@ func RegisterServiceInfo(cp xconfig.IConfigProvider) {
 	consulConfig.Address = consulAddr
 	consulConfig.Token = consulToken
 	consulClient, err := api.NewClient(consulConfig)
-	xerr.FatalIfErr(err)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
 	consulAgent := consulClient.Agent()
 
 	// Register service in service center
@@ -40,5 +50,28 @@ func RegisterServiceInfo(cp xconfig.IConfigProvider) {
 			DeregisterCriticalServiceAfter: serviceCheckTimeout, // Deregistration time, equivalent to expiration time
 		},
 	})
-	xerr.FatalIfErr(err)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	deregister = func() error {
+		return xerr.WithStack(consulAgent.ServiceDeregister(serviceID))
+	}
+	return deregister, nil
+}
+
+// RegisterServiceOnReady registers the service once the host is ready and deregisters it before the host stops serving
+func RegisterServiceOnReady(lifecycle *host.Lifecycle, cp xconfig.IConfigProvider) {
+	var deregister func() error
+	lifecycle.OnReady("consul", func(ctx context.Context) error {
+		var err error
+		deregister, err = RegisterServiceInfoE(cp)
+		return err
+	})
+	lifecycle.OnStopping("consul", func(ctx context.Context) error {
+		if deregister == nil {
+			return nil
+		}
+		return deregister()
+	})
 }
@@ -1,7 +1,11 @@
 package hfasthttp
 
 import (
+	"net/http"
+
 	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
 	"github.com/DreamvatLab/host/hclient"
 )
 
@@ -13,27 +17,68 @@ type FHOAuthClientHost struct {
 }
 
 func NewFHOAuthClientHost(cp xconfig.IConfigProvider, options ...ClientHostOption) hclient.IOAuthClientHost {
+	x, err := NewFHOAuthClientHostE(cp, options...)
+	xerr.FatalIfErr(err)
+	return x
+}
+
+// NewFHOAuthClientHostE creates the host, errors of all bad config keys are returned as host.ConfigErrors
+func NewFHOAuthClientHostE(cp xconfig.IConfigProvider, options ...ClientHostOption) (hclient.IOAuthClientHost, error) {
 	x := new(FHOAuthClientHost)
-	cp.GetStruct("@this", &x)
+	var errs host.ConfigErrors
+	errs.Append("", cp.GetStruct("@this", &x))
 	x.ConfigProvider = cp
 
 	for _, o := range options {
 		o(x)
 	}
 
-	x.BuildFHOAuthClientHost()
+	errs.Append("", x.BuildFHOAuthClientHostE())
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	return x
+	return x, nil
 }
 
 func (x *FHOAuthClientHost) BuildFHOAuthClientHost() {
-	x.BuildOAuthClientHost()
+	xerr.FatalIfErr(x.BuildFHOAuthClientHostE())
+}
+
+func (x *FHOAuthClientHost) BuildFHOAuthClientHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BuildOAuthClientHostE())
 	x.FHWebHost.CookieEncryptor = x.SecureCookieHost.GetCookieEncryptor()
-	x.FHWebHost.buildFHWebHost()
+	if x.FHWebHost.WebRedisConfig == nil {
+		x.FHWebHost.WebRedisConfig = x.RedisConfig
+	}
+	x.FHWebHost.lifecycle = x.OAuthClientHost.GetLifecycle()
+	x.FHWebHost.configProvider = x.OAuthClientHost.ConfigProvider
+	x.FHWebHost.routeProvider = x.RouteProvider
+	x.FHWebHost.permissionProvider = x.PermissionProvider
+	x.FHWebHost.permissionAuditor = x.PermissionAuditor
+	x.FHWebHost.urlProvider = x.URLProvider
+	if x.FHWebHost.Views != nil {
+		x.FHWebHost.Views.Reload = x.FHWebHost.Views.Reload || x.Debug
+		if x.FHWebHost.Views.UserJsonSessionKey == "" {
+			x.FHWebHost.Views.UserJsonSessionKey = x.UserJsonSessionKey
+		}
+	}
+	errs.Append("", x.FHWebHost.buildFHWebHost())
+	if len(errs) > 0 {
+		return errs
+	}
 
 	////////// oauth client endpoints
 	x.Router.GET(x.SignInPath, x.FHWebHost.BuildNativeHandler(x.SignInPath, x.OAuthClientHandler.SignInHandler))
 	x.Router.GET(x.SignInCallbackPath, x.FHWebHost.BuildNativeHandler(x.SignInPath, x.OAuthClientHandler.SignInCallbackHandler))
 	x.Router.GET(x.SignOutPath, x.FHWebHost.BuildNativeHandler(x.SignInPath, x.OAuthClientHandler.SignOutHandler))
 	x.Router.GET(x.SignOutCallbackPath, x.FHWebHost.BuildNativeHandler(x.SignInPath, x.OAuthClientHandler.SignOutCallbackHandler))
+
+	x.AddRouteInfo(http.MethodGet, x.SignInPath, x.SignInPath, x.OAuthClientHandler.SignInHandler)
+	x.AddRouteInfo(http.MethodGet, x.SignInCallbackPath, x.SignInPath, x.OAuthClientHandler.SignInCallbackHandler)
+	x.AddRouteInfo(http.MethodGet, x.SignOutPath, x.SignInPath, x.OAuthClientHandler.SignOutHandler)
+	x.AddRouteInfo(http.MethodGet, x.SignOutCallbackPath, x.SignInPath, x.OAuthClientHandler.SignOutCallbackHandler)
+
+	return nil
 }
@@ -2,6 +2,8 @@ package hfasthttp
 
 import (
 	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
 	"github.com/DreamvatLab/host/hresource"
 )
 
@@ -13,23 +15,60 @@ type FHOAuthResourceHost struct {
 }
 
 func NewFHOAuthResourceHost(cp xconfig.IConfigProvider, options ...ResourceHostOption) hresource.IOAuthResourceHost {
+	r, err := NewFHOAuthResourceHostE(cp, options...)
+	xerr.FatalIfErr(err)
+	return r
+}
+
+// NewFHOAuthResourceHostE creates the host, errors of all bad config keys are returned as host.ConfigErrors
+func NewFHOAuthResourceHostE(cp xconfig.IConfigProvider, options ...ResourceHostOption) (hresource.IOAuthResourceHost, error) {
 	r := new(FHOAuthResourceHost)
 	// r.OAuthResourceHost = new(resource.OAuthResourceHost)
 	// r.OAuthResourceHost.BaseHost = new(host.BaseHost)
 	// r.FHWebHost = new(FHWebHost)
-	cp.GetStruct("@this", &r)
+	var errs host.ConfigErrors
+	errs.Append("", cp.GetStruct("@this", &r))
 	r.ConfigProvider = cp
 
 	for _, o := range options {
 		o(r)
 	}
 
-	r.BuildFHOAuthResourceHost()
+	errs.Append("", r.BuildFHOAuthResourceHostE())
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	return r
+	return r, nil
 }
 
 func (x *FHOAuthResourceHost) BuildFHOAuthResourceHost() {
-	x.BuildOAuthResourceHost()
-	x.FHWebHost.buildFHWebHost()
+	xerr.FatalIfErr(x.BuildFHOAuthResourceHostE())
+}
+
+func (x *FHOAuthResourceHost) BuildFHOAuthResourceHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BuildOAuthResourceHostE())
+	if x.FHWebHost.WebRedisConfig == nil {
+		x.FHWebHost.WebRedisConfig = x.RedisConfig
+	}
+	x.FHWebHost.lifecycle = x.OAuthResourceHost.GetLifecycle()
+	x.FHWebHost.configProvider = x.OAuthResourceHost.ConfigProvider
+	if x.FHWebHost.Admin != nil && x.FHWebHost.Admin.PublicKey == nil && x.FHWebHost.Admin.PublicKeyPath == "" {
+		// Admin bearer tokens are issued by the same authority as api tokens by default
+		x.FHWebHost.Admin.PublicKey = x.PublicKey
+		if len(x.FHWebHost.Admin.ValidIssuers) == 0 && len(x.FHWebHost.Admin.ValidAudiences) == 0 && x.OAuthOptions != nil {
+			x.FHWebHost.Admin.ValidIssuers = x.OAuthOptions.ValidIssuers
+			x.FHWebHost.Admin.ValidAudiences = x.OAuthOptions.ValidAudiences
+		}
+	}
+	x.FHWebHost.routeProvider = x.RouteProvider
+	x.FHWebHost.permissionProvider = x.PermissionProvider
+	x.FHWebHost.permissionAuditor = x.PermissionAuditor
+	x.FHWebHost.urlProvider = x.URLProvider
+	if x.FHWebHost.Views != nil {
+		x.FHWebHost.Views.Reload = x.FHWebHost.Views.Reload || x.Debug
+	}
+	errs.Append("", x.FHWebHost.buildFHWebHost())
+	return errs.Err()
 }
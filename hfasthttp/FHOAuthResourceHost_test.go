@@ -0,0 +1,32 @@
+package hfasthttp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hfasthttp"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestResourceHostConfigErrors(t *testing.T) {
+	cp, err := hosttest.NewConfigProvider(`{"Log":{"Level":"error"},"SessionStore":"file","PermissionReload":{},"OAuth":{"ValidIssuers":["https://issuer"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = hfasthttp.NewFHOAuthResourceHostE(cp)
+	var errs host.ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	keys := make(map[string]bool)
+	for _, v := range errs {
+		keys[v.Key] = true
+	}
+	for _, key := range []string{"PublicKeyPath", "OAuth.ValidAudiences", "ListenAddr", "SessionStore", "PermissionReload"} {
+		if !keys[key] {
+			t.Errorf("missing error of '%s' in: %v", key, err)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package hfasthttp
 
 import (
+	"net/http"
+
 	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
 	"github.com/DreamvatLab/host/htoken"
 )
 
@@ -13,26 +17,64 @@ type FHOAuthTokenHost struct {
 }
 
 func NewFHOAuthTokenHost(cp xconfig.IConfigProvider, options ...TokenHostOption) htoken.IOAuthTokenHost {
+	r, err := NewFHOAuthTokenHostE(cp, options...)
+	xerr.FatalIfErr(err)
+	return r
+}
+
+// NewFHOAuthTokenHostE creates the host, errors of all bad config keys are returned as host.ConfigErrors
+func NewFHOAuthTokenHostE(cp xconfig.IConfigProvider, options ...TokenHostOption) (htoken.IOAuthTokenHost, error) {
 	r := new(FHOAuthTokenHost)
-	cp.GetStruct("@this", &r)
+	var errs host.ConfigErrors
+	errs.Append("", cp.GetStruct("@this", &r))
 	r.ConfigProvider = cp
 
 	for _, o := range options {
 		o(r)
 	}
 
-	r.BuildFHOAuthTokenHost()
+	errs.Append("", r.BuildFHOAuthTokenHostE())
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	return r
+	return r, nil
 }
 
 func (x *FHOAuthTokenHost) BuildFHOAuthTokenHost() {
-	x.BuildOAuthTokenHost()
+	xerr.FatalIfErr(x.BuildFHOAuthTokenHostE())
+}
+
+func (x *FHOAuthTokenHost) BuildFHOAuthTokenHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BuildOAuthTokenHostE())
 	x.FHWebHost.CookieEncryptor = x.SecureCookieHost.GetCookieEncryptor()
-	x.FHWebHost.buildFHWebHost()
+	if x.FHWebHost.WebRedisConfig == nil {
+		x.FHWebHost.WebRedisConfig = x.RedisConfig
+	}
+	x.FHWebHost.lifecycle = x.OAuthTokenHost.GetLifecycle()
+	x.FHWebHost.configProvider = x.OAuthTokenHost.ConfigProvider
+	x.FHWebHost.routeProvider = x.RouteProvider
+	x.FHWebHost.permissionProvider = x.PermissionProvider
+	x.FHWebHost.permissionAuditor = x.PermissionAuditor
+	x.FHWebHost.urlProvider = x.URLProvider
+	if x.FHWebHost.Views != nil {
+		x.FHWebHost.Views.Reload = x.FHWebHost.Views.Reload || x.Debug
+	}
+	errs.Append("", x.FHWebHost.buildFHWebHost())
+	if len(errs) > 0 {
+		return errs
+	}
 
 	x.Router.POST(x.TokenEndpoint, x.TokenHost.TokenRequestHandler)
 	x.Router.GET(x.AuthorizeEndpoint, x.TokenHost.AuthorizeRequestHandler)
 	x.Router.GET(x.EndSessionEndpoint, x.TokenHost.EndSessionRequestHandler)
 	x.Router.POST(x.EndSessionEndpoint, x.TokenHost.ClearTokenRequestHandler)
+
+	x.AddRouteInfo(http.MethodPost, x.TokenEndpoint, x.TokenEndpoint, x.TokenHost.TokenRequestHandler)
+	x.AddRouteInfo(http.MethodGet, x.AuthorizeEndpoint, x.AuthorizeEndpoint, x.TokenHost.AuthorizeRequestHandler)
+	x.AddRouteInfo(http.MethodGet, x.EndSessionEndpoint, x.EndSessionEndpoint, x.TokenHost.EndSessionRequestHandler)
+	x.AddRouteInfo(http.MethodPost, x.EndSessionEndpoint, x.EndSessionEndpoint, x.TokenHost.ClearTokenRequestHandler)
+
+	return nil
 }
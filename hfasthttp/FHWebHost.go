@@ -1,27 +1,39 @@
 package hfasthttp
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"io/fs"
 	"mime"
+	"net"
 	"net/http"
 	fp "path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DreamvatLab/go/xconfig"
 	"github.com/DreamvatLab/go/xerr"
 	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
 	"github.com/DreamvatLab/go/xsecurity"
 	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hurl"
 	"github.com/fasthttp/router"
 	"github.com/fasthttp/session/v2"
 	"github.com/fasthttp/session/v2/providers/memory"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
 )
 
 const (
 	_filepath = "filepath"
 	_suffix   = "/{" + _filepath + ":*}"
+
+	SessionStore_Memory = "memory"
+	SessionStore_Redis  = "redis"
 )
 
 type WebHostOption func(*FHWebHost)
@@ -33,33 +45,101 @@ type FHWebHost struct {
 	IndexName          string
 	SessionCookieName  string
 	SessionExpSeconds  int
+	SessionStore       string // memory (default) or redis
+	SessionKeyPrefix   string // Redis key prefix of sessions, only used by redis session store
 	ReadBufferSize     int
 	MaxRequestBodySize int
-	Router             *router.Router
-	SessionProvider    session.Provider
-	SessionManager     *session.Session
+	// Stream request bodies larger than MaxRequestBodySize instead of rejecting them,
+	// Actions with a larger MaxBodySize should read them by GetBodyStream
+	StreamRequestBody bool
+	Router            *router.Router
+	SessionProvider   session.Provider
+	SessionManager    *session.Session
 	// HTTP request Handler, if specified, the Router's Handler will not be used
 	HttpHandler     host.RequestHandler
 	PanicHandler    host.RequestHandler
 	CookieEncryptor xsecurity.ICookieEncryptor
-	fsHandler       fasthttp.RequestHandler
+	// Redis used by web host features like redis session store and ip filter, defaults to 'ConnectionStrings.Redis'
+	WebRedisConfig *xredis.RedisConfig
+	// Proxies (IPs or CIDRs) whose Forwarded/X-Forwarded-*/X-Real-IP headers are honored, headers from other peers are ignored
+	TrustedProxies   []string
+	trustedProxyNets []*net.IPNet
+	ipFilter         *host.IPFilter
+	responseCache    *host.ResponseCache
+	idempotency      *host.Idempotency
+	etag             *host.ETag
+	cors             atomic.Value // *host.CORSOptions, replaced when 'CORS' of a watched config provider changes
+	// Path of the route dump endpoint, disabled if empty. It's served by the admin listener and behind admin authorization
+	// if they are configured, protect it by IPFilter rules keyed by the path otherwise
+	RoutesPath         string
+	routeProvider      xsecurity.IRouteProvider
+	permissionProvider xsecurity.IPermissionProvider
+	permissionAuditor  xsecurity.IPermissionAuditor
+	// Router of the admin listener, created if AdminListener is set
+	AdminRouter     *router.Router
+	AdminHealthPath string
+	// Html views rendered by IHttpContext.Render, disabled if nil
+	Views      *host.ViewOptions
+	ViewFS     fs.FS // Load views from it (e.g. embed.FS) instead of disk
+	ViewEngine *host.ViewEngine
+	// Message catalogs used by IHttpContext.T, disabled if nil
+	I18n        *host.I18nOptions
+	I18nFS      fs.FS // Load catalogs from it (e.g. embed.FS) instead of disk
+	i18n        *host.I18n
+	urlProvider hurl.IURLProvider
+	// Config dumped by admin endpoints
+	configProvider xconfig.IConfigProvider
+	admin          *host.Admin
+	server         *fasthttp.Server
+	adminServer    *fasthttp.Server
+	fsHandler      fasthttp.RequestHandler
+	// Seconds Run waits for open connections when it shuts the host down by itself after a serve error, default 30
+	ShutdownTimeoutSeconds int
+	// Shared with BaseHost by composite hosts, created on demand otherwise
+	lifecycle    *host.Lifecycle
+	buildOnce    sync.Once
+	stopping     atomic.Bool
+	shutdownOnce sync.Once
+	shutdownErr  error
 }
 
 func NewFHWebHost(cp xconfig.IConfigProvider, options ...WebHostOption) host.IWebHost {
+	r, err := NewFHWebHostE(cp, options...)
+	xerr.FatalIfErr(err)
+	return r
+}
+
+// NewFHWebHostE creates the host, errors of all bad config keys are returned as host.ConfigErrors
+func NewFHWebHostE(cp xconfig.IConfigProvider, options ...WebHostOption) (host.IWebHost, error) {
 	r := new(FHWebHost)
-	cp.GetStruct("@this", &r)
+	var errs host.ConfigErrors
+	errs.Append("", cp.GetStruct("@this", &r))
+	r.configProvider = cp
+
+	if r.WebRedisConfig == nil {
+		redisConnStr := cp.GetString("ConnectionStrings.Redis")
+		if redisConnStr != "" {
+			var err error
+			r.WebRedisConfig, err = xredis.ParseRedisConfig(redisConnStr)
+			errs.Append("ConnectionStrings.Redis", err)
+		}
+	}
 
 	for _, o := range options {
 		o(r)
 	}
 
-	r.buildFHWebHost()
+	errs.Append("", r.buildFHWebHost())
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	return r
+	return r, nil
 }
 
-func (x *FHWebHost) buildFHWebHost() {
-	x.BuildBaseWebHost()
+func (x *FHWebHost) buildFHWebHost() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BuildBaseWebHostE())
 
 	if x.IndexName == "" {
 		x.IndexName = "index.html"
@@ -74,7 +154,7 @@ func (x *FHWebHost) buildFHWebHost() {
 		x.Router = router.New()
 		x.Router.PanicHandler = func(ctx *fasthttp.RequestCtx, err interface{}) {
 			if x.PanicHandler != nil {
-				newCtx := NewFastHttpContext(ctx, x.SessionManager, x.CookieEncryptor)
+				newCtx := x.newFastHttpContext(ctx)
 				newCtx.SetItem(host.Ctx_Panic, err)
 				x.PanicHandler(newCtx)
 				return
@@ -86,13 +166,28 @@ func (x *FHWebHost) buildFHWebHost() {
 
 	////////// session provider
 	if x.SessionProvider == nil {
-		provider, err := memory.New(memory.Config{})
-		xerr.FatalIfErr(err)
-		x.SessionProvider = provider
+		switch x.SessionStore {
+		case "", SessionStore_Memory:
+			provider, err := memory.New(memory.Config{})
+			errs.Append("SessionStore", err)
+			x.SessionProvider = provider
+		case SessionStore_Redis:
+			if x.SessionKeyPrefix == "" {
+				x.SessionKeyPrefix = "session"
+			}
+			provider, err := NewRedisSessionProvider(x.SessionKeyPrefix, x.WebRedisConfig)
+			errs.Append("SessionStore", err)
+			if err == nil {
+				x.SessionProvider = provider
+				x.GetLifecycle().AddCloser("SessionProvider", provider)
+			}
+		default:
+			errs.Add("SessionStore", "unsupported SessionStore: "+x.SessionStore)
+		}
 	}
 
 	////////// session manager
-	if x.SessionManager == nil {
+	if x.SessionManager == nil && x.SessionProvider != nil {
 		cfg := session.NewDefaultConfig()
 		if x.SessionExpSeconds <= 0 {
 			cfg.Expiration = -1
@@ -100,12 +195,26 @@ func (x *FHWebHost) buildFHWebHost() {
 			cfg.Expiration = time.Second * time.Duration(x.SessionExpSeconds)
 		}
 		cfg.CookieName = x.SessionCookieName
-		cfg.EncodeFunc = session.MSGPEncode // Memory provider has better performance
-		cfg.DecodeFunc = session.MSGPDecode // Memory provider has better performance
+		cfg.EncodeFunc = session.MSGPEncode // Memory provider has better performance, also compact in redis
+		cfg.DecodeFunc = session.MSGPDecode // Memory provider has better performance, also compact in redis
 
 		x.SessionManager = session.New(cfg)
 		err := x.SessionManager.SetProvider(x.SessionProvider)
-		xerr.FatalIfErr(err)
+		errs.Append("SessionStore", err)
+	}
+
+	////////// view engine
+	if x.ViewEngine == nil && x.Views != nil {
+		var err error
+		x.ViewEngine, err = host.NewViewEngine(x.Views, x.ViewFS, x.urlProvider)
+		errs.Append("Views", err)
+	}
+
+	////////// trusted proxies
+	if len(x.TrustedProxies) > 0 {
+		var err error
+		x.trustedProxyNets, err = host.ParseIPNets(x.TrustedProxies)
+		errs.Append("TrustedProxies", err)
 	}
 
 	if x.ReadBufferSize <= 0 {
@@ -116,53 +225,219 @@ func (x *FHWebHost) buildFHWebHost() {
 		x.MaxRequestBodySize = fasthttp.DefaultMaxRequestBodySize
 	}
 
+	////////// IP filter, runs before other global middleware to reject requests early
+	if x.IPFilter != nil && x.ipFilter == nil {
+		var err error
+		x.ipFilter, err = host.NewIPFilter(x.IPFilter, x.WebRedisConfig)
+		if err != nil {
+			errs.Append("IPFilter", err)
+		} else {
+			x.GetLifecycle().AddCloser("IPFilter", x.ipFilter)
+			x.AddGlobalPreHandlers(false, x.ipFilter.Handler)
+		}
+	}
+
+	////////// i18n, at the head, so messages of auth handlers and ip filter are translated
+	if x.I18n != nil && x.i18n == nil {
+		var err error
+		x.i18n, err = host.NewI18n(x.I18n, x.I18nFS)
+		if err != nil {
+			errs.Append("I18n", err)
+		} else {
+			x.AddGlobalPreHandlers(false, x.i18n.Handler)
+		}
+	}
+
+	////////// admin router
+	if x.AdminListener != nil && x.AdminRouter == nil {
+		x.AdminRouter = router.New()
+	}
+	if x.AdminHealthPath == "" {
+		x.AdminHealthPath = "/health"
+	}
+	if x.AdminRouter != nil {
+		x.AdminGET(x.AdminHealthPath, func(ctx host.IHttpContext) {
+			ctx.WriteString("OK")
+		})
+	}
+
+	////////// admin endpoints
+	if x.Admin != nil && x.admin == nil {
+		var err error
+		x.admin, err = host.NewAdmin(x.Admin, x.configProvider)
+		if err != nil {
+			errs.Append("Admin", err)
+		} else {
+			x.registerAdmin()
+		}
+	}
+
+	////////// route dump endpoint
+	if x.RoutesPath != "" {
+		handler := host.NewRoutesHandler(x, x.permissionAuditor)
+		if x.admin != nil {
+			r := x.AdminRouter
+			if r == nil {
+				r = x.Router
+			}
+			r.GET(x.RoutesPath, x.buildNativeHandler(x.RoutesPath, 0, nil, x.admin.AuthHandler, handler))
+		} else {
+			x.AdminGET(x.RoutesPath, handler)
+		}
+	}
+
 	////////// CORS
 	if x.CORS != nil {
+		x.cors.Store(x.CORS)
+		if watcher, ok := x.configProvider.(host.IConfigWatcher); ok {
+			// Options are reloaded, but removing or adding the whole section needs a restart
+			watcher.OnChange("CORS", func(cp xconfig.IConfigProvider) {
+				cors := new(host.CORSOptions)
+				if err := cp.GetStruct("CORS", cors); err != nil {
+					xlog.Error(err)
+					return
+				}
+				x.cors.Store(cors)
+			})
+		}
+
 		x.AddGlobalPreHandlers(true, func(ctx host.IHttpContext) {
-			if x.CORS.AllowedOrigin != "" {
-				ctx.SetHeader("Access-Control-Allow-Origin", x.CORS.AllowedOrigin)
+			if cors := x.cors.Load().(*host.CORSOptions); cors.AllowedOrigin != "" {
+				ctx.SetHeader("Access-Control-Allow-Origin", cors.AllowedOrigin)
 			}
 			ctx.Next()
 		})
 
 		x.OPTIONS("/{filepath:*}", func(ctx host.IHttpContext) {
-			// if x.CORS.AllowedOrigin != "" {	// Already added by global middleware above
-			// 	ctx.SetHeader("Access-Control-Allow-Origin", x.CORS.AllowedOrigin)
-			// }
-			if x.CORS.AllowedMethods != "" {
-				ctx.SetHeader("Access-Control-Allow-Methods", x.CORS.AllowedMethods)
+			// Access-Control-Allow-Origin is already added by global middleware above
+			cors := x.cors.Load().(*host.CORSOptions)
+			if cors.AllowedMethods != "" {
+				ctx.SetHeader("Access-Control-Allow-Methods", cors.AllowedMethods)
 			}
-			if x.CORS.AllowedHeaders != "" {
-				ctx.SetHeader("Access-Control-Allow-Headers", x.CORS.AllowedHeaders)
+			if cors.AllowedHeaders != "" {
+				ctx.SetHeader("Access-Control-Allow-Headers", cors.AllowedHeaders)
 			}
 		})
 	}
+
+	////////// etag, before response cache, so cached responses are answered by 304 as well
+	if x.ETag != nil && x.etag == nil {
+		var err error
+		x.etag, err = host.NewETagE(x.ETag)
+		if err != nil {
+			errs.Append("ETag", err)
+		} else {
+			x.AddGlobalPreHandlers(true, x.etag.Handler)
+		}
+	}
+
+	////////// response cache
+	if x.ResponseCache != nil && x.responseCache == nil {
+		if x.ResponseCache.SessionCookieName == "" {
+			x.ResponseCache.SessionCookieName = x.SessionCookieName
+		}
+		var err error
+		x.responseCache, err = host.NewResponseCache(x.ResponseCache, x.WebRedisConfig)
+		if err != nil {
+			errs.Append("ResponseCache", err)
+		} else {
+			x.GetLifecycle().AddCloser("ResponseCache", x.responseCache)
+			x.AddGlobalPreHandlers(true, x.responseCache.Handler) // After CORS, so cached responses get CORS headers
+		}
+	}
+
+	////////// idempotency
+	if x.Idempotency != nil && x.idempotency == nil {
+		if x.Idempotency.SessionCookieName == "" {
+			x.Idempotency.SessionCookieName = x.SessionCookieName
+		}
+		var err error
+		x.idempotency, err = host.NewIdempotency(x.Idempotency, x.WebRedisConfig)
+		if err != nil {
+			errs.Append("Idempotency", err)
+		} else {
+			x.GetLifecycle().AddCloser("Idempotency", x.idempotency)
+			x.AddGlobalPreHandlers(true, x.idempotency.Handler)
+		}
+	}
+
+	return errs.Err()
 }
 
 func (x *FHWebHost) BuildNativeHandler(routeKey string, handlers ...host.RequestHandler) fasthttp.RequestHandler {
+	return x.buildNativeHandlerWithGlobals(routeKey, 0, nil, handlers...)
+}
+
+func (x *FHWebHost) buildNativeHandlerWithGlobals(routeKey string, maxBodySize int, permission *host.ActionPermission, handlers ...host.RequestHandler) fasthttp.RequestHandler {
 	if len(handlers) == 0 {
-		xlog.Fatal("handlers are missing")
+		return x.missingHandlers(routeKey)
 	}
 
-	// Register global middleware
-	if len(x.GlobalPreHandlers) > 0 {
-		handlers = append(x.GlobalPreHandlers, handlers...)
+	// Register global middleware, into a new slice as appending to GlobalPreHandlers may share its backing array between actions
+	chain := make([]host.RequestHandler, 0, len(x.GlobalPreHandlers)+len(handlers)+len(x.GlobalSufHandlers))
+	chain = append(chain, x.GlobalPreHandlers...)
+	chain = append(chain, handlers...)
+	chain = append(chain, x.GlobalSufHandlers...)
+
+	return x.buildNativeHandler(routeKey, maxBodySize, permission, chain...)
+}
+
+// buildNativeHandler builds handler without global middleware, maxBodySize <= 0 uses MaxRequestBodySize,
+// permission is the declared permission of the action checked by AuthHandler
+func (x *FHWebHost) buildNativeHandler(routeKey string, maxBodySize int, permission *host.ActionPermission, handlers ...host.RequestHandler) fasthttp.RequestHandler {
+	if len(handlers) == 0 {
+		return x.missingHandlers(routeKey)
 	}
-	if len(x.GlobalSufHandlers) > 0 {
-		handlers = append(handlers, x.GlobalSufHandlers...)
+	if maxBodySize <= 0 {
+		maxBodySize = x.MaxRequestBodySize
 	}
 
 	return fasthttp.RequestHandler(func(ctx *fasthttp.RequestCtx) {
-		newCtx := NewFastHttpContext(ctx, x.SessionManager, x.CookieEncryptor, handlers...)
+		newCtx := x.newFastHttpContext(ctx, handlers...)
+		newCtx.maxBodySize = maxBodySize
 		newCtx.SetItem(host.Ctx_RouteKey, routeKey)
+		if permission != nil {
+			newCtx.SetItem(host.Ctx_Permission, permission)
+		}
 		defer func() {
 			newCtx.Reset()
 			_ctxPool.Put(newCtx)
 		}()
+
+		if newCtx.bodyTooLarge() {
+			respondBodyTooLarge(ctx)
+			return
+		}
+
 		handlers[0](newCtx) // Start executing the first Handler
+
+		if newCtx.bodyExceeded {
+			// A streamed body went beyond the limit while handlers read it, keep 413 responses of handlers like uploads
+			if ctx.Response.StatusCode() == http.StatusRequestEntityTooLarge {
+				ctx.SetConnectionClose()
+			} else {
+				respondBodyTooLarge(ctx)
+			}
+		}
 	})
 }
 
+// missingHandlers records a route without handlers, Run and Serve fail then, and returns a handler responding 500
+func (x *FHWebHost) missingHandlers(routeKey string) fasthttp.RequestHandler {
+	x.AddRouteError(routeKey, "handlers are missing")
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.Error("handlers are missing", http.StatusInternalServerError)
+	}
+}
+
+func (x *FHWebHost) newFastHttpContext(ctx *fasthttp.RequestCtx, handlers ...host.RequestHandler) *FastHttpContext {
+	r := NewFastHttpContext(ctx, x.SessionManager, x.CookieEncryptor, handlers...).(*FastHttpContext)
+	r.sessCookieName = x.SessionCookieName
+	r.trustedProxies = x.trustedProxyNets
+	r.viewEngine = x.ViewEngine
+	return r
+}
+
 func (x *FHWebHost) NewFSHandler(root string, stripSlashes int) host.RequestHandler {
 	x.fsHandler = fasthttp.FSHandler(root, stripSlashes)
 	return func(ctx host.IHttpContext) {
@@ -173,25 +448,32 @@ func (x *FHWebHost) NewFSHandler(root string, stripSlashes int) host.RequestHand
 
 func (x *FHWebHost) GET(path string, handlers ...host.RequestHandler) {
 	x.Router.GET(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodGet, path, path, handlers...)
 }
 func (x *FHWebHost) POST(path string, handlers ...host.RequestHandler) {
 	x.Router.POST(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodPost, path, path, handlers...)
 }
 func (x *FHWebHost) PUT(path string, handlers ...host.RequestHandler) {
 	x.Router.PUT(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodPut, path, path, handlers...)
 }
 func (x *FHWebHost) PATCH(path string, handlers ...host.RequestHandler) {
 	x.Router.PATCH(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodPatch, path, path, handlers...)
 }
 func (x *FHWebHost) DELETE(path string, handlers ...host.RequestHandler) {
 	x.Router.DELETE(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodDelete, path, path, handlers...)
 }
 func (x *FHWebHost) OPTIONS(path string, handlers ...host.RequestHandler) {
 	x.Router.OPTIONS(path, x.BuildNativeHandler(path, handlers...))
+	x.addRouteInfo(http.MethodOptions, path, path, handlers...)
 }
 
 func (x *FHWebHost) ServeFiles(webPath, physiblePath string) {
 	x.Router.ServeFiles(webPath, physiblePath)
+	x.AddRouteInfo(http.MethodGet, webPath, webPath, x.Router.ServeFiles)
 }
 
 func (x *FHWebHost) ServeEmbedFiles(webPath, physiblePath string, emd embed.FS) {
@@ -199,6 +481,7 @@ func (x *FHWebHost) ServeEmbedFiles(webPath, physiblePath string, emd embed.FS)
 		panic("path must end with " + _suffix + " in path '" + webPath + "'")
 	}
 
+	x.AddRouteInfo(http.MethodGet, webPath, webPath, x.ServeEmbedFiles)
 	x.Router.GET(webPath, func(ctx *fasthttp.RequestCtx) {
 		filepath := ctx.UserValue(_filepath).(string)
 		if filepath == "" {
@@ -225,14 +508,158 @@ func (x *FHWebHost) ServeEmbedFiles(webPath, physiblePath string, emd embed.FS)
 }
 
 func (x *FHWebHost) Run() error {
+	if dump, err := host.DumpConfigSchema(x.configProvider); dump {
+		return errors.Join(err, x.Shutdown(context.Background()))
+	}
+	if err := x.RouteErr(); err != nil {
+		return errors.Join(err, x.Shutdown(context.Background()))
+	}
+
+	lifecycle := x.GetLifecycle()
+	if err := lifecycle.RunStartHooks(context.Background()); err != nil {
+		return errors.Join(err, x.Shutdown(context.Background()))
+	}
+
+	if x.PermissionSync != nil {
+		if err := x.SyncPermissions(); err != nil {
+			return errors.Join(err, x.Shutdown(context.Background()))
+		}
+		if x.PermissionSync.Exit {
+			return x.Shutdown(context.Background())
+		}
+	}
+
+	x.buildServer()
+
+	////////// Listeners
+	listenerOptions := x.GetListenerOptions()
+	listeners := make([]net.Listener, 0, len(listenerOptions))
+	closeListeners := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+	for _, o := range listenerOptions {
+		ln, err := host.NewListener(o)
+		if err != nil {
+			closeListeners()
+			return errors.Join(err, x.Shutdown(context.Background()))
+		}
+		listeners = append(listeners, ln)
+	}
+
+	var adminListener net.Listener
+	if x.AdminListener != nil {
+		var err error
+		adminListener, err = host.NewListener(x.AdminListener)
+		if err != nil {
+			closeListeners()
+			return errors.Join(err, x.Shutdown(context.Background()))
+		}
+	}
+
+	if x.stopping.Load() { // Shut down during start, e.g. by a runner whose other host failed
+		closeListeners()
+		if adminListener != nil {
+			adminListener.Close()
+		}
+		x.Shutdown(context.Background())
+		return nil
+	}
+
+	////////// Start Serve
+	errs := make(chan error, len(listeners)+1)
+	for i, ln := range listeners {
+		xlog.Infof("Listening on %s", listenerOptions[i])
+		go func(ln net.Listener) {
+			errs <- xerr.WithStack(x.server.Serve(ln))
+		}(ln)
+	}
+
+	if adminListener != nil {
+		x.adminServer = &fasthttp.Server{
+			Handler: x.AdminRouter.Handler,
+			Logger:  new(debugLogger),
+		}
+		xlog.Infof("Admin listening on %s", x.AdminListener)
+		go func() {
+			errs <- xerr.WithStack(x.adminServer.Serve(adminListener))
+		}()
+	}
+
+	if err := lifecycle.RunReadyHooks(context.Background()); err != nil {
+		return errors.Join(err, x.shutdownWithTimeout())
+	}
+
+	err := <-errs
+	if x.stopping.Load() {
+		// Stopped by Shutdown, wait until it finishes, its caller gets the shutdown errors
+		x.Shutdown(context.Background())
+		return nil
+	}
+	if err == nil {
+		err = xerr.New("server stopped unexpectedly")
+	}
+	return errors.Join(err, x.shutdownWithTimeout())
+}
+
+// Shutdown runs stopping hooks, stops servers gracefully until ctx is done, then runs stopped hooks and cleanups.
+// It runs once, later calls wait for the first one and return its result
+func (x *FHWebHost) Shutdown(ctx context.Context) error {
+	x.shutdownOnce.Do(func() {
+		x.stopping.Store(true)
+		lifecycle := x.GetLifecycle()
+		errs := []error{lifecycle.RunStoppingHooks(ctx)}
+		if x.server != nil {
+			errs = append(errs, xerr.WithStack(x.server.ShutdownWithContext(ctx)))
+		}
+		if x.adminServer != nil {
+			errs = append(errs, xerr.WithStack(x.adminServer.ShutdownWithContext(ctx)))
+		}
+		errs = append(errs, lifecycle.RunStoppedHooks(ctx))
+		x.shutdownErr = errors.Join(errs...)
+	})
+	return x.shutdownErr
+}
+
+func (x *FHWebHost) shutdownWithTimeout() error {
+	timeout := x.ShutdownTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 30
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return x.Shutdown(ctx)
+}
+
+// GetLifecycle returns hooks of the host, composite hosts share the lifecycle of their BaseHost
+func (x *FHWebHost) GetLifecycle() *host.Lifecycle {
+	if x.lifecycle == nil {
+		x.lifecycle = new(host.Lifecycle)
+	}
+	return x.lifecycle
+}
+
+// Serve registers actions and serves the main router on ln only, e.g. an in-memory listener in tests
+func (x *FHWebHost) Serve(ln net.Listener) error {
+	if err := x.RouteErr(); err != nil {
+		return err
+	}
+	x.buildServer()
+	return xerr.WithStack(x.server.Serve(ln))
+}
+
+// buildServer registers Actions and creates the server once, so Serve can be called for more listeners or after Run
+func (x *FHWebHost) buildServer() {
+	x.buildOnce.Do(x.doBuildServer)
+}
+
+func (x *FHWebHost) doBuildServer() {
 	////////// Register Actions to router
 	for _, v := range x.Actions {
 		x.RegisterActionsToRouter(v)
 	}
 
-	////////// Start Serve
-	xlog.Infof("Listening on %s", x.ListenAddr)
-
 	var handler fasthttp.RequestHandler
 	if x.HttpHandler == nil {
 		handler = x.Router.Handler
@@ -240,14 +667,63 @@ func (x *FHWebHost) Run() error {
 		handler = x.BuildNativeHandler("General", x.HttpHandler)
 	}
 
-	s := &fasthttp.Server{
+	x.server = &fasthttp.Server{
 		// Handler:        x.Router.Handler,
 		Handler:            handler,
-		ReadBufferSize:     x.ReadBufferSize, // Increase this value to resolve Http 431 error
-		MaxRequestBodySize: x.MaxRequestBodySize,
-		Logger:             new(debugLogger),
+		ReadBufferSize:     x.ReadBufferSize,     // Increase this value to resolve Http 431 error
+		MaxRequestBodySize: x.MaxRequestBodySize, // Buffered size of each request when StreamRequestBody is on
+		StreamRequestBody:  x.StreamRequestBody,
+		// Let handlers read streamed multipart forms, otherwise they are parsed into temp files before checking body limits
+		DisablePreParseMultipartForm: x.StreamRequestBody,
+		Logger:                       new(debugLogger),
 	}
-	return s.ListenAndServe(x.ListenAddr)
+}
+
+// SyncPermissions publishes declared permissions of actions to the route and permission providers of the host
+func (x *FHWebHost) SyncPermissions() error {
+	count, err := host.SyncActionPermissions(x.Actions, x.routeProvider, x.permissionProvider)
+	if err != nil {
+		return err
+	}
+	xlog.Infof("%d routes and permissions synced from actions", count)
+	return nil
+}
+
+// GetResponseCache returns the response cache middleware, nil if 'ResponseCache' is not configured
+func (x *FHWebHost) GetResponseCache() *host.ResponseCache {
+	return x.responseCache
+}
+
+// registerAdmin mounts admin endpoints to the admin listener, or to the main router without global middleware,
+// admin endpoints have their own authorization
+func (x *FHWebHost) registerAdmin() {
+	adaptor := fasthttpadaptor.NewFastHTTPHandler(x.admin.Handler())
+	path := x.admin.GetPathPrefix() + "/{" + _filepath + ":*}"
+	handler := x.buildNativeHandler(path, 0, nil, x.admin.AuthHandler, func(ctx host.IHttpContext) {
+		adaptor(ctx.GetInnerContext().(*fasthttp.RequestCtx))
+	})
+
+	r := x.AdminRouter
+	if r == nil {
+		r = x.Router
+	}
+	r.GET(path, handler)
+	r.PUT(path, handler)
+}
+
+// GetAdmin returns the admin endpoints, nil if 'Admin' is not configured
+func (x *FHWebHost) GetAdmin() *host.Admin {
+	return x.admin
+}
+
+// AdminGET registers an admin endpoint to the admin listener without global middleware,
+// or to the main router with global middleware if there is no admin listener
+func (x *FHWebHost) AdminGET(path string, handlers ...host.RequestHandler) {
+	if x.AdminRouter == nil {
+		x.GET(path, handlers...)
+		return
+	}
+	x.AdminRouter.GET(path, x.buildNativeHandler(path, 0, nil, handlers...))
 }
 
 func (x *FHWebHost) RegisterActionsToRouter(action *host.Action) {
@@ -255,20 +731,30 @@ func (x *FHWebHost) RegisterActionsToRouter(action *host.Action) {
 	method := action.Route[:index]
 	path := action.Route[index:]
 
+	handler := x.buildNativeHandlerWithGlobals(action.RouteKey, action.MaxBodySize, action.Permission, action.Handlers...)
 	switch method {
 	case http.MethodPost:
-		x.Router.POST(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.POST(path, handler)
 	case http.MethodGet:
-		x.Router.GET(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.GET(path, handler)
 	case http.MethodPut:
-		x.Router.PUT(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.PUT(path, handler)
 	case http.MethodPatch:
-		x.Router.PATCH(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.PATCH(path, handler)
 	case http.MethodDelete:
-		x.Router.DELETE(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.DELETE(path, handler)
 	case http.MethodOptions:
-		x.Router.OPTIONS(path, x.BuildNativeHandler(action.RouteKey, action.Handlers...))
+		x.Router.OPTIONS(path, handler)
 	default:
 		panic("does not support method " + method)
 	}
+	x.addRouteInfo(method, path, action.RouteKey, action.Handlers...)
+}
+
+func (x *FHWebHost) addRouteInfo(method, path, routeKey string, handlers ...host.RequestHandler) {
+	a := make([]interface{}, 0, len(handlers))
+	for _, h := range handlers {
+		a = append(a, h)
+	}
+	x.AddRouteInfo(method, path, routeKey, a...)
 }
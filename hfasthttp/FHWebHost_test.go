@@ -0,0 +1,264 @@
+package hfasthttp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hfasthttp"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestResourceHostShutdown(t *testing.T) {
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":"127.0.0.1:0","Log":{"Level":"error"}}`, hosttest.NewStubPermissions().ResourceHostOption())
+
+	var stages []string
+	ready := make(chan struct{})
+	lifecycle := resourceHost.GetLifecycle()
+	lifecycle.OnReady("ready", func(ctx context.Context) error {
+		stages = append(stages, "ready")
+		close(ready)
+		return nil
+	})
+	lifecycle.OnStopping("stopping", func(ctx context.Context) error {
+		stages = append(stages, "stopping")
+		return nil
+	})
+	lifecycle.AddCleanup("cleanup", func(ctx context.Context) error {
+		stages = append(stages, "cleanup")
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- resourceHost.Run()
+	}()
+	<-ready
+
+	if err := resourceHost.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"ready", "stopping", "cleanup"}; !reflect.DeepEqual(stages, expected) {
+		t.Errorf("stages %v, expected %v", stages, expected)
+	}
+}
+
+func TestServeTwice(t *testing.T) {
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"}}`, hosttest.NewStubPermissions().AllowGuest("api_ping").ResourceHostOption())
+	resourceHost.AddGlobalPreHandlers(false, resourceHost.AuthHandler)
+	resourceHost.AddAction("GET/ping", "api_ping", func(ctx host.IHttpContext) {
+		ctx.WriteString("pong")
+	})
+
+	// Actions are registered once, the second listener is served by the same server
+	s1 := hosttest.Start(t, resourceHost)
+	s2 := hosttest.Start(t, resourceHost)
+	hosttest.AssertStatus(t, s1.GET(t, "/ping"), http.StatusOK)
+	hosttest.AssertStatus(t, s2.GET(t, "/ping"), http.StatusOK)
+}
+
+func TestHandlerChains(t *testing.T) {
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"}}`, hosttest.NewStubPermissions().ResourceHostOption())
+	next := func(ctx host.IHttpContext) { ctx.Next() }
+	// Appended one by one, so GlobalPreHandlers has spare capacity
+	resourceHost.AddGlobalPreHandlers(true, next)
+	resourceHost.AddGlobalPreHandlers(true, next)
+	resourceHost.AddGlobalPreHandlers(true, next)
+	write := func(body string) host.RequestHandler {
+		return func(ctx host.IHttpContext) {
+			ctx.WriteString(body)
+		}
+	}
+	resourceHost.AddActions(
+		host.NewAction("GET/a", "a", write("a")),
+		host.NewAction("GET/b", "b", write("b")),
+	)
+	preHandlers := make([]host.RequestHandler, 1, 4)
+	preHandlers[0] = next
+	resourceHost.AddActionGroups(host.NewActionGroup(preHandlers, []*host.Action{
+		host.NewAction("GET/c", "c", write("c")),
+		host.NewAction("GET/d", "d", write("d")),
+	}))
+
+	s := hosttest.Start(t, resourceHost)
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		resp := s.GET(t, path)
+		hosttest.AssertStatus(t, resp, http.StatusOK)
+		if string(resp.Body) != path[1:] {
+			t.Errorf("GET %s reached %q", path, resp.Body)
+		}
+	}
+}
+
+func TestBuiltinMiddlewareChains(t *testing.T) {
+	redisConfig := hosttest.NewRedisConfig(t)
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"},"SessionCookieName":"sid",
+		"CORS":{"AllowedOrigin":"https://a.com"},"ETag":{},"ResponseCache":{"Routes":{"api__":{}}},"Idempotency":{"Routes":["api__"]}}`,
+		hosttest.NewStubPermissions().ResourceHostOption(),
+		func(h *hfasthttp.FHOAuthResourceHost) {
+			h.WebRedisConfig = redisConfig
+		})
+	write := func(body string) host.RequestHandler {
+		return func(ctx host.IHttpContext) {
+			ctx.WriteString(body)
+		}
+	}
+	resourceHost.AddActions(
+		host.NewAction("GET/a", "api_a_get", write("a")),
+		host.NewAction("GET/b", "api_b_get", write("b")),
+		host.NewAction("POST/c", "api_c_create", write("c")),
+		host.NewAction("POST/d", "api_d_create", write("d")),
+	)
+	s := hosttest.Start(t, resourceHost)
+
+	for _, path := range []string{"/a", "/b"} {
+		for _, xCache := range []string{"MISS", "HIT"} {
+			resp := s.GET(t, path)
+			hosttest.AssertStatus(t, resp, http.StatusOK)
+			if string(resp.Body) != path[1:] || resp.Header.Get(host.Header_XCache) != xCache {
+				t.Fatalf("GET %s = %q, X-Cache %q, expected %q", path, resp.Body, resp.Header.Get(host.Header_XCache), xCache)
+			}
+			if resp.Header.Get("Access-Control-Allow-Origin") != "https://a.com" {
+				t.Errorf("GET %s has no CORS header", path)
+			}
+			// ETag runs before the response cache, so cached responses are answered by 304 as well
+			hosttest.AssertStatus(t, s.GET(t, path, hosttest.WithHeader("If-None-Match", resp.Header.Get("ETag"))), http.StatusNotModified)
+		}
+	}
+	for _, path := range []string{"/c", "/d"} {
+		for _, replayed := range []string{"", "true"} {
+			resp := s.POST(t, path, "{}", hosttest.WithHeader(host.Header_IdempotencyKey, "k"+path), hosttest.WithHeader("Cookie", "sid=s1"))
+			if string(resp.Body) != path[1:] || resp.Header.Get(host.Header_IdempotencyReplayed) != replayed {
+				t.Fatalf("POST %s = %q, replayed %q, expected %q", path, resp.Body, resp.Header.Get(host.Header_IdempotencyReplayed), replayed)
+			}
+		}
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":"127.0.0.1:0","Log":{"Level":"error"}}`, hosttest.NewStubPermissions().ResourceHostOption())
+	ok := func(ctx host.IHttpContext) {}
+
+	err := resourceHost.AddActionsE(host.NewAction("GET/a", "a", ok), host.NewAction("GET/a", "a", ok), host.NewAction("GET/b", "b"))
+	var errs host.ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Key != "GET/a" || errs[1].Key != "GET/b" {
+		t.Fatalf("AddActionsE = %v, expected duplicated GET/a and GET/b without handlers", err)
+	}
+	if resourceHost.RouteErr() != nil {
+		t.Fatal("errors returned by AddActionsE are reported again")
+	}
+
+	resourceHost.AddAction("GET/a", "a", ok)
+	resourceHost.AddActionGroups(host.NewActionGroup(nil, []*host.Action{host.NewAction("GET/c", "c")}))
+	resourceHost.GET("/d")
+	err = resourceHost.Run()
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("Run = %v, expected errors of GET/a, GET/c and /d", err)
+	}
+	for _, route := range []string{"GET/a", "GET/c", "/d"} {
+		if !strings.Contains(err.Error(), route+": ") {
+			t.Errorf("error of %s is not reported: %v", route, err)
+		}
+	}
+}
+
+func TestRunDumpsConfigSchema(t *testing.T) {
+	file := fp.Join(t.TempDir(), "configs.json")
+	os.WriteFile(file, []byte(`{"ListenAddr":"127.0.0.1:0","Log":{"Level":"error"}}`), 0644)
+
+	args := os.Args
+	os.Args = []string{"app", "--config-schema"}
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0) // Schema is dumped to stdout
+	t.Cleanup(func() {
+		os.Args = args
+		os.Stdout.Close()
+		os.Stdout = stdout
+	})
+
+	cp, err := host.NewLayeredConfigProvider(&host.LayeredConfigOptions{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := hosttest.NewTokenIssuer("https://issuer", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceHost, err := hfasthttp.NewFHOAuthResourceHostE(cp, issuer.ResourceHostOption(), hosttest.NewStubPermissions().ResourceHostOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := false
+	resourceHost.GetLifecycle().OnStart("start", func(ctx context.Context) error {
+		started = true
+		return nil
+	})
+	if err := resourceHost.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if started {
+		t.Error("host started instead of dumping the schema")
+	}
+}
+
+// watchedConfigProvider is a config provider whose json is replaced by tests
+type watchedConfigProvider struct {
+	*xconfig.JsonConfigProvider
+	host.ConfigChangeNotifier
+}
+
+func (x *watchedConfigProvider) set(t *testing.T, configJson string) {
+	cp, err := hosttest.NewConfigProvider(configJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := x.JsonConfigProvider
+	x.JsonConfigProvider = cp.(*xconfig.JsonConfigProvider)
+	if previous != nil {
+		x.Notify(x, previous.RawJson, x.RawJson)
+	}
+}
+
+func TestCORSReload(t *testing.T) {
+	cp := new(watchedConfigProvider)
+	cp.set(t, `{"ListenAddr":":0","Log":{"Level":"error"},"CORS":{"AllowedOrigin":"https://a.com","AllowedMethods":"GET"}}`)
+	issuer, err := hosttest.NewTokenIssuer("https://issuer", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceHost, err := hfasthttp.NewFHOAuthResourceHostE(cp, issuer.ResourceHostOption(), hosttest.NewStubPermissions().AllowGuest("api_ping").ResourceHostOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceHost.AddAction("GET/ping", "api_ping", func(ctx host.IHttpContext) {
+		ctx.WriteString("pong")
+	})
+	s := hosttest.Start(t, resourceHost)
+
+	if origin := s.GET(t, "/ping").Header.Get("Access-Control-Allow-Origin"); origin != "https://a.com" {
+		t.Errorf("origin '%s', expected the configured one", origin)
+	}
+
+	cp.set(t, `{"ListenAddr":":0","Log":{"Level":"error"},"CORS":{"AllowedOrigin":"https://b.com","AllowedMethods":"GET,POST"}}`)
+	if origin := s.GET(t, "/ping").Header.Get("Access-Control-Allow-Origin"); origin != "https://b.com" {
+		t.Errorf("origin '%s', expected the changed one", origin)
+	}
+	resp, err := s.Request(http.MethodOptions, "/ping", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if methods := resp.Header.Get("Access-Control-Allow-Methods"); methods != "GET,POST" {
+		t.Errorf("methods '%s', expected the changed ones", methods)
+	}
+}
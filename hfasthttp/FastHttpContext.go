@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"mime/multipart"
+	"net"
 	"net/http"
-	"strings"
 	"sync"
 
 	"github.com/DreamvatLab/go/xbytes"
@@ -41,6 +41,11 @@ type FastHttpContext struct {
 	sess            *session.Session
 	sessStore       *session.Store
 	mapPool         *sync.Pool
+	sessCookieName  string
+	trustedProxies  []*net.IPNet
+	maxBodySize     int
+	bodyExceeded    bool // The body was read beyond maxBodySize, the host responds 413 whatever handlers responded
+	viewEngine      *host.ViewEngine
 	cookieEncryptor xsecurity.ICookieEncryptor
 	handlers        []host.RequestHandler
 	handlerIndex    int
@@ -186,6 +191,12 @@ func (x *FastHttpContext) RemoveCookie(key string, options ...func(*http.Cookie)
 }
 
 func (x *FastHttpContext) SetSession(key, value string) {
+	x.SetSessionValue(key, value)
+}
+
+// SetSessionValue stores a value which can be encoded by the session encoder (string, bool, numbers, []byte ...),
+// use SetSessionStruct for structs
+func (x *FastHttpContext) SetSessionValue(key string, value interface{}) {
 	store, err := x.sess.Get(x.ctx)
 	if xerr.LogError(err) {
 		return
@@ -195,21 +206,58 @@ func (x *FastHttpContext) SetSession(key, value string) {
 	}()
 	store.Set(key, value)
 }
-func (x *FastHttpContext) GetSessionString(key string) string {
+
+// SetSessionStruct stores obj as json
+func (x *FastHttpContext) SetSessionStruct(key string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	x.SetSessionValue(key, xbytes.BytesToStr(data))
+	return nil
+}
+
+func (x *FastHttpContext) GetSessionValue(key string) interface{} {
 	store, err := x.sess.Get(x.ctx)
 	if xerr.LogError(err) {
-		return ""
+		return nil
 	}
 	defer func() {
 		xerr.LogError(x.sess.Save(x.ctx, store))
 	}()
 
-	if r, ok := store.Get(key).(string); ok {
+	return store.Get(key)
+}
+func (x *FastHttpContext) GetSessionString(key string) string {
+	if r, ok := x.GetSessionValue(key).(string); ok {
 		return r
 	}
 
 	return ""
 }
+func (x *FastHttpContext) GetSessionInt(key string) int {
+	v := x.GetSessionValue(key)
+	return xconv.ToInt(v)
+}
+func (x *FastHttpContext) GetSessionInt64(key string) int64 {
+	v := x.GetSessionValue(key)
+	return xconv.ToInt64(v)
+}
+func (x *FastHttpContext) GetSessionBool(key string) bool {
+	v := x.GetSessionValue(key)
+	return xconv.ToBool(v)
+}
+
+// GetSessionStruct reads a value stored by SetSessionStruct, objPtr is untouched if the key does not exist
+func (x *FastHttpContext) GetSessionStruct(key string, objPtr interface{}) error {
+	data := x.GetSessionString(key)
+	if data == "" {
+		return nil
+	}
+	err := json.Unmarshal(xbytes.StrToBytes(data), objPtr)
+	return xerr.WithStack(err)
+}
+
 func (x *FastHttpContext) RemoveSession(key string) {
 	store, err := x.sess.Get(x.ctx)
 	if xerr.LogError(err) {
@@ -220,6 +268,27 @@ func (x *FastHttpContext) RemoveSession(key string) {
 	}()
 	store.Delete(key)
 }
+
+// RegenerateSession issues a new session id and keeps the session data, call it after signing in to prevent session fixation
+func (x *FastHttpContext) RegenerateSession() error {
+	err := x.sess.Regenerate(x.ctx)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+
+	if x.sessCookieName != "" {
+		// Session manager reads the id from request cookie, point it to the new id for the rest of this request
+		c := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(c)
+		c.SetKey(x.sessCookieName)
+		if x.ctx.Response.Header.Cookie(c) {
+			x.ctx.Request.Header.SetCookieBytesKV(c.Key(), c.Value())
+		}
+	}
+
+	return nil
+}
+
 func (x *FastHttpContext) EndSession() {
 	x.sess.Destroy(x.ctx)
 }
@@ -249,10 +318,12 @@ func (x *FastHttpContext) GetMultipartForm() (*multipart.Form, error) {
 
 func (x *FastHttpContext) GetBodyString() string {
 	// return x.ctx.Request.String()
-	return xbytes.BytesToStr(x.ctx.Request.Body())
+	data, _ := x.body()
+	return xbytes.BytesToStr(data)
 }
 func (x *FastHttpContext) GetBodyBytes() []byte {
-	return x.ctx.Request.Body()
+	data, _ := x.body()
+	return data
 }
 
 func (x *FastHttpContext) GetParamString(key string) string {
@@ -273,8 +344,11 @@ func (x *FastHttpContext) GetParamInt64(key string) int64 {
 }
 
 func (x *FastHttpContext) ReadJSON(objPtr interface{}) error {
-	data := x.ctx.Request.Body()
-	err := json.Unmarshal(data, objPtr)
+	data, err := x.body()
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, objPtr)
 	return xerr.WithStack(err)
 }
 func (x *FastHttpContext) ReadQuery(objPtr interface{}) error {
@@ -329,6 +403,16 @@ func (x *FastHttpContext) GetHeader(key string) string {
 func (x *FastHttpContext) SetStatusCode(statusCode int) {
 	x.ctx.SetStatusCode(statusCode)
 }
+func (x *FastHttpContext) GetStatusCode() int {
+	return x.ctx.Response.StatusCode()
+}
+func (x *FastHttpContext) GetResponseHeader(key string) string {
+	v := x.ctx.Response.Header.Peek(key)
+	return xbytes.BytesToStr(v)
+}
+func (x *FastHttpContext) GetResponseBody() []byte {
+	return x.ctx.Response.Body()
+}
 func (x *FastHttpContext) SetContentType(cType string) {
 	x.ctx.SetContentType(cType)
 }
@@ -347,8 +431,40 @@ func (x *FastHttpContext) WriteJsonBytes(body []byte) (int, error) {
 	return r, xerr.WithStack(err)
 }
 
+// Render renders html view by the host's view engine
+func (x *FastHttpContext) Render(name string, data interface{}) error {
+	return host.RenderView(x, x.viewEngine, name, data)
+}
+
+// T translates key by the locale of the request
+func (x *FastHttpContext) T(key string, args ...interface{}) string {
+	return host.Translate(x, key, args...)
+}
+
+func (x *FastHttpContext) RequestMethod() string {
+	return xbytes.BytesToStr(x.ctx.Method())
+}
+
+// RequestURL returns the url requested by the client, scheme and host are taken from trusted proxy headers if present
 func (x *FastHttpContext) RequestURL() string {
-	return x.ctx.URI().String()
+	forwarded := x.resolveForwarded()
+	if forwarded.Proto == "" && forwarded.Host == "" {
+		return x.ctx.URI().String()
+	}
+
+	return x.RequestScheme() + "://" + x.RequestHost() + xbytes.BytesToStr(x.ctx.URI().RequestURI())
+}
+func (x *FastHttpContext) RequestScheme() string {
+	if forwarded := x.resolveForwarded(); forwarded.Proto != "" {
+		return forwarded.Proto
+	}
+	return xbytes.BytesToStr(x.ctx.URI().Scheme())
+}
+func (x *FastHttpContext) RequestHost() string {
+	if forwarded := x.resolveForwarded(); forwarded.Host != "" {
+		return forwarded.Host
+	}
+	return xbytes.BytesToStr(x.ctx.URI().Host())
 }
 func (x *FastHttpContext) RequestPath() string {
 	return xbytes.BytesToStr(x.ctx.URI().Path())
@@ -357,28 +473,33 @@ func (x *FastHttpContext) GetRemoteIP() string {
 	return x.ctx.RemoteIP().String()
 }
 
+// GetRealIP returns the client ip, proxy headers are only honored when the remote peer is a trusted proxy
 func (x *FastHttpContext) GetRealIP() string {
-	var ips []string
-
-	// 从 X-Forwarded-For 获取所有 IP
-	if forwardedFor := x.ctx.Request.Header.Peek("X-Forwarded-For"); len(forwardedFor) > 0 {
-		// 分割所有 IP 地址
-		ipList := bytes.Split(forwardedFor, []byte(","))
-		for _, ip := range ipList {
-			// 去除空格
-			ip = bytes.TrimSpace(ip)
-			if len(ip) > 0 {
-				ips = append(ips, string(ip))
-			}
-		}
-	}
+	return x.resolveForwarded().IP
+}
 
-	// 如果没有 X-Forwarded-For，则添加 RemoteIP
-	if len(ips) == 0 {
-		ips = append(ips, x.ctx.RemoteIP().String())
+func (x *FastHttpContext) resolveForwarded() *host.ForwardedInfo {
+	headers := &host.ProxyHeaders{
+		Forwarded:       x.peekAllHeader(host.Header_Forwarded),
+		XForwardedFor:   x.peekAllHeader(host.Header_XForwardedFor),
+		XForwardedProto: x.peekAllHeader(host.Header_XForwardedProto),
+		XForwardedHost:  x.peekAllHeader(host.Header_XForwardedHost),
+		XRealIP:         x.GetHeader(host.Header_XRealIP),
 	}
+	return host.ResolveForwarded(x.GetRemoteIP(), headers, x.trustedProxies)
+}
 
-	return strings.Join(ips, "\n")
+// peekAllHeader joins repeated headers by ","
+func (x *FastHttpContext) peekAllHeader(key string) string {
+	values := x.ctx.Request.Header.PeekAll(key)
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return string(values[0])
+	default:
+		return string(bytes.Join(values, []byte(",")))
+	}
 }
 
 func (x *FastHttpContext) UserAgent() string {
@@ -407,6 +528,11 @@ func (x *FastHttpContext) Reset() {
 	x.ctx = nil
 	x.sess = nil
 	x.sessStore = nil
+	x.sessCookieName = ""
+	x.trustedProxies = nil
+	x.maxBodySize = 0
+	x.bodyExceeded = false
+	x.viewEngine = nil
 	x.cookieEncryptor = nil
 	x.mapPool = nil
 	x.handlers = nil
@@ -0,0 +1,68 @@
+package hfasthttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hfasthttp"
+	"github.com/DreamvatLab/host/hosttest"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRegenerateSession(t *testing.T) {
+	server := miniredis.RunT(t)
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"},"SessionStore":"redis","SessionCookieName":"sid"}`,
+		hosttest.NewStubPermissions().AllowGuest("api_visit").AllowGuest("api_login").AllowGuest("api_me").ResourceHostOption(),
+		func(h *hfasthttp.FHOAuthResourceHost) {
+			h.WebRedisConfig = &xredis.RedisConfig{Addrs: []string{server.Addr()}}
+		})
+	resourceHost.AddAction("GET/visit", "api_visit", func(ctx host.IHttpContext) {
+		ctx.SetSession("visited", "yes")
+	})
+	resourceHost.AddAction("GET/login", "api_login", func(ctx host.IHttpContext) {
+		if err := ctx.RegenerateSession(); err != nil {
+			ctx.SetStatusCode(http.StatusInternalServerError)
+			return
+		}
+		ctx.SetSession("user", "u1") // Written to the new session in the same request
+	})
+	resourceHost.AddAction("GET/me", "api_me", func(ctx host.IHttpContext) {
+		ctx.WriteString(ctx.GetSessionString("visited") + "," + ctx.GetSessionString("user"))
+	})
+	s := hosttest.Start(t, resourceHost)
+
+	oldID := sessionCookie(t, s.GET(t, "/visit"))
+	newID := sessionCookie(t, s.GET(t, "/login", withSession(oldID)))
+	if newID == oldID {
+		t.Fatal("session id is not changed")
+	}
+	if server.Exists("session:" + oldID) {
+		t.Error("old session is still in redis")
+	}
+
+	if body := string(s.GET(t, "/me", withSession(newID)).Body); body != "yes,u1" {
+		t.Errorf("new session = %s, expected data kept", body)
+	}
+	if body := string(s.GET(t, "/me", withSession(oldID)).Body); body != "," {
+		t.Errorf("old session = %s, expected it's invalid", body)
+	}
+}
+
+func sessionCookie(t *testing.T, resp *hosttest.Response) string {
+	t.Helper()
+	for _, c := range (&http.Response{Header: resp.Header}).Cookies() {
+		if c.Name == "sid" && c.Value != "" {
+			return c.Value
+		}
+	}
+	t.Fatalf("session cookie is not set, status %d", resp.StatusCode)
+	return ""
+}
+
+func withSession(id string) hosttest.RequestOption {
+	return func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: "sid", Value: id})
+	}
+}
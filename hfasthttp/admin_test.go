@@ -0,0 +1,42 @@
+package hfasthttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestResourceHostAdmin(t *testing.T) {
+	resourceHost, issuer := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"},"Admin":{"Roles":8},"Service":{"ApiKey":"k1","Name":"n1"}}`, hosttest.NewStubPermissions().ResourceHostOption())
+	s := hosttest.Start(t, resourceHost)
+
+	hosttest.AssertStatus(t, s.GET(t, "/_admin/runtime"), http.StatusUnauthorized)
+
+	token, err := issuer.Mint(&hosttest.TokenClaims{Subject: "u1", Roles: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosttest.AssertStatus(t, s.GET(t, "/_admin/runtime", hosttest.WithBearer(token)), http.StatusForbidden)
+
+	token, err = issuer.Mint(&hosttest.TokenClaims{Subject: "admin", Roles: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosttest.AssertStatus(t, s.GET(t, "/_admin/runtime", hosttest.WithBearer(token)), http.StatusOK)
+	hosttest.AssertStatus(t, s.GET(t, "/_admin/pprof/goroutine?debug=1", hosttest.WithBearer(token)), http.StatusOK)
+
+	var config struct{ Service map[string]string }
+	hosttest.DecodeJSON(t, s.GET(t, "/_admin/config", hosttest.WithBearer(token)), &config)
+	if config.Service["ApiKey"] != "******" || config.Service["Name"] != "n1" {
+		t.Errorf("config dump %v, expected ApiKey redacted", config.Service)
+	}
+
+	resp := s.PUT(t, "/_admin/loglevel", `{"Level":"debug","Seconds":60}`, hosttest.WithBearer(token))
+	hosttest.AssertStatus(t, resp, http.StatusOK)
+	if level := host.GetLogLevel(); level != "debug" {
+		t.Errorf("log level %s, expected debug", level)
+	}
+	host.SetLogLevel("error", 0)
+}
@@ -0,0 +1,90 @@
+package hfasthttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/valyala/fasthttp"
+)
+
+// bodyLimitReader fails with fasthttp.ErrBodyTooLarge once more than n bytes are read,
+// the host responds 413 and closes the connection as the rest of the body is left unread
+type bodyLimitReader struct {
+	ctx *FastHttpContext
+	r   io.Reader
+	n   int64
+}
+
+func (x *bodyLimitReader) Read(p []byte) (int, error) {
+	if x.n < 0 {
+		return 0, fasthttp.ErrBodyTooLarge
+	}
+	if int64(len(p)) > x.n+1 {
+		p = p[:x.n+1] // Read one more byte to detect oversize bodies
+	}
+	n, err := x.r.Read(p)
+	x.n -= int64(n)
+	if x.n < 0 {
+		x.ctx.bodyExceeded = true
+		return n + int(x.n), fasthttp.ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// GetBodyStream returns request body as a stream. When the host streams request bodies,
+// bodies larger than MaxRequestBodySize are read from the connection without buffering them in memory.
+// Reading beyond the body limit of the route fails with fasthttp.ErrBodyTooLarge, and the host responds 413 after handlers
+func (x *FastHttpContext) GetBodyStream() io.Reader {
+	stream := x.ctx.RequestBodyStream()
+	if stream == nil {
+		return bytes.NewReader(x.ctx.Request.Body())
+	}
+	if x.maxBodySize > 0 {
+		return &bodyLimitReader{ctx: x, r: stream, n: int64(x.maxBodySize)}
+	}
+	return stream
+}
+
+// bodyTooLarge checks Content-Length against the body limit of the route, streamed chunked bodies are checked while reading
+func (x *FastHttpContext) bodyTooLarge() bool {
+	if x.maxBodySize <= 0 {
+		return false
+	}
+	if contentLength := x.ctx.Request.Header.ContentLength(); contentLength >= 0 {
+		return contentLength > x.maxBodySize
+	}
+	if x.ctx.Request.IsBodyStream() {
+		return false
+	}
+	return len(x.ctx.Request.Body()) > x.maxBodySize
+}
+
+// body returns the whole body, a streamed chunked body is read into memory within the body limit.
+// Beyond the limit it returns nil and fasthttp.ErrBodyTooLarge
+func (x *FastHttpContext) body() ([]byte, error) {
+	if x.maxBodySize <= 0 || !x.ctx.Request.IsBodyStream() || x.ctx.Request.Header.ContentLength() >= 0 {
+		return x.ctx.Request.Body(), nil
+	}
+	if x.bodyExceeded {
+		return nil, xerr.WithStack(fasthttp.ErrBodyTooLarge)
+	}
+
+	data, err := io.ReadAll(x.GetBodyStream())
+	if err != nil {
+		if !errors.Is(err, fasthttp.ErrBodyTooLarge) {
+			xerr.LogError(err)
+		}
+		return nil, xerr.WithStack(err)
+	}
+	x.ctx.Request.SetBody(data)
+	return x.ctx.Request.Body(), nil
+}
+
+// respondBodyTooLarge responds 413 and closes the connection as the rest of the body is not read
+func respondBodyTooLarge(ctx *fasthttp.RequestCtx) {
+	ctx.Error("request body too large", http.StatusRequestEntityTooLarge)
+	ctx.SetConnectionClose() // Error resets the response so it's set after
+}
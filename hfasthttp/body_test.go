@@ -0,0 +1,78 @@
+package hfasthttp_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBodyLimit(t *testing.T) {
+	resourceHost, _ := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"},"MaxRequestBodySize":1024,"StreamRequestBody":true}`,
+		hosttest.NewStubPermissions().AllowGuest("api_small").AllowGuest("api_default").AllowGuest("api_stream").ResourceHostOption())
+	echoLength := func(ctx host.IHttpContext) {
+		ctx.WriteString(strconv.Itoa(len(ctx.GetBodyBytes())))
+	}
+	resourceHost.AddActions(
+		host.NewAction("POST/small", "api_small", echoLength).SetMaxBodySize(10),
+		host.NewAction("POST/default", "api_default", echoLength),
+		host.NewAction("POST/stream", "api_stream", func(ctx host.IHttpContext) {
+			data, err := io.ReadAll(ctx.GetBodyStream())
+			if errors.Is(err, fasthttp.ErrBodyTooLarge) {
+				ctx.SetStatusCode(http.StatusRequestEntityTooLarge)
+				return
+			}
+			ctx.WriteString(strconv.Itoa(len(data)))
+		}).SetMaxBodySize(2048),
+	)
+	resourceHost.POST("/raw", echoLength) // Routes outside actions have the limit of the host
+	s := hosttest.Start(t, resourceHost)
+
+	tests := []struct {
+		path    string
+		size    int
+		chunked bool
+		status  int
+	}{
+		// Limit of the action
+		{"/small", 10, false, http.StatusOK},
+		{"/small", 11, false, http.StatusRequestEntityTooLarge},
+		// MaxRequestBodySize of the host
+		{"/default", 1024, false, http.StatusOK},
+		{"/default", 1025, false, http.StatusRequestEntityTooLarge},
+		{"/raw", 1024, true, http.StatusOK},
+		{"/raw", 1025, true, http.StatusRequestEntityTooLarge},
+		// Streamed beyond MaxRequestBodySize within the limit of the action
+		{"/stream", 2048, false, http.StatusOK},
+		{"/stream", 2048, true, http.StatusOK},
+		{"/stream", 2049, false, http.StatusRequestEntityTooLarge},
+		// Chunked bodies have no Content-Length, the reader stops at the limit
+		{"/stream", 2049, true, http.StatusRequestEntityTooLarge},
+		// Chunked bodies read by GetBodyBytes
+		{"/small", 10, true, http.StatusOK},
+		{"/small", 11, true, http.StatusRequestEntityTooLarge},
+		{"/default", 1024, true, http.StatusOK},
+		{"/default", 1025, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		var body io.Reader = strings.NewReader(strings.Repeat("a", tt.size))
+		if tt.chunked {
+			body = struct{ io.Reader }{body} // Unknown length
+		}
+		resp, err := s.Request(http.MethodPost, tt.path, body)
+		if err != nil {
+			t.Fatalf("POST %s of %d bytes: %v", tt.path, tt.size, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("POST %s of %d bytes, chunked %v: status %d, expected %d", tt.path, tt.size, tt.chunked, resp.StatusCode, tt.status)
+		} else if tt.status == http.StatusOK && string(resp.Body) != strconv.Itoa(tt.size) {
+			t.Errorf("POST %s of %d bytes: read %s bytes", tt.path, tt.size, resp.Body)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package hfasthttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestDeclaredPermissions(t *testing.T) {
+	// The store says otherwise, code wins
+	permissions := hosttest.NewStubPermissions().AllowGuest("api_orders_list")
+
+	resourceHost, issuer := hosttest.NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"}}`, permissions.ResourceHostOption())
+	resourceHost.AddGlobalPreHandlers(false, resourceHost.AuthHandler)
+	ok := func(ctx host.IHttpContext) {
+		ctx.WriteString("ok")
+	}
+	resourceHost.AddActionGroups(host.NewActionGroup(nil, []*host.Action{
+		host.NewAction("GET/orders", "api_orders_list", ok),
+		host.NewAction("GET/orders/public", "api_orders_public", ok).Require(&host.ActionPermission{AllowAnonymous: true}),
+	}).Require(&host.ActionPermission{AllowedRoles: 4, Level: 2, Scopes: []string{"orders"}}))
+
+	s := hosttest.Start(t, resourceHost)
+
+	hosttest.AssertStatus(t, s.GET(t, "/orders/public"), http.StatusOK)
+	hosttest.AssertStatus(t, s.GET(t, "/orders"), http.StatusUnauthorized)
+	for _, claims := range []*hosttest.TokenClaims{
+		{Subject: "u1", Roles: 4, Level: 2},
+		{Subject: "u1", Roles: 4, Level: 1, Scopes: []string{"orders"}},
+		{Subject: "u1", Roles: 2, Level: 2, Scopes: []string{"orders"}},
+	} {
+		token, err := issuer.Mint(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosttest.AssertStatus(t, s.GET(t, "/orders", hosttest.WithBearer(token)), http.StatusUnauthorized)
+	}
+	token, err := issuer.Mint(&hosttest.TokenClaims{Subject: "u1", Roles: 6, Level: 2, Scopes: []string{"orders"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosttest.AssertStatus(t, s.GET(t, "/orders", hosttest.WithBearer(token)), http.StatusOK)
+
+	// Sync publishes declared permissions to the store, a second sync has nothing to write
+	if err := resourceHost.SyncPermissions(); err != nil {
+		t.Fatal(err)
+	}
+	if permissions.CheckRouteWithLevel("api", "orders", "list", 0, 0, nil) || !permissions.CheckRouteWithLevel("api", "orders", "list", 4, 2, []string{"orders"}) {
+		t.Error("declared permission is not synced")
+	}
+	if !permissions.CheckRouteWithLevel("api", "orders", "public", 0, 0, nil) {
+		t.Error("anonymous permission is not synced")
+	}
+	count, err := host.SyncActionPermissions(resourceHost.Actions, permissions, permissions)
+	if err != nil || count != 0 {
+		t.Errorf("second sync wrote %d items: %v", count, err)
+	}
+}
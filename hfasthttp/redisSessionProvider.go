@@ -0,0 +1,118 @@
+package hfasthttp
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/fasthttp/session/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSessionProvider stores sessions in redis so that they can be shared by all instances behind a load balancer
+type redisSessionProvider struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisSessionProvider creates a session provider backed by redis, both single node and cluster configs are supported
+func NewRedisSessionProvider(keyPrefix string, redisConfig *xredis.RedisConfig) (session.Provider, error) {
+	if keyPrefix == "" {
+		return nil, xerr.New("keyPrefix cannot be empty")
+	}
+	if redisConfig == nil || len(redisConfig.Addrs) == 0 || redisConfig.Addrs[0] == "" {
+		return nil, xerr.New("cannot find 'Redis.Addrs' config")
+	}
+
+	return &redisSessionProvider{
+		client:    xredis.NewClient(redisConfig),
+		keyPrefix: keyPrefix,
+	}, nil
+}
+
+// Close closes the redis client
+func (x *redisSessionProvider) Close() error {
+	return xerr.WithStack(x.client.Close())
+}
+
+func (x *redisSessionProvider) getKey(id []byte) string {
+	return x.keyPrefix + ":" + string(id)
+}
+
+func (x *redisSessionProvider) Get(id []byte) ([]byte, error) {
+	r, err := x.client.Get(context.Background(), x.getKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return r, xerr.WithStack(err)
+}
+
+func (x *redisSessionProvider) Save(id, data []byte, expiration time.Duration) error {
+	err := x.client.Set(context.Background(), x.getKey(id), data, expiration).Err()
+	return xerr.WithStack(err)
+}
+
+// Regenerate moves the session data to the new id, the old id becomes invalid
+func (x *redisSessionProvider) Regenerate(id, newID []byte, expiration time.Duration) error {
+	goctx := context.Background()
+	key := x.getKey(id)
+	newKey := x.getKey(newID)
+
+	data, err := x.client.Get(goctx, key).Bytes()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return xerr.WithStack(err)
+	}
+
+	// Rename does not work across cluster slots, so copy and delete instead
+	err = x.client.Set(goctx, newKey, data, expiration).Err()
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	return xerr.WithStack(x.client.Del(goctx, key).Err())
+}
+
+func (x *redisSessionProvider) Destroy(id []byte) error {
+	err := x.client.Del(context.Background(), x.getKey(id)).Err()
+	return xerr.WithStack(err)
+}
+
+// Count scans session keys instead of KEYS which blocks redis, it's still O(N) and meant for diagnostics
+func (x *redisSessionProvider) Count() int {
+	goctx := context.Background()
+	pattern := x.getKey([]byte("*"))
+	var count atomic.Int64
+	scan := func(goctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(goctx, 0, pattern, 1000).Iterator()
+		for iter.Next(goctx) {
+			count.Add(1)
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := x.client.(*redis.ClusterClient); ok {
+		// Keys are spread over masters, each of them is scanned
+		err = cluster.ForEachMaster(goctx, func(goctx context.Context, client *redis.Client) error {
+			return scan(goctx, client)
+		})
+	} else {
+		err = scan(goctx, x.client)
+	}
+	if xerr.LogError(err) {
+		return 0
+	}
+	return int(count.Load())
+}
+
+// NeedGC returns false, redis expires the keys by itself
+func (x *redisSessionProvider) NeedGC() bool {
+	return false
+}
+
+func (x *redisSessionProvider) GC() error {
+	return nil
+}
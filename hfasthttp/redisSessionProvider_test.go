@@ -0,0 +1,62 @@
+package hfasthttp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisSessionProvider(t *testing.T) {
+	if _, err := NewRedisSessionProvider("", &xredis.RedisConfig{Addrs: []string{"127.0.0.1:6379"}}); err == nil {
+		t.Error("empty key prefix is accepted")
+	}
+	if _, err := NewRedisSessionProvider("session", nil); err == nil {
+		t.Error("nil redis config is accepted")
+	}
+
+	server := miniredis.RunT(t)
+	provider, err := NewRedisSessionProvider("session", &xredis.RedisConfig{Addrs: []string{server.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.(*redisSessionProvider).Close()
+	server.Set("other", "1")
+
+	if data, err := provider.Get([]byte("s1")); err != nil || data != nil {
+		t.Fatalf("Get() of a missing session = %v, %v", data, err)
+	}
+	if err := provider.Save([]byte("s1"), []byte("d1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Save([]byte("s2"), []byte("d2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("session:s1"); ttl != time.Minute {
+		t.Errorf("ttl %v, expected expiration of the session", ttl)
+	}
+	if count := provider.Count(); count != 2 {
+		t.Errorf("Count() = %d, expected keys of the prefix only", count)
+	}
+
+	if err := provider.Regenerate([]byte("s1"), []byte("s3"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := provider.Get([]byte("s1")); data != nil {
+		t.Error("old id is still valid after Regenerate")
+	}
+	if data, _ := provider.Get([]byte("s3")); string(data) != "d1" {
+		t.Errorf("Get() of the new id = %s, expected data moved", data)
+	}
+	if err := provider.Regenerate([]byte("missing"), []byte("s4"), time.Minute); err != nil || server.Exists("session:s4") {
+		t.Errorf("Regenerate() of a missing session created the new id: %v", err)
+	}
+
+	if err := provider.Destroy([]byte("s2")); err != nil {
+		t.Fatal(err)
+	}
+	if count := provider.Count(); count != 1 {
+		t.Errorf("Count() = %d after Destroy", count)
+	}
+}
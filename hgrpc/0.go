@@ -7,6 +7,7 @@ import (
 	"github.com/DreamvatLab/go/xbytes"
 	"github.com/DreamvatLab/go/xconv"
 	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
 
 	_ "github.com/DreamvatLab/host/hconsul" // call init function in /hconsul/consul.go to register resolver
 	oauth2go "github.com/DreamvatLab/oauth2go/core"
@@ -118,6 +119,8 @@ type NewClientOptions struct {
 	JwtToken           string // JWT token for authentication
 	MaxCallRecvMsgSize int    // Maximum size of received messages
 	MaxCallSendMsgSize int    // Maximum size of sent messages
+	// Closes the connection when the host stops if set, e.g. host.GetLifecycle()
+	Lifecycle *host.Lifecycle
 }
 
 // NewClient creates a gRPC client connection
@@ -168,6 +171,13 @@ func NewClient(options *NewClientOptions) (*grpc.ClientConn, error) {
 	if err != nil {
 		return nil, xerr.WithStack(err)
 	}
+	if options.Lifecycle != nil {
+		name := options.URL
+		if name == "" {
+			name = options.ServiceName // url of consul has the token
+		}
+		options.Lifecycle.AddCloser("grpc client "+name, r)
+	}
 
 	return r, nil
 }
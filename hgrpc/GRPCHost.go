@@ -1,11 +1,18 @@
 package hgrpc
 
 import (
+	"context"
+	"errors"
 	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/DreamvatLab/go/xconfig"
 	"github.com/DreamvatLab/go/xerr"
 	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/host"
 	"github.com/DreamvatLab/host/hservice"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	panichandler "github.com/kazegusuri/grpc-panic-handler"
@@ -24,24 +31,50 @@ type GRPCServiceHost struct {
 	GRPCServer     *grpc.Server
 	MaxRecvMsgSize int
 	MaxSendMsgSize int
+	// Admin endpoints served by a small http server on Admin.ListenAddr, disabled if nil
+	Admin       *host.AdminOptions
+	admin       *host.Admin
+	adminServer *http.Server
+	// Seconds Run waits for open calls when it shuts the host down by itself after a failure, default 30
+	ShutdownTimeoutSeconds int
+	stopping               atomic.Bool
+	shutdownOnce           sync.Once
+	shutdownErr            error
 }
 
 func NewGRPCServiceHost(cp xconfig.IConfigProvider, options ...GRPCOption) IGRPCServiceHost {
+	x, err := NewGRPCServiceHostE(cp, options...)
+	xerr.FatalIfErr(err)
+	return x
+}
+
+// NewGRPCServiceHostE creates the host, errors of all bad config keys are returned as host.ConfigErrors
+func NewGRPCServiceHostE(cp xconfig.IConfigProvider, options ...GRPCOption) (IGRPCServiceHost, error) {
 	x := new(GRPCServiceHost)
-	cp.GetStruct("@this", &x)
+	var errs host.ConfigErrors
+	errs.Append("", cp.GetStruct("@this", &x))
 	x.ConfigProvider = cp
 
 	for _, o := range options {
 		o(x)
 	}
 
-	x.BuildGRPCServiceHost()
+	errs.Append("", x.BuildGRPCServiceHostE())
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	return x
+	return x, nil
 }
 
 func (x *GRPCServiceHost) BuildGRPCServiceHost() {
-	x.ServiceHost.BuildServiceHost()
+	xerr.FatalIfErr(x.BuildGRPCServiceHostE())
+}
+
+func (x *GRPCServiceHost) BuildGRPCServiceHostE() error {
+	if err := x.ServiceHost.BuildServiceHostE(); err != nil {
+		return err
+	}
 
 	if x.MaxRecvMsgSize == 0 {
 		x.MaxRecvMsgSize = 10 * 1024 * 1024
@@ -51,6 +84,22 @@ func (x *GRPCServiceHost) BuildGRPCServiceHost() {
 		x.MaxSendMsgSize = 10 * 1024 * 1024
 	}
 
+	// Admin endpoints
+	if x.Admin != nil {
+		var errs host.ConfigErrors
+		if x.Admin.ListenAddr == "" {
+			errs.Add("Admin.ListenAddr", "admin ListenAddr cannot be empty")
+		}
+		var err error
+		x.admin, err = host.NewAdmin(x.Admin, x.ConfigProvider)
+		errs.Append("Admin", err)
+		if len(errs) > 0 {
+			return errs
+		}
+		// Created before Run, so Shutdown never races with Run on it
+		x.adminServer = &http.Server{Handler: x.admin.HTTPHandler()}
+	}
+
 	// GRPC Server
 	unaryHandler := grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(panichandler.UnaryPanicHandler, receiveTokenMiddleware))
 	streamHandler := grpc.StreamInterceptor(panichandler.StreamPanicHandler)
@@ -64,6 +113,7 @@ func (x *GRPCServiceHost) BuildGRPCServiceHost() {
 		unaryHandler,
 		streamHandler,
 	)
+	return nil
 }
 
 func (x *GRPCServiceHost) GetGRPCServer() *grpc.Server {
@@ -71,15 +121,101 @@ func (x *GRPCServiceHost) GetGRPCServer() *grpc.Server {
 }
 
 func (x *GRPCServiceHost) Run() error {
+	if dump, err := host.DumpConfigSchema(x.ConfigProvider); dump {
+		return errors.Join(err, x.Shutdown(context.Background()))
+	}
+
 	if x.ListenAddr == "" {
-		xlog.Fatal("ListenAddr cannot be empty")
+		return xerr.New("ListenAddr cannot be empty")
+	}
+
+	lifecycle := x.GetLifecycle()
+	if err := lifecycle.RunStartHooks(context.Background()); err != nil {
+		return errors.Join(err, x.shutdownWithTimeout())
 	}
 
 	listen, err := net.Listen("tcp", x.ListenAddr)
 	if err != nil {
-		return xerr.WithStack(err)
+		return errors.Join(xerr.WithStack(err), x.shutdownWithTimeout())
+	}
+
+	if x.stopping.Load() { // Shut down during start, e.g. by a runner whose other host failed
+		listen.Close()
+		x.shutdownWithTimeout()
+		return nil
 	}
 
 	xlog.Infof("Listening on %s", x.ListenAddr)
-	return xerr.WithStack(x.GRPCServer.Serve(listen))
+	errs := make(chan error, 2)
+	go func() {
+		errs <- x.GRPCServer.Serve(listen)
+	}()
+
+	if x.adminServer != nil {
+		adminListener, err := net.Listen("tcp", x.Admin.ListenAddr)
+		if err != nil {
+			return errors.Join(xerr.WithStack(err), x.shutdownWithTimeout())
+		}
+		xlog.Infof("Admin listening on %s", x.Admin.ListenAddr)
+		go func() {
+			if err := x.adminServer.Serve(adminListener); err != http.ErrServerClosed {
+				errs <- err
+			}
+		}()
+	}
+
+	if err := lifecycle.RunReadyHooks(context.Background()); err != nil {
+		return errors.Join(err, x.shutdownWithTimeout())
+	}
+
+	err = <-errs
+	if x.stopping.Load() {
+		// Stopped by Shutdown, wait until it finishes, its caller gets the shutdown errors
+		x.Shutdown(context.Background())
+		return nil
+	}
+	return errors.Join(xerr.WithStack(err), x.shutdownWithTimeout())
+}
+
+// Shutdown runs stopping hooks, stops the server gracefully (forcibly once ctx is done), then runs stopped hooks and cleanups.
+// It runs once, later calls wait for the first one and return its result
+func (x *GRPCServiceHost) Shutdown(ctx context.Context) error {
+	x.shutdownOnce.Do(func() {
+		x.stopping.Store(true)
+		lifecycle := x.GetLifecycle()
+		errs := []error{lifecycle.RunStoppingHooks(ctx)}
+
+		if x.GRPCServer != nil {
+			stopped := make(chan struct{})
+			go func() {
+				x.GRPCServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				x.GRPCServer.Stop()
+				<-stopped
+				errs = append(errs, xerr.WithMessage(ctx.Err(), "grpc server stopped forcibly"))
+			}
+		}
+
+		if x.adminServer != nil {
+			errs = append(errs, xerr.WithStack(x.adminServer.Shutdown(ctx)))
+		}
+
+		errs = append(errs, lifecycle.RunStoppedHooks(ctx))
+		x.shutdownErr = errors.Join(errs...)
+	})
+	return x.shutdownErr
+}
+
+func (x *GRPCServiceHost) shutdownWithTimeout() error {
+	timeout := x.ShutdownTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 30
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return x.Shutdown(ctx)
 }
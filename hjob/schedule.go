@@ -0,0 +1,173 @@
+package hjob
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+type (
+	// Schedule returns the first run time after t, zero time means never
+	Schedule interface {
+		Next(t time.Time) time.Time
+	}
+
+	// cronSchedule is a 5 fields cron expression: minute hour day-of-month month day-of-week
+	cronSchedule struct {
+		minute, hour, dom, month, dow uint64
+		domStar, dowStar              bool
+		location                      *time.Location
+	}
+
+	// intervalSchedule runs at multiples of the interval since zero time, so replicas share run times
+	intervalSchedule struct {
+		interval time.Duration
+	}
+)
+
+var _descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses cron expressions like '*/5 * * * *', descriptors like '@daily' and intervals like '@every 30s'.
+// Cron fields support '*', lists, ranges and steps, day-of-week is 0-7 where both 0 and 7 are Sunday
+func ParseSchedule(spec string, location *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if location == nil {
+		location = time.Local
+	}
+
+	if s, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return nil, xerr.WithMessage(err, "invalid interval of '"+spec+"'")
+		}
+		if interval < time.Second {
+			return nil, xerr.Errorf("interval of '%s' is less than 1s", spec)
+		}
+		return &intervalSchedule{interval: interval}, nil
+	}
+
+	if s, ok := _descriptors[spec]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, xerr.Errorf("cron expression '%s' must have 5 fields", spec)
+	}
+
+	r := &cronSchedule{
+		location: location,
+		domStar:  fields[2] == "*",
+		dowStar:  fields[4] == "*",
+	}
+	var err error
+	if r.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if r.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if r.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if r.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if r.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if r.dow&(1<<7) != 0 {
+		r.dow |= 1
+	}
+	return r, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var r uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, xerr.Errorf("invalid step of cron field '%s'", field)
+			}
+		}
+
+		var lo, hi int
+		var err1, err2 error
+		switch {
+		case rng == "*":
+			lo, hi = min, max
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(b)
+		default:
+			lo, err1 = strconv.Atoi(rng)
+			hi = lo
+			if hasStep {
+				hi = max
+			}
+		}
+		if err1 != nil || err2 != nil || lo < min || hi > max || lo > hi {
+			return 0, xerr.Errorf("invalid cron field '%s', values must be in %d-%d", field, min, max)
+		}
+
+		for i := lo; i <= hi; i += step {
+			r |= 1 << uint(i)
+		}
+	}
+	return r, nil
+}
+
+func (x *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(x.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if x.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, x.location)
+			continue
+		}
+		if !x.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, x.location)
+			continue
+		}
+		if x.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, x.location)
+			continue
+		}
+		if x.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay follows cron: if both day-of-month and day-of-week are restricted, either matching is enough
+func (x *cronSchedule) matchDay(t time.Time) bool {
+	dom := x.dom&(1<<uint(t.Day())) != 0
+	dow := x.dow&(1<<uint(t.Weekday())) != 0
+	if x.domStar || x.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (x *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(x.interval).Add(x.interval)
+}
@@ -0,0 +1,42 @@
+package hjob
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"5 9-11 * * *", time.Date(2024, 1, 31, 11, 5, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 * *", time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1,7", time.Date(2024, 2, 4, 8, 0, 0, 0, time.UTC)},
+		{"0 8 1 * 5", time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)}, // Day-of-month or day-of-week
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 1m", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec, time.UTC)
+		if err != nil {
+			t.Errorf("ParseSchedule(%s): %v", tt.spec, err)
+			continue
+		}
+		if actual := schedule.Next(base); !actual.Equal(tt.expected) {
+			t.Errorf("ParseSchedule(%s).Next = %v, expected %v", tt.spec, actual, tt.expected)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "@every x"} {
+		if _, err := ParseSchedule(spec, time.UTC); err == nil {
+			t.Errorf("ParseSchedule(%s) expected error", spec)
+		}
+	}
+}
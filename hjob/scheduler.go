@@ -0,0 +1,263 @@
+package hjob
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/host"
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// Job runs by its Schedule until the scheduler stops, a run is skipped while the previous one is still running
+	Job struct {
+		Name           string
+		Schedule       string // Cron expression like '*/5 * * * *', descriptor like '@hourly' or interval like '@every 30s'
+		JitterSeconds  int    // Random delay before each run, spreads load of replicas which don't lock
+		TimeoutSeconds int    // ctx of a run is canceled after the timeout, 0 means no timeout
+		// Run on only one replica per scheduled time by a redis lock, requires redis config of the scheduler
+		Lock        bool
+		LockSeconds int // How long the lock of a scheduled time is kept, should exceed clock skew of replicas, default 60 or TimeoutSeconds if larger
+		Run         func(ctx context.Context) error
+		schedule    Schedule
+		running     atomic.Bool
+	}
+
+	SchedulerOptions struct {
+		KeyPrefix string // Redis key prefix of job locks, default 'job'
+		Location  string // Time zone of cron expressions like 'Asia/Shanghai', default local
+	}
+
+	// Scheduler runs background jobs, attach it to a host lifecycle to start it when the host is ready and stop it when the host stops
+	Scheduler struct {
+		options  *SchedulerOptions
+		location *time.Location
+		client   redis.UniversalClient
+		owner    string
+		lock     sync.Mutex
+		jobs     []*Job
+		ctx      context.Context
+		cancel   context.CancelFunc
+		wg       sync.WaitGroup
+	}
+)
+
+// NewScheduler creates the scheduler, options can be nil, redisConfig is only required by jobs with Lock
+func NewScheduler(options *SchedulerOptions, redisConfig *xredis.RedisConfig) (*Scheduler, error) {
+	if options == nil {
+		options = new(SchedulerOptions)
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "job"
+	}
+
+	r := &Scheduler{
+		options:  options,
+		location: time.Local,
+	}
+	if options.Location != "" {
+		var err error
+		r.location, err = time.LoadLocation(options.Location)
+		if err != nil {
+			return nil, xerr.WithStack(err)
+		}
+	}
+	if redisConfig != nil {
+		r.client = xredis.NewClient(redisConfig)
+	}
+
+	hostname, _ := os.Hostname()
+	r.owner = hostname + ":" + strconv.Itoa(os.Getpid())
+	return r, nil
+}
+
+// Attach starts the scheduler when the host is ready, stops it before the host stops serving and closes it after
+func (x *Scheduler) Attach(lifecycle *host.Lifecycle) {
+	lifecycle.OnReady("scheduler", func(ctx context.Context) error {
+		x.Start()
+		return nil
+	})
+	lifecycle.OnStopping("scheduler", x.Stop)
+	lifecycle.AddCloser("scheduler", x)
+}
+
+// Add validates jobs and adds them, jobs added after Start are started immediately
+func (x *Scheduler) Add(jobs ...*Job) error {
+	for _, job := range jobs {
+		if job.Name == "" {
+			return xerr.New("job name cannot be empty")
+		}
+		if job.Run == nil {
+			return xerr.Errorf("job '%s' has no Run func", job.Name)
+		}
+		if job.Lock && x.client == nil {
+			return xerr.Errorf("job '%s' requires redis config of the scheduler to lock", job.Name)
+		}
+		var err error
+		job.schedule, err = ParseSchedule(job.Schedule, x.location)
+		if err != nil {
+			return xerr.WithMessage(err, "invalid schedule of job '"+job.Name+"'")
+		}
+		if job.LockSeconds <= 0 {
+			job.LockSeconds = max(60, job.TimeoutSeconds)
+		}
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.jobs = append(x.jobs, jobs...)
+	if x.ctx != nil && x.ctx.Err() == nil {
+		for _, job := range jobs {
+			x.wg.Add(1)
+			go x.loop(x.ctx, job)
+		}
+	}
+	return nil
+}
+
+// Start starts all jobs, it does nothing if already started
+func (x *Scheduler) Start() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.ctx != nil {
+		return
+	}
+
+	x.ctx, x.cancel = context.WithCancel(context.Background())
+	for _, job := range x.jobs {
+		x.wg.Add(1)
+		go x.loop(x.ctx, job)
+	}
+}
+
+// Stop cancels ctx of running jobs and waits for them until ctx is done
+func (x *Scheduler) Stop(ctx context.Context) error {
+	x.lock.Lock()
+	if x.cancel != nil {
+		x.cancel()
+	}
+	x.lock.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		x.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return xerr.WithMessage(ctx.Err(), "jobs did not stop")
+	}
+}
+
+// Close closes the redis client
+func (x *Scheduler) Close() error {
+	if x.client == nil {
+		return nil
+	}
+	return x.client.Close()
+}
+
+func (x *Scheduler) loop(ctx context.Context, job *Job) {
+	defer x.wg.Done()
+
+	next := job.schedule.Next(time.Now())
+	if next.IsZero() {
+		xlog.Warnf("job '%s' will never run", job.Name)
+		return
+	}
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if job.running.CompareAndSwap(false, true) {
+			x.wg.Add(1)
+			go x.run(ctx, job, next)
+		} else {
+			xlog.Warnf("job '%s' of %s skipped, the previous run is still running", job.Name, next.Format(time.RFC3339))
+		}
+
+		next = job.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+func (x *Scheduler) run(ctx context.Context, job *Job, scheduled time.Time) {
+	defer x.wg.Done()
+	defer job.running.Store(false)
+
+	if job.JitterSeconds > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rand.N(time.Duration(job.JitterSeconds) * time.Second)):
+		}
+	}
+
+	if job.Lock {
+		locked, err := x.tryLock(ctx, job, scheduled)
+		if err != nil {
+			xlog.Errorf("failed to lock job '%s': %+v", job.Name, err)
+			return
+		}
+		if !locked {
+			xlog.Debugf("job '%s' of %s runs on another replica", job.Name, scheduled.Format(time.RFC3339))
+			return
+		}
+	}
+
+	if job.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(job.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := call(ctx, job)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			xlog.Errorf("job '%s' timed out: %+v", job.Name, err)
+		} else {
+			xlog.Errorf("job '%s' failed: %+v", job.Name, err)
+		}
+		return
+	}
+	xlog.Debugf("job '%s' done in %s", job.Name, time.Since(start))
+}
+
+// tryLock locks the scheduled time of the job, the lock is kept until it expires,
+// so replicas whose clocks are slightly behind cannot run the same scheduled time again
+func (x *Scheduler) tryLock(ctx context.Context, job *Job, scheduled time.Time) (bool, error) {
+	key := x.options.KeyPrefix + ":" + job.Name + ":" + strconv.FormatInt(scheduled.Unix(), 10)
+	ok, err := x.client.SetNX(ctx, key, x.owner, time.Duration(job.LockSeconds)*time.Second).Result()
+	return ok, xerr.WithStack(err)
+}
+
+// call runs the job, panics are returned as errors
+func call(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = xerr.Errorf("job '%s' panicked: %v", job.Name, r)
+		}
+	}()
+	return job.Run(ctx)
+}
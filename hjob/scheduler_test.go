@@ -0,0 +1,59 @@
+package hjob
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/host"
+)
+
+func TestSchedulerLifecycle(t *testing.T) {
+	scheduler, err := NewScheduler(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runs, panics atomic.Int32
+	err = scheduler.Add(&Job{
+		Name:     "count",
+		Schedule: "@every 1s",
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}, &Job{
+		Name:     "panic",
+		Schedule: "@every 1s",
+		Run: func(ctx context.Context) error {
+			panics.Add(1)
+			panic("boom")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Add(&Job{Name: "lock", Schedule: "@hourly", Lock: true, Run: func(ctx context.Context) error { return nil }}); err == nil {
+		t.Error("expected error of lock without redis")
+	}
+
+	var lifecycle host.Lifecycle
+	scheduler.Attach(&lifecycle)
+	if err := lifecycle.RunReadyHooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2100 * time.Millisecond)
+	if err := lifecycle.RunStoppingHooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs.Load() < 2 || panics.Load() < 2 {
+		t.Errorf("runs = %d, panics = %d, expected at least 2 runs of each job", runs.Load(), panics.Load())
+	}
+	stopped := runs.Load()
+	time.Sleep(1100 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Error("job ran after the scheduler stopped")
+	}
+}
@@ -11,12 +11,14 @@ import (
 	"github.com/DreamvatLab/host/hconsul"
 	"github.com/DreamvatLab/host/hgrpc"
 	"github.com/DreamvatLab/logs"
+	"google.golang.org/grpc"
 )
 
 // grpcSink implements a logging sink that sends logs to a gRPC service
 type grpcSink struct {
 	clientID         string                     // Unique identifier for the client sending logs
 	logServiceClient logs.LogEntryServiceClient // gRPC client for log service
+	conn             *grpc.ClientConn           // Connection closed by Close
 }
 
 // WriteLog writes a log entry to the gRPC service
@@ -36,6 +38,11 @@ func (o *grpcSink) WriteLog(entry *xlog.LogEntry) {
 	}
 }
 
+// Close closes the connection to the log service, BaseHost closes its LogSinks when it stops
+func (o *grpcSink) Close() error {
+	return xerr.WithStack(o.conn.Close())
+}
+
 // NewGrpcSink creates a new gRPC logging sink
 // consulConfig: Consul configuration
 // clientID: Unique identifier for the logging client
@@ -54,5 +61,6 @@ func NewGrpcSink(consulConfig *hconsul.ConsulConfig, clientID string) xlog.LogSi
 	return &grpcSink{
 		logServiceClient: logServiceClient,
 		clientID:         clientID,
+		conn:             logServiceConn,
 	}
 }
@@ -0,0 +1,52 @@
+package hosttest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func AssertStatus(t testing.TB, resp *Response, statusCode int) {
+	t.Helper()
+	if resp.StatusCode != statusCode {
+		t.Fatalf("status code is %d, expected %d. body: %s", resp.StatusCode, statusCode, resp.Body)
+	}
+}
+
+// AssertJSON compares the response body with expected semantically, expected can be a json string, []byte or any value marshaled to json
+func AssertJSON(t testing.TB, resp *Response, expected interface{}) {
+	t.Helper()
+
+	var expectedJson []byte
+	switch v := expected.(type) {
+	case string:
+		expectedJson = []byte(v)
+	case []byte:
+		expectedJson = v
+	default:
+		var err error
+		expectedJson, err = json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var expectedValue, actualValue interface{}
+	if err := json.Unmarshal(expectedJson, &expectedValue); err != nil {
+		t.Fatalf("invalid expected json: %v. %s", err, expectedJson)
+	}
+	if err := json.Unmarshal(resp.Body, &actualValue); err != nil {
+		t.Fatalf("invalid response json: %v. %s", err, resp.Body)
+	}
+	if !reflect.DeepEqual(expectedValue, actualValue) {
+		t.Fatalf("response json is %s, expected %s", resp.Body, expectedJson)
+	}
+}
+
+// DecodeJSON unmarshals the response body into objPtr
+func DecodeJSON(t testing.TB, resp *Response, objPtr interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(resp.Body, objPtr); err != nil {
+		t.Fatalf("invalid response json: %v. %s", err, resp.Body)
+	}
+}
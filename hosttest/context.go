@@ -0,0 +1,449 @@
+package hosttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DreamvatLab/go/xconv"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xhttp"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xsecurity"
+	"github.com/DreamvatLab/host"
+	"github.com/gorilla/schema"
+)
+
+var _decoder = schema.NewDecoder()
+
+func init() {
+	_decoder.IgnoreUnknownKeys(true)
+}
+
+// Context is an in-memory IHttpContext for unit testing handlers without a server.
+// Set the request fields before Run, then inspect the response fields
+type Context struct {
+	////////// Request
+	Method          string
+	Scheme          string
+	Host            string
+	Path            string
+	Query           url.Values
+	Form            url.Values // Post arguments
+	MultipartForm   *multipart.Form
+	Body            []byte
+	Headers         http.Header
+	Params          map[string]string // Route parameters
+	Cookies         map[string]string
+	Session         map[string]interface{}
+	RemoteIP        string
+	TrustedProxies  []*net.IPNet
+	CookieEncryptor xsecurity.ICookieEncryptor
+	ViewEngine      *host.ViewEngine
+
+	////////// Response
+	StatusCode         int
+	ResponseHeaders    http.Header
+	ResponseCookies    map[string]*http.Cookie
+	ResponseBody       bytes.Buffer
+	SessionRegenerated bool
+	SessionEnded       bool
+
+	items        map[string]interface{}
+	handlers     []host.RequestHandler
+	handlerIndex int
+}
+
+// NewContext creates a context requesting rawURL, e.g. "/api/users?id=1" or "https://example.com/api/users"
+func NewContext(method, rawURL string, handlers ...host.RequestHandler) *Context {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		u = &url.URL{Path: rawURL}
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = "localhost"
+	}
+
+	return &Context{
+		Method:          method,
+		Scheme:          u.Scheme,
+		Host:            u.Host,
+		Path:            u.Path,
+		Query:           u.Query(),
+		Form:            make(url.Values),
+		Headers:         make(http.Header),
+		Params:          make(map[string]string),
+		Cookies:         make(map[string]string),
+		Session:         make(map[string]interface{}),
+		RemoteIP:        "127.0.0.1",
+		StatusCode:      http.StatusOK,
+		ResponseHeaders: make(http.Header),
+		ResponseCookies: make(map[string]*http.Cookie),
+		items:           make(map[string]interface{}),
+		handlers:        handlers,
+	}
+}
+
+// Run executes the handler chain from the first handler, handlers call Next to continue the chain
+func (x *Context) Run() *Context {
+	x.handlerIndex = 0
+	if len(x.handlers) > 0 {
+		x.handlers[0](x)
+	}
+	return x
+}
+
+// HandlerIndex returns the index of the last handler reached, len(handlers)-1 means the whole chain ran
+func (x *Context) HandlerIndex() int {
+	return x.handlerIndex
+}
+
+// Completed reports whether the chain reached its last handler
+func (x *Context) Completed() bool {
+	return len(x.handlers) > 0 && x.handlerIndex == len(x.handlers)-1
+}
+
+func (x *Context) GetInnerContext() interface{} {
+	return x
+}
+
+func (x *Context) Write(p []byte) (n int, err error) {
+	return x.ResponseBody.Write(p)
+}
+
+func (x *Context) SetItem(key string, value interface{}) {
+	x.items[key] = value
+}
+func (x *Context) GetItem(key string) interface{} {
+	return x.items[key]
+}
+func (x *Context) GetItemString(key string) string {
+	return xconv.ToString(x.items[key])
+}
+func (x *Context) GetItemInt(key string) int {
+	return xconv.ToInt(x.items[key])
+}
+func (x *Context) GetItemInt32(key string) int32 {
+	return xconv.ToInt32(x.items[key])
+}
+func (x *Context) GetItemInt64(key string) int64 {
+	return xconv.ToInt64(x.items[key])
+}
+
+func (x *Context) GetRouteKey() string {
+	return x.GetItemString(host.Ctx_RouteKey)
+}
+
+func (x *Context) SetCookieKV(key, value string, options ...func(*http.Cookie)) {
+	c := &http.Cookie{Name: key, Value: value}
+	for _, o := range options {
+		o(c)
+	}
+	x.ResponseCookies[key] = c
+}
+func (x *Context) GetCookieString(key string) string {
+	return x.Cookies[key]
+}
+
+func (x *Context) SetEncryptedCookieKV(key, value string, options ...func(*http.Cookie)) {
+	if x.CookieEncryptor == nil {
+		xlog.Warn("cookieEncryptor is nil, this context does not suppot cookie encryption")
+		return
+	}
+	encryptedString, err := x.CookieEncryptor.Encrypt(key, value)
+	if xerr.LogError(err) {
+		return
+	}
+
+	x.SetCookieKV(key, encryptedString, options...)
+}
+func (x *Context) GetEncryptedCookieString(key string) (r string) {
+	if x.CookieEncryptor == nil {
+		xlog.Warn("cookieEncryptor is nil, this context does not suppot cookie encryption")
+		return
+	}
+
+	encryptedString := x.GetCookieString(key)
+	if encryptedString != "" {
+		err := x.CookieEncryptor.Decrypt(key, encryptedString, &r)
+		xerr.LogError(err)
+	}
+
+	return
+}
+
+// RemoveCookie records an expired cookie in ResponseCookies
+func (x *Context) RemoveCookie(key string, options ...func(*http.Cookie)) {
+	c := &http.Cookie{Name: key, MaxAge: -1}
+	for _, o := range options {
+		o(c)
+	}
+	x.ResponseCookies[key] = c
+}
+
+func (x *Context) SetSession(key, value string) {
+	x.SetSessionValue(key, value)
+}
+func (x *Context) SetSessionValue(key string, value interface{}) {
+	x.Session[key] = value
+}
+
+// SetSessionStruct stores obj as json like the real session
+func (x *Context) SetSessionStruct(key string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	x.SetSessionValue(key, string(data))
+	return nil
+}
+func (x *Context) GetSessionValue(key string) interface{} {
+	return x.Session[key]
+}
+func (x *Context) GetSessionString(key string) string {
+	if r, ok := x.Session[key].(string); ok {
+		return r
+	}
+	return ""
+}
+func (x *Context) GetSessionInt(key string) int {
+	return xconv.ToInt(x.Session[key])
+}
+func (x *Context) GetSessionInt64(key string) int64 {
+	return xconv.ToInt64(x.Session[key])
+}
+func (x *Context) GetSessionBool(key string) bool {
+	return xconv.ToBool(x.Session[key])
+}
+func (x *Context) GetSessionStruct(key string, objPtr interface{}) error {
+	data := x.GetSessionString(key)
+	if data == "" {
+		return nil
+	}
+	err := json.Unmarshal([]byte(data), objPtr)
+	return xerr.WithStack(err)
+}
+func (x *Context) RemoveSession(key string) {
+	delete(x.Session, key)
+}
+func (x *Context) RegenerateSession() error {
+	x.SessionRegenerated = true
+	return nil
+}
+func (x *Context) EndSession() {
+	x.Session = make(map[string]interface{})
+	x.SessionEnded = true
+}
+
+// GetFormString looks up query, post arguments and multipart values in order like fasthttp
+func (x *Context) GetFormString(key string) string {
+	if v, ok := x.Query[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	if v, ok := x.Form[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	if x.MultipartForm != nil {
+		if v := x.MultipartForm.Value[key]; len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+func (x *Context) GetFormStringDefault(key, d string) string {
+	if r := x.GetFormString(key); r != "" {
+		return r
+	}
+	return d
+}
+func (x *Context) GetFormFile(key string) (*multipart.FileHeader, error) {
+	if x.MultipartForm != nil {
+		if v := x.MultipartForm.File[key]; len(v) > 0 {
+			return v[0], nil
+		}
+	}
+	return nil, xerr.WithStack(http.ErrMissingFile)
+}
+func (x *Context) GetMultipartForm() (*multipart.Form, error) {
+	if x.MultipartForm == nil {
+		return nil, xerr.WithStack(http.ErrNotMultipart)
+	}
+	return x.MultipartForm, nil
+}
+
+func (x *Context) GetBodyString() string {
+	return string(x.Body)
+}
+func (x *Context) GetBodyBytes() []byte {
+	return x.Body
+}
+func (x *Context) GetBodyStream() io.Reader {
+	return bytes.NewReader(x.Body)
+}
+
+func (x *Context) GetParamString(key string) string {
+	return x.Params[key]
+}
+func (x *Context) GetParamInt(key string) int {
+	return xconv.ToInt(x.Params[key])
+}
+func (x *Context) GetParamInt32(key string) int32 {
+	return xconv.ToInt32(x.Params[key])
+}
+func (x *Context) GetParamInt64(key string) int64 {
+	return xconv.ToInt64(x.Params[key])
+}
+
+func (x *Context) ReadJSON(objPtr interface{}) error {
+	err := json.Unmarshal(x.Body, objPtr)
+	return xerr.WithStack(err)
+}
+func (x *Context) ReadQuery(objPtr interface{}) error {
+	err := _decoder.Decode(objPtr, x.Query)
+	return xerr.WithStack(err)
+}
+func (x *Context) ReadForm(objPtr interface{}) error {
+	err := _decoder.Decode(objPtr, x.Form)
+	return xerr.WithStack(err)
+}
+func (x *Context) ReadFormMap() (map[string][]string, error) {
+	r := make(map[string][]string, len(x.Form))
+	for k, v := range x.Form {
+		r[k] = v
+	}
+	return r, nil
+}
+
+func (x *Context) GetHeader(key string) string {
+	return x.Headers.Get(key)
+}
+func (x *Context) SetHeader(key, value string) {
+	x.ResponseHeaders.Set(key, value)
+}
+
+func (x *Context) SetStatusCode(statusCode int) {
+	x.StatusCode = statusCode
+}
+func (x *Context) GetStatusCode() int {
+	return x.StatusCode
+}
+func (x *Context) GetResponseHeader(key string) string {
+	return x.ResponseHeaders.Get(key)
+}
+func (x *Context) GetResponseBody() []byte {
+	return x.ResponseBody.Bytes()
+}
+func (x *Context) SetContentType(cType string) {
+	x.ResponseHeaders.Set("Content-Type", cType)
+}
+func (x *Context) WriteString(body string) (int, error) {
+	return x.ResponseBody.WriteString(body)
+}
+func (x *Context) WriteBytes(body []byte) (int, error) {
+	return x.ResponseBody.Write(body)
+}
+func (x *Context) WriteJsonBytes(body []byte) (int, error) {
+	x.SetContentType(xhttp.CTYPE_JSON)
+	return x.ResponseBody.Write(body)
+}
+
+// Render renders html view by ViewEngine
+func (x *Context) Render(name string, data interface{}) error {
+	return host.RenderView(x, x.ViewEngine, name, data)
+}
+
+// T translates key by the i18n set in items by I18n.Handler, key is returned as is without it
+func (x *Context) T(key string, args ...interface{}) string {
+	return host.Translate(x, key, args...)
+}
+
+func (x *Context) RequestMethod() string {
+	return x.Method
+}
+func (x *Context) RequestURL() string {
+	u := url.URL{
+		Scheme:   x.RequestScheme(),
+		Host:     x.RequestHost(),
+		Path:     x.Path,
+		RawQuery: x.Query.Encode(),
+	}
+	return u.String()
+}
+func (x *Context) RequestScheme() string {
+	if forwarded := x.resolveForwarded(); forwarded.Proto != "" {
+		return forwarded.Proto
+	}
+	return x.Scheme
+}
+func (x *Context) RequestHost() string {
+	if forwarded := x.resolveForwarded(); forwarded.Host != "" {
+		return forwarded.Host
+	}
+	return x.Host
+}
+func (x *Context) RequestPath() string {
+	return x.Path
+}
+func (x *Context) GetRemoteIP() string {
+	return x.RemoteIP
+}
+
+// GetRealIP returns the client ip, proxy headers are only honored when RemoteIP is in TrustedProxies
+func (x *Context) GetRealIP() string {
+	return x.resolveForwarded().IP
+}
+
+func (x *Context) resolveForwarded() *host.ForwardedInfo {
+	headers := &host.ProxyHeaders{
+		Forwarded:       strings.Join(x.Headers.Values(host.Header_Forwarded), ","),
+		XForwardedFor:   strings.Join(x.Headers.Values(host.Header_XForwardedFor), ","),
+		XForwardedProto: strings.Join(x.Headers.Values(host.Header_XForwardedProto), ","),
+		XForwardedHost:  strings.Join(x.Headers.Values(host.Header_XForwardedHost), ","),
+		XRealIP:         x.GetHeader(host.Header_XRealIP),
+	}
+	return host.ResolveForwarded(x.RemoteIP, headers, x.TrustedProxies)
+}
+
+func (x *Context) UserAgent() string {
+	return x.GetHeader("User-Agent")
+}
+
+func (x *Context) Redirect(url string, statusCode int) {
+	x.ResponseHeaders.Set("Location", url)
+	x.StatusCode = statusCode
+}
+func (x *Context) CopyBodyAndStatusCode(resp *http.Response) {
+	x.StatusCode = resp.StatusCode
+	data, err := io.ReadAll(resp.Body)
+	if xerr.LogError(err) {
+		return
+	}
+	x.ResponseBody.Write(data)
+}
+
+func (x *Context) Next() {
+	if x.handlerIndex < len(x.handlers)-1 {
+		x.handlerIndex++
+		x.handlers[x.handlerIndex](x)
+	}
+}
+
+// Reset clears items and the response, request fields are kept so the context can be run again
+func (x *Context) Reset() {
+	x.items = make(map[string]interface{})
+	x.StatusCode = http.StatusOK
+	x.ResponseHeaders = make(http.Header)
+	x.ResponseCookies = make(map[string]*http.Cookie)
+	x.ResponseBody.Reset()
+	x.SessionRegenerated = false
+	x.SessionEnded = false
+	x.handlerIndex = 0
+}
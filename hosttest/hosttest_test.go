@@ -0,0 +1,103 @@
+package hosttest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hresource"
+	"github.com/DreamvatLab/oauth2go/model"
+)
+
+func TestResourceHost(t *testing.T) {
+	permissions := NewStubPermissions().
+		AllowGuest("api_public").
+		AllowRoles("api_admin", 4, 0)
+
+	resourceHost, issuer := NewResourceHost(t, `{"ListenAddr":":0","Log":{"Level":"error"}}`, permissions.ResourceHostOption())
+	resourceHost.AddGlobalPreHandlers(false, resourceHost.AuthHandler)
+	resourceHost.AddAction("GET/public", "api_public", func(ctx host.IHttpContext) {
+		ctx.WriteJsonBytes([]byte(`{"ok":true}`))
+	})
+	resourceHost.AddAction("GET/admin", "api_admin", func(ctx host.IHttpContext) {
+		ctx.WriteJsonBytes([]byte(`{"user":"` + ctx.GetItemString(host.Ctx_UserID) + `"}`))
+	})
+
+	s := Start(t, resourceHost)
+
+	AssertJSON(t, s.GET(t, "/public"), map[string]interface{}{"ok": true})
+	AssertStatus(t, s.GET(t, "/admin"), http.StatusUnauthorized)
+
+	token, err := issuer.Mint(&TokenClaims{Subject: "u1", Roles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertStatus(t, s.GET(t, "/admin", WithBearer(token)), http.StatusUnauthorized)
+
+	token, err = issuer.Mint(&TokenClaims{Subject: "u1", Roles: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := s.GET(t, "/admin", WithBearer(token))
+	AssertStatus(t, resp, http.StatusOK)
+	AssertJSON(t, resp, `{"user":"u1"}`)
+
+	token, err = issuer.Mint(&TokenClaims{Subject: "u1", Roles: 4, TTL: -time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertStatus(t, s.GET(t, "/admin", WithBearer(token)), http.StatusUnauthorized)
+
+	// changes after the host is built are seen by the auditor
+	permissions.AllowAnyUser("api_admin")
+	token, err = issuer.Mint(&TokenClaims{Subject: "u2", Roles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertStatus(t, s.GET(t, "/admin", WithBearer(token)), http.StatusOK)
+}
+
+func TestContextAuthHandler(t *testing.T) {
+	issuer, err := NewTokenIssuer("https://issuer", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	permissions := NewStubPermissions().AllowRoles("api_orders", 4, 0)
+	resourceHost := &hresource.OAuthResourceHost{
+		OAuthOptions: &model.Resource{ValidIssuers: []string{issuer.Issuer}, ValidAudiences: []string{issuer.Audience}},
+		PublicKey:    issuer.PublicKey(),
+	}
+	resourceHost.PermissionAuditor = permissions
+
+	var userID string
+	newContext := func() *Context {
+		ctx := NewContext(http.MethodGet, "/orders", resourceHost.AuthHandler, func(ctx host.IHttpContext) {
+			ctx.Next() // does nothing at the end of the chain
+		}, func(ctx host.IHttpContext) {
+			userID = ctx.GetItemString(host.Ctx_UserID)
+			ctx.WriteString("ok")
+		})
+		ctx.SetItem(host.Ctx_RouteKey, "api_orders")
+		return ctx
+	}
+
+	ctx := newContext().Run()
+	if ctx.Completed() || ctx.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous request passed, status %d", ctx.StatusCode)
+	}
+
+	token, err := issuer.Mint(&TokenClaims{Subject: "u1", Roles: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = newContext()
+	ctx.Headers.Set("Authorization", "Bearer "+token)
+	ctx.Run()
+	if !ctx.Completed() || ctx.HandlerIndex() != 2 {
+		t.Fatalf("chain stopped at %d, status %d", ctx.HandlerIndex(), ctx.StatusCode)
+	}
+	if userID != "u1" || ctx.ResponseBody.String() != "ok" {
+		t.Fatalf("user is '%s', body is '%s'", userID, ctx.ResponseBody.String())
+	}
+}
@@ -0,0 +1,188 @@
+package hosttest
+
+import (
+	"sync"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xsecurity"
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hfasthttp"
+)
+
+// StubPermissions is an in-memory route and permission provider, it is also a permission auditor
+// which sees changes made after the host is built
+type StubPermissions struct {
+	routes      map[string]*xdto.Route
+	permissions map[string]*xdto.Permission
+	auditor     xsecurity.IPermissionAuditor
+	lock        sync.RWMutex
+}
+
+func NewStubPermissions() *StubPermissions {
+	return &StubPermissions{
+		routes:      make(map[string]*xdto.Route),
+		permissions: make(map[string]*xdto.Permission),
+	}
+}
+
+// Configure replaces the providers and auditor of the host, call it in a host option before the host is built
+func (x *StubPermissions) Configure(baseHost *host.BaseHost) {
+	baseHost.PermissionProvider = x
+	baseHost.RouteProvider = x
+	baseHost.PermissionAuditor = x
+}
+
+// Allow protects routeKey with permission. Missing parts of routeKey are wildcards like the auditor's fallback,
+// e.g. 'area_controller' covers every action of the controller and 'area' covers the whole area
+func (x *StubPermissions) Allow(routeKey string, permission *xdto.Permission) *StubPermissions {
+	area, controller, action := host.GetRoutesByKey(routeKey)
+	routeKey = area + host.Seperator_Route + controller + host.Seperator_Route + action
+	if permission.ID == "" {
+		permission.ID = routeKey
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.permissions[permission.ID] = permission
+	x.routes[routeKey] = &xdto.Route{
+		ID:            routeKey,
+		Permission_ID: permission.ID,
+		Area:          area,
+		Controller:    controller,
+		Action:        action,
+	}
+	x.auditor = nil
+	return x
+}
+
+func (x *StubPermissions) AllowGuest(routeKey string) *StubPermissions {
+	return x.Allow(routeKey, &xdto.Permission{IsAllowGuest: true})
+}
+
+func (x *StubPermissions) AllowAnyUser(routeKey string) *StubPermissions {
+	return x.Allow(routeKey, &xdto.Permission{IsAllowAnyUser: true})
+}
+
+func (x *StubPermissions) AllowRoles(routeKey string, roles int64, level int32, scopes ...string) *StubPermissions {
+	return x.Allow(routeKey, &xdto.Permission{AllowedRoles: roles, Level: level, Scopes: scopes})
+}
+
+func (x *StubPermissions) getAuditor() xsecurity.IPermissionAuditor {
+	x.lock.RLock()
+	r := x.auditor
+	x.lock.RUnlock()
+	if r == nil {
+		r = xsecurity.NewPermissionAuditor(x, x)
+		x.lock.Lock()
+		x.auditor = r
+		x.lock.Unlock()
+	}
+	return r
+}
+
+////////// IPermissionAuditor
+
+// GetRoutePermission implements host.IRoutePermissionAuditor, so route dumps show stub permissions
+func (x *StubPermissions) GetRoutePermission(area, controller, action string) *xdto.Permission {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return host.FindRoutePermission(x.routes, x.permissions, area, controller, action)
+}
+
+func (x *StubPermissions) CheckPermission(permissionID string, userRoles int64, userScopes []string) bool {
+	return x.getAuditor().CheckPermission(permissionID, userRoles, userScopes)
+}
+func (x *StubPermissions) CheckPermissionWithLevel(permissionID string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckPermissionWithLevel(permissionID, userRoles, userLevel, userScopes)
+}
+func (x *StubPermissions) CheckRoute(area, controller, action string, userRoles int64, userScopes []string) bool {
+	return x.getAuditor().CheckRoute(area, controller, action, userRoles, userScopes)
+}
+func (x *StubPermissions) CheckRouteWithLevel(area, controller, action string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckRouteWithLevel(area, controller, action, userRoles, userLevel, userScopes)
+}
+func (x *StubPermissions) CheckRouteKeyWithLevel(routeKey string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckRouteKeyWithLevel(routeKey, userRoles, userLevel, userScopes)
+}
+
+////////// IPermissionProvider
+
+func (x *StubPermissions) CreatePermission(permission *xdto.Permission) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.permissions[permission.ID] = permission
+	x.auditor = nil
+	return nil
+}
+func (x *StubPermissions) GetPermission(id string) (*xdto.Permission, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	if r, ok := x.permissions[id]; ok {
+		return r, nil
+	}
+	return nil, xerr.Errorf("permission '%s' does not exist", id)
+}
+func (x *StubPermissions) UpdatePermission(permission *xdto.Permission) error {
+	return x.CreatePermission(permission)
+}
+func (x *StubPermissions) RemovePermission(id string) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.permissions, id)
+	x.auditor = nil
+	return nil
+}
+func (x *StubPermissions) GetPermissions() (map[string]*xdto.Permission, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	r := make(map[string]*xdto.Permission, len(x.permissions))
+	for k, v := range x.permissions {
+		r[k] = v
+	}
+	return r, nil
+}
+
+////////// IRouteProvider
+
+func (x *StubPermissions) CreateRoute(route *xdto.Route) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.routes[route.ID] = route
+	x.auditor = nil
+	return nil
+}
+func (x *StubPermissions) GetRoute(id string) (*xdto.Route, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	if r, ok := x.routes[id]; ok {
+		return r, nil
+	}
+	return nil, xerr.Errorf("route '%s' does not exist", id)
+}
+func (x *StubPermissions) UpdateRoute(route *xdto.Route) error {
+	return x.CreateRoute(route)
+}
+func (x *StubPermissions) RemoveRoute(id string) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.routes, id)
+	x.auditor = nil
+	return nil
+}
+func (x *StubPermissions) GetRoutes() (map[string]*xdto.Route, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	r := make(map[string]*xdto.Route, len(x.routes))
+	for k, v := range x.routes {
+		r[k] = v
+	}
+	return r, nil
+}
+
+// ResourceHostOption configures a resource host by Configure
+func (x *StubPermissions) ResourceHostOption() hfasthttp.ResourceHostOption {
+	return func(h *hfasthttp.FHOAuthResourceHost) {
+		x.Configure(&h.BaseHost)
+	}
+}
@@ -0,0 +1,73 @@
+package hosttest
+
+import (
+	"testing"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/host"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Route runs requests of a route through handlers without a server, the way a host runs an action after routing.
+// Handlers are usually the middleware under test followed by the action
+type Route struct {
+	Method   string
+	URL      string
+	RouteKey string
+	Handlers []host.RequestHandler
+}
+
+func NewRoute(method, rawURL, routeKey string, handlers ...host.RequestHandler) *Route {
+	return &Route{
+		Method:   method,
+		URL:      rawURL,
+		RouteKey: routeKey,
+		Handlers: handlers,
+	}
+}
+
+// Run runs a new context of the route, setups prepare the request before the handlers run and can be nil
+func (x *Route) Run(setups ...func(*Context)) *Context {
+	ctx := NewContext(x.Method, x.URL, x.Handlers...)
+	ctx.SetItem(host.Ctx_RouteKey, x.RouteKey)
+	for _, setup := range setups {
+		if setup != nil {
+			setup(ctx)
+		}
+	}
+	return ctx.Run()
+}
+
+// AsUser sets the user of a request the way auth handlers do
+func AsUser(userID string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.SetItem(host.Ctx_UserID, userID)
+	}
+}
+
+// WithRequestHeader sets a request header
+func WithRequestHeader(key, value string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.Headers.Set(key, value)
+	}
+}
+
+// WithCookie sets a request cookie
+func WithCookie(name, value string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.Cookies[name] = value
+	}
+}
+
+// WithBody sets the request body
+func WithBody(body string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.Body = []byte(body)
+	}
+}
+
+// NewRedisConfig starts an in-memory redis server for the test and returns the config connecting to it
+func NewRedisConfig(t testing.TB) *xredis.RedisConfig {
+	server := miniredis.RunT(t)
+	return &xredis.RedisConfig{Addrs: []string{server.Addr()}}
+}
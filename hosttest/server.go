@@ -0,0 +1,190 @@
+package hosttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xhttp"
+	"github.com/DreamvatLab/host"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// BaseURL is the url prefix of every request sent by Server.Client, the host part is ignored by the in-memory listener
+const BaseURL = "http://hosttest"
+
+// Server is a web host served on an in-memory listener
+type Server struct {
+	Host   host.IWebHost
+	Client *http.Client
+	ln     *fasthttputil.InmemoryListener
+	done   chan error
+}
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type RequestOption func(*http.Request)
+
+// NewConfigProvider creates a config provider from a json string instead of configs.json
+func NewConfigProvider(configJson string) (xconfig.IConfigProvider, error) {
+	r := &xconfig.JsonConfigProvider{
+		RawJson:          []byte(configJson),
+		MapConfiguration: make(xconfig.MapConfiguration),
+	}
+	err := json.Unmarshal(r.RawJson, &r.MapConfiguration)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	return r, nil
+}
+
+// Start serves webHost on an in-memory listener, the server is closed when the test finishes
+func Start(t testing.TB, webHost host.IWebHost) *Server {
+	t.Helper()
+
+	ln := fasthttputil.NewInmemoryListener()
+	r := &Server{
+		Host: webHost,
+		ln:   ln,
+		done: make(chan error, 1),
+	}
+	r.Client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return ln.Dial()
+			},
+		},
+	}
+
+	go func() {
+		r.done <- webHost.Serve(ln)
+	}()
+
+	t.Cleanup(func() {
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return r
+}
+
+// Close closes the listener and waits for the host to stop serving
+func (x *Server) Close() error {
+	if x.ln == nil {
+		return nil
+	}
+	x.Client.CloseIdleConnections()
+	err := x.ln.Close()
+	x.ln = nil
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	return <-x.done
+}
+
+// Request sends a request to path. body can be nil, string, []byte, io.Reader or any other value which is sent as json
+func (x *Server) Request(method, path string, body interface{}, options ...RequestOption) (*Response, error) {
+	var reader io.Reader
+	var contentType string
+	switch v := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(v)
+	case []byte:
+		reader = bytes.NewReader(v)
+	case io.Reader:
+		reader = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, xerr.WithStack(err)
+		}
+		reader = bytes.NewReader(data)
+		contentType = xhttp.CTYPE_JSON
+	}
+
+	req, err := http.NewRequest(method, BaseURL+path, reader)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, o := range options {
+		o(req)
+	}
+
+	resp, err := x.Client.Do(req)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
+}
+
+func (x *Server) GET(t testing.TB, path string, options ...RequestOption) *Response {
+	t.Helper()
+	r, err := x.Request(http.MethodGet, path, nil, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (x *Server) POST(t testing.TB, path string, body interface{}, options ...RequestOption) *Response {
+	t.Helper()
+	r, err := x.Request(http.MethodPost, path, body, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (x *Server) PUT(t testing.TB, path string, body interface{}, options ...RequestOption) *Response {
+	t.Helper()
+	r, err := x.Request(http.MethodPut, path, body, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (x *Server) DELETE(t testing.TB, path string, options ...RequestOption) *Response {
+	t.Helper()
+	r, err := x.Request(http.MethodDelete, path, nil, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func WithHeader(key, value string) RequestOption {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+func WithBearer(token string) RequestOption {
+	return WithHeader(xhttp.HEADER_AUTH, host.AuthType_Bearer+" "+token)
+}
@@ -0,0 +1,122 @@
+package hosttest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xslice"
+	"github.com/DreamvatLab/host/hfasthttp"
+	oauth2core "github.com/DreamvatLab/oauth2go/core"
+	"github.com/DreamvatLab/oauth2go/model"
+	"github.com/pascaldekloe/jwt"
+)
+
+// TokenIssuer mints bearer tokens accepted by an OAuthResourceHost configured with ResourceHostOption
+type TokenIssuer struct {
+	Issuer     string
+	Audience   string
+	Algorithm  string
+	PrivateKey *rsa.PrivateKey
+}
+
+type TokenClaims struct {
+	Subject string
+	Roles   int64
+	Level   int32
+	Scopes  []string
+	TTL     time.Duration // Default 1 hour, negative for an expired token
+	Extra   map[string]interface{}
+}
+
+// NewTokenIssuer creates an issuer with a random rsa key
+func NewTokenIssuer(issuer, audience string) (*TokenIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	return &TokenIssuer{
+		Issuer:     issuer,
+		Audience:   audience,
+		Algorithm:  jwt.PS256,
+		PrivateKey: key,
+	}, nil
+}
+
+func (x *TokenIssuer) PublicKey() *rsa.PublicKey {
+	return &x.PrivateKey.PublicKey
+}
+
+// ResourceHostOption makes the resource host trust this issuer instead of reading PublicKeyPath
+func (x *TokenIssuer) ResourceHostOption() hfasthttp.ResourceHostOption {
+	return func(h *hfasthttp.FHOAuthResourceHost) {
+		h.PublicKey = x.PublicKey()
+		h.SigningAlgorithm = x.Algorithm
+		if h.OAuthOptions == nil {
+			h.OAuthOptions = new(model.Resource)
+		}
+		if !xslice.HasStr(h.OAuthOptions.ValidIssuers, x.Issuer) {
+			h.OAuthOptions.ValidIssuers = append(h.OAuthOptions.ValidIssuers, x.Issuer)
+		}
+		if !xslice.HasStr(h.OAuthOptions.ValidAudiences, x.Audience) {
+			h.OAuthOptions.ValidAudiences = append(h.OAuthOptions.ValidAudiences, x.Audience)
+		}
+	}
+}
+
+// Mint signs a token with the claims read by OAuthResourceHost.AuthHandler
+func (x *TokenIssuer) Mint(claims *TokenClaims) (string, error) {
+	if claims == nil {
+		claims = new(TokenClaims)
+	}
+	ttl := claims.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+
+	now := time.Now().UTC()
+	c := new(jwt.Claims)
+	c.Subject = claims.Subject
+	c.Issuer = x.Issuer
+	c.Audiences = []string{x.Audience}
+	c.Issued = jwt.NewNumericTime(now)
+	c.Expires = jwt.NewNumericTime(now.Add(ttl))
+	c.Set = make(map[string]interface{}, len(claims.Extra)+3)
+	for k, v := range claims.Extra {
+		c.Set[k] = v
+	}
+	c.Set[oauth2core.Claim_Role] = claims.Roles
+	c.Set[oauth2core.Claim_Level] = claims.Level
+	if len(claims.Scopes) > 0 {
+		c.Set[oauth2core.Claim_Scope] = claims.Scopes
+	}
+
+	token, err := c.RSASign(x.Algorithm, x.PrivateKey)
+	if err != nil {
+		return "", xerr.WithStack(err)
+	}
+	return string(token), nil
+}
+
+// NewResourceHost creates a resource host from configJson which trusts a new issuer of 'https://issuer' and audience 'api',
+// the test fails if the host cannot be built
+func NewResourceHost(t testing.TB, configJson string, options ...hfasthttp.ResourceHostOption) (*hfasthttp.FHOAuthResourceHost, *TokenIssuer) {
+	t.Helper()
+
+	cp, err := NewConfigProvider(configJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := NewTokenIssuer("https://issuer", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceHost, err := hfasthttp.NewFHOAuthResourceHostE(cp, append([]hfasthttp.ResourceHostOption{issuer.ResourceHostOption()}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resourceHost.(*hfasthttp.FHOAuthResourceHost), issuer
+}
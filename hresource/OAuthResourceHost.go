@@ -35,24 +35,30 @@ type OAuthResourceHost struct {
 }
 
 func (x *OAuthResourceHost) BuildOAuthResourceHost() {
-	x.BaseHost.BuildBaseHost()
+	xerr.FatalIfErr(x.BuildOAuthResourceHostE())
+}
 
-	if x.OAuthOptions == nil {
-		xlog.Fatal("OAuth secion in configuration is missing")
-	}
+func (x *OAuthResourceHost) BuildOAuthResourceHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BaseHost.BuildBaseHostE())
 
-	if x.PublicKeyPath == "" {
-		xlog.Fatal("public key path cannot be empty")
+	if x.PublicKey == nil && x.PublicKeyPath == "" {
+		errs.Add("PublicKeyPath", "public key path cannot be empty")
 	}
 	if x.OAuthOptions == nil {
-		xlog.Fatal("oauth options cannot be nil")
-	}
-	if len(x.OAuthOptions.ValidIssuers) == 0 {
-		xlog.Fatal("Issuers cannot be empty")
+		errs.Add("OAuth", "OAuth section in configuration is missing")
+	} else {
+		if len(x.OAuthOptions.ValidIssuers) == 0 {
+			errs.Add("OAuth.ValidIssuers", "issuers cannot be empty")
+		}
+		if len(x.OAuthOptions.ValidAudiences) == 0 {
+			errs.Add("OAuth.ValidAudiences", "audiences cannot be empty")
+		}
 	}
-	if len(x.OAuthOptions.ValidAudiences) == 0 {
-		xlog.Fatal("Audiences cannot be empty")
+	if len(errs) > 0 {
+		return errs
 	}
+
 	if x.SigningAlgorithm == "" {
 		x.SigningAlgorithm = jwt.PS256
 	}
@@ -66,25 +72,34 @@ func (x *OAuthResourceHost) BuildOAuthResourceHost() {
 		}
 	}
 
-	// read public certificate
-	cert, err := xrsa.ReadCertFromFile(x.PublicKeyPath)
-	xerr.FatalIfErr(err)
-	x.PublicKey = cert.PublicKey.(*rsa.PublicKey)
+	// read public certificate, unless the key is given directly
+	if x.PublicKey == nil {
+		cert, err := xrsa.ReadCertFromFile(x.PublicKeyPath)
+		if err != nil {
+			errs.Append("PublicKeyPath", err)
+			return errs
+		}
+		publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			errs.Add("PublicKeyPath", "certificate does not contain a rsa public key")
+			return errs
+		}
+		x.PublicKey = publicKey
+	}
+
+	return nil
 }
 
 func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
-	routeKey := ctx.GetItemString(host.Ctx_RouteKey)
-	area, controller, action := host.GetRoutesByKey(routeKey)
-
 	authHeader := ctx.GetHeader(xhttp.HEADER_AUTH)
 	if authHeader == "" {
-		if x.PermissionAuditor.CheckRouteWithLevel(area, controller, action, 0, 0, []string{}) {
+		if host.CheckActionPermission(ctx, x.PermissionAuditor, 0, 0, []string{}) {
 			ctx.Next() // 没有提供令牌，但是允许匿名访问
 			return
 		} else {
 			// 没有提供令牌，且不允许匿名访问
 			ctx.SetStatusCode(http.StatusUnauthorized)
-			ctx.WriteString("Authorization header is missing")
+			ctx.WriteString(ctx.T("Authorization header is missing"))
 			return
 		}
 	}
@@ -111,7 +126,7 @@ func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
 	if !isNotExpired {
 		ctx.SetStatusCode(http.StatusUnauthorized)
 		msgCode := "current time not in token's valid period"
-		ctx.WriteString(msgCode)
+		ctx.WriteString(ctx.T(msgCode))
 		xlog.Warnf("%s. Remote IP:[%s]", msgCode, ctx.GetRemoteIP())
 		return
 	}
@@ -121,7 +136,7 @@ func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
 	if !isValidAudience {
 		ctx.SetStatusCode(http.StatusUnauthorized)
 		msgCode := "invalid audience"
-		ctx.WriteString(msgCode)
+		ctx.WriteString(ctx.T(msgCode))
 		xlog.Warnf("%s. Required: %v, has: %v, IP:[%s]", msgCode, x.OAuthOptions.ValidAudiences, jwtClaims.Audiences, ctx.GetRemoteIP())
 		return
 	}
@@ -131,7 +146,7 @@ func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
 	if !isValidIssuer {
 		ctx.SetStatusCode(http.StatusUnauthorized)
 		msgCode := "invalid issuer"
-		ctx.WriteString(msgCode)
+		ctx.WriteString(ctx.T(msgCode))
 		xlog.Warnf("%s. Required: %v, has: %v, IP:[%s]", msgCode, x.OAuthOptions.ValidIssuers, jwtClaims.Issuer, ctx.GetRemoteIP())
 		return
 	}
@@ -169,7 +184,7 @@ func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
 			userScopes = []string{}
 		}
 
-		if x.PermissionAuditor.CheckRouteWithLevel(area, controller, action, roles, int32(level), userScopes) {
+		if host.CheckActionPermission(ctx, x.PermissionAuditor, roles, int32(level), userScopes) {
 			// Has permission, allow
 			ctx.SetItem(host.Ctx_UserID, jwtClaims.Subject) // UserID
 			ctx.SetItem(host.Ctx_Claims, &jwtClaims.Set)    // RL00001
@@ -183,5 +198,5 @@ func (x *OAuthResourceHost) AuthHandler(ctx host.IHttpContext) {
 
 	// Not allow
 	ctx.SetStatusCode(http.StatusUnauthorized)
-	ctx.WriteString(msgCode)
+	ctx.WriteString(ctx.T(msgCode))
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/DreamvatLab/go/xconv"
+	"github.com/DreamvatLab/go/xerr"
 	"github.com/DreamvatLab/host"
 )
 
@@ -23,7 +24,16 @@ type ServiceHost struct {
 }
 
 func (x *ServiceHost) BuildServiceHost() {
-	x.BaseHost.BuildBaseHost()
+	xerr.FatalIfErr(x.BuildServiceHostE())
+}
+
+func (x *ServiceHost) BuildServiceHostE() error {
+	var errs host.ConfigErrors
+	errs.Append("", x.BaseHost.BuildBaseHostE())
+	if x.ListenAddr == "" {
+		errs.Add("ListenAddr", "ListenAddr cannot be empty")
+	}
+	return errs.Err()
 }
 
 func (x *ServiceHost) GetListenAddr() string {
@@ -2,7 +2,6 @@ package htoken
 
 import (
 	"github.com/DreamvatLab/go/xerr"
-	"github.com/DreamvatLab/go/xlog"
 	"github.com/DreamvatLab/go/xsecurity/xrsa"
 	"github.com/DreamvatLab/host"
 	"github.com/DreamvatLab/oauth2go"
@@ -36,12 +35,17 @@ type OAuthTokenHost struct {
 }
 
 func (x *OAuthTokenHost) BuildOAuthTokenHost() {
+	xerr.FatalIfErr(x.BuildOAuthTokenHostE())
+}
+
+func (x *OAuthTokenHost) BuildOAuthTokenHostE() error {
 	// slog.Info(x.SecureCookieHost.GetEncryptedCooke)
 
-	x.BaseHost.BuildBaseHost()
+	var errs host.ConfigErrors
+	errs.Append("", x.BaseHost.BuildBaseHostE())
 
 	if x.PrivateKeyPath == "" {
-		xlog.Fatal("missing 'PrivateKeyPath' filed in configuration")
+		errs.Add("PrivateKeyPath", "missing 'PrivateKeyPath' field in configuration")
 	}
 	if x.UserJsonSessionKey == "" {
 		x.UserJsonSessionKey = "USERJSON"
@@ -53,12 +57,20 @@ func (x *OAuthTokenHost) BuildOAuthTokenHost() {
 		x.ClientStoreKey = "CLIENTS"
 	}
 	if x.TokenStoreKey == "" {
-		x.ClientStoreKey = "t:"
+		x.TokenStoreKey = "t:"
+	}
+	if (x.ClientStore == nil || x.TokenStore == nil) && x.RedisConfig == nil {
+		errs.Add("ConnectionStrings.Redis", "redis is required by client store and token store")
+	}
+	if x.CookieEncryptor == nil {
+		errs.Append("", x.SecureCookieHost.BuildSecureCookieHostE())
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 
 	////////// CookieEncryptor
 	if x.CookieEncryptor == nil {
-		x.SecureCookieHost.BuildSecureCookieHost()
 		x.CookieEncryptor = x.GetCookieEncryptor()
 	}
 
@@ -66,7 +78,10 @@ func (x *OAuthTokenHost) BuildOAuthTokenHost() {
 	if x.PrivateKey == nil {
 		var err error
 		x.PrivateKey, err = xrsa.ReadPrivateKeyFromFile(x.PrivateKeyPath)
-		xerr.FatalIfErr(err)
+		if err != nil {
+			errs.Append("PrivateKeyPath", err)
+			return errs
+		}
 	}
 
 	////////// SecretEncryptor
@@ -76,24 +91,13 @@ func (x *OAuthTokenHost) BuildOAuthTokenHost() {
 
 	////////// ClientStore
 	if x.ClientStore == nil {
-		if x.ClientStoreKey == "" {
-			xlog.Fatal("ClientStoreKey cannot be empty")
-		}
-		if x.RedisConfig == nil {
-			xlog.Fatal("missing 'Redis' section in configuration")
-		}
 		x.ClientStore = redis.NewRedisClientStore(x.ClientStoreKey, x.SecretEncryptor, x.RedisConfig)
 	}
 	////////// TokenStore
 	if x.TokenStore == nil {
-		if x.TokenStoreKey == "" {
-			xlog.Fatal("TokenStoreKey cannot be empty")
-		}
-		if x.RedisConfig == nil {
-			xlog.Fatal("missing 'Redis' section in configuration")
-		}
 		x.TokenStore = redis.NewRedisTokenStore(x.TokenStoreKey, x.SecretEncryptor, x.RedisConfig)
 	}
 
 	x.TokenHost.BuildTokenHost()
+	return nil
 }
@@ -0,0 +1,78 @@
+package hupload
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+type localStorage struct {
+	root string
+}
+
+// NewLocalStorage creates storage which saves files under root directory
+func NewLocalStorage(root string) (IStorage, error) {
+	if root == "" {
+		return nil, xerr.New("local storage root cannot be empty")
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	if err = os.MkdirAll(root, 0755); err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	return &localStorage{root: root}, nil
+}
+
+// getPath maps key into root, '..' can't escape root
+func (x *localStorage) getPath(key string) string {
+	return filepath.Join(x.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (x *localStorage) Save(ctx context.Context, key string, r io.Reader, contentType string) error {
+	filePath := x.getPath(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return xerr.WithStack(err)
+	}
+
+	// Write to a temp file then rename, so readers never see partial files
+	f, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	tmpPath := f.Name()
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return xerr.WithStack(err)
+	}
+
+	return nil
+}
+
+func (x *localStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(x.getPath(key))
+	return f, xerr.WithStack(err)
+}
+
+func (x *localStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(x.getPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return xerr.WithStack(err)
+}
@@ -0,0 +1,53 @@
+package hupload
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+type memoryStorage struct {
+	lock  sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage creates storage which keeps files in memory, for tests
+func NewMemoryStorage() IStorage {
+	return &memoryStorage{
+		files: make(map[string][]byte),
+	}
+}
+
+func (x *memoryStorage) Save(ctx context.Context, key string, r io.Reader, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+
+	x.lock.Lock()
+	x.files[key] = data
+	x.lock.Unlock()
+	return nil
+}
+
+func (x *memoryStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	x.lock.RLock()
+	data, ok := x.files[key]
+	x.lock.RUnlock()
+
+	if !ok {
+		return nil, xerr.WithStack(os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (x *memoryStorage) Delete(ctx context.Context, key string) error {
+	x.lock.Lock()
+	delete(x.files, key)
+	x.lock.Unlock()
+	return nil
+}
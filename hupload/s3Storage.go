@@ -0,0 +1,229 @@
+package hupload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	_s3Algorithm   = "AWS4-HMAC-SHA256"
+	_s3Service     = "s3"
+	_s3EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type (
+	// S3Options configures an S3 compatible storage (AWS S3, MinIO, Ceph ...)
+	S3Options struct {
+		Endpoint    string // e.g. 'https://s3.us-east-1.amazonaws.com' or 'http://minio:9000'
+		Region      string // Default 'us-east-1'
+		Bucket      string
+		AccessKey   string
+		SecretKey   string
+		VirtualHost bool // Use 'bucket.endpoint/key' instead of path style 'endpoint/bucket/key'
+	}
+
+	// s3Storage talks to S3 REST API with signature v4
+	s3Storage struct {
+		options  *S3Options
+		endpoint *url.URL
+		client   *http.Client
+	}
+)
+
+// NewS3Storage creates S3 compatible storage
+func NewS3Storage(options *S3Options) (IStorage, error) {
+	if options == nil {
+		return nil, xerr.New("s3 options cannot be nil")
+	}
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, xerr.New("s3 'Endpoint' and 'Bucket' cannot be empty")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(options.Endpoint, "/"))
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, xerr.Errorf("invalid s3 endpoint '%s'", options.Endpoint)
+	}
+
+	return &s3Storage{
+		options:  options,
+		endpoint: endpoint,
+		client:   http.DefaultClient,
+	}, nil
+}
+
+// Save spools r into a temp file to get its length and hash, which are required by PutObject
+func (x *s3Storage) Save(ctx context.Context, key string, r io.Reader, contentType string) error {
+	f, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return xerr.WithStack(err)
+	}
+
+	req, err := x.newRequest(ctx, http.MethodPut, key, io.NopCloser(f), hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := x.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (x *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := x.newRequest(ctx, http.MethodGet, key, nil, _s3EmptySHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := x.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (x *s3Storage) Delete(ctx context.Context, key string) error {
+	req, err := x.newRequest(ctx, http.MethodDelete, key, nil, _s3EmptySHA256)
+	if err != nil {
+		return err
+	}
+
+	resp, err := x.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (x *s3Storage) do(req *http.Request) (*http.Response, error) {
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, xerr.WithStack(os.ErrNotExist)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, xerr.Errorf("s3 %s '%s' failed: %s %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+
+	return resp, nil
+}
+
+func (x *s3Storage) newRequest(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	u := *x.endpoint
+	objectPath := "/" + strings.TrimPrefix(key, "/")
+	if x.options.VirtualHost {
+		u.Host = x.options.Bucket + "." + u.Host
+	} else {
+		objectPath = "/" + x.options.Bucket + objectPath
+	}
+	u.Path = u.Path + objectPath
+	u.RawPath = s3EncodePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	x.sign(req, u.RawPath, payloadHash, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds signature v4 headers, see https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (x *s3Storage) sign(req *http.Request, canonicalURI, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"", // No query string
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + x.options.Region + "/" + _s3Service + "/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := _s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+x.options.SecretKey), date)
+	key = hmacSHA256(key, x.options.Region)
+	key = hmacSHA256(key, _s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", _s3Algorithm+" Credential="+x.options.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EncodePath encodes each byte except unreserved characters and '/'
+func s3EncodePath(p string) string {
+	const hexChars = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hexChars[c>>4])
+		sb.WriteByte(hexChars[c&15])
+	}
+	return sb.String()
+}
@@ -0,0 +1,52 @@
+package hupload
+
+import (
+	"context"
+	"io"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	Storage_Local  = "local"
+	Storage_Memory = "memory"
+	Storage_S3     = "s3"
+)
+
+type (
+	// IStorage stores uploaded files by key, keys use '/' as separator
+	IStorage interface {
+		// Save stores content of r, nothing is stored if reading r fails
+		Save(ctx context.Context, key string, r io.Reader, contentType string) error
+		Open(ctx context.Context, key string) (io.ReadCloser, error)
+		Delete(ctx context.Context, key string) error
+	}
+
+	// StorageOptions selects and configures a storage
+	//
+	//	{"Type": "local", "Root": "/data/uploads"}
+	//	{"Type": "s3", "S3": {"Endpoint": "http://minio:9000", "Bucket": "uploads", "AccessKey": "...", "SecretKey": "..."}}
+	StorageOptions struct {
+		Type string // local (default), memory or s3
+		Root string // Root directory of local storage
+		S3   *S3Options
+	}
+)
+
+// NewStorage creates storage by options
+func NewStorage(options *StorageOptions) (IStorage, error) {
+	if options == nil {
+		return nil, xerr.New("storage options cannot be nil")
+	}
+
+	switch options.Type {
+	case "", Storage_Local:
+		return NewLocalStorage(options.Root)
+	case Storage_Memory:
+		return NewMemoryStorage(), nil
+	case Storage_S3:
+		return NewS3Storage(options.S3)
+	default:
+		return nil, xerr.Errorf("unsupported storage type '%s'", options.Type)
+	}
+}
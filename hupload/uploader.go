@@ -0,0 +1,300 @@
+package hupload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host"
+	"github.com/valyala/fasthttp"
+)
+
+const _sniffLen = 512 // Bytes used by http.DetectContentType
+
+var _errTooLarge = errors.New("file too large")
+
+type (
+	// UploadOptions rules of uploaded files, content types are checked against sniffed content,
+	// so renaming a file won't bypass them
+	UploadOptions struct {
+		MaxFileSize       int64    // Max size of each file, 0 means only the route's body limit applies
+		MaxFiles          int      // Max number of files in a request, 0 means no limit
+		MaxValueSize      int64    // Max size of each non-file field, default 1MB
+		AllowedTypes      []string // e.g. 'image/png', 'image/*', empty means any
+		AllowedExtensions []string // e.g. '.png', case insensitive, empty means any
+		KeyPrefix         string   // Keys are '{KeyPrefix}/2006/01/02/{id}{ext}'
+	}
+
+	// UploadedFile metadata of a stored file
+	UploadedFile struct {
+		Field        string
+		FileName     string // File name sent by client
+		Key          string // Storage key
+		Size         int64
+		ContentType  string // Sniffed content type
+		DeclaredType string // Content type sent by client
+		MD5          string // Hex
+		SHA256       string // Hex
+	}
+
+	// UploadResult files and non-file fields of a multipart request
+	UploadResult struct {
+		Files  []*UploadedFile
+		Values map[string][]string
+	}
+
+	// UploadError is a rejected upload, StatusCode is the suggested http status
+	UploadError struct {
+		StatusCode int
+		Field      string
+		FileName   string
+		Message    string // English message, also the i18n key
+	}
+
+	Uploader struct {
+		storage IStorage
+		options *UploadOptions
+	}
+)
+
+func (x *UploadError) Error() string {
+	if x.FileName != "" {
+		return x.Message + ": " + x.FileName
+	}
+	return x.Message
+}
+
+// NewUploader creates uploader, options can be nil
+func NewUploader(storage IStorage, options *UploadOptions) *Uploader {
+	if options == nil {
+		options = new(UploadOptions)
+	}
+	if options.MaxValueSize <= 0 {
+		options.MaxValueSize = 1 << 20
+	}
+	for i, ext := range options.AllowedExtensions {
+		ext = strings.ToLower(ext)
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		options.AllowedExtensions[i] = ext
+	}
+
+	return &Uploader{
+		storage: storage,
+		options: options,
+	}
+}
+
+// GetStorage returns storage of this uploader
+func (x *Uploader) GetStorage() IStorage {
+	return x.storage
+}
+
+// Upload streams files of a multipart request to storage, read the body by GetBodyStream,
+// so uploads larger than MaxRequestBodySize are not buffered when the host streams request bodies
+func (x *Uploader) Upload(ctx host.IHttpContext) (*UploadResult, error) {
+	mediaType, params, err := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, &UploadError{StatusCode: http.StatusBadRequest, Message: "multipart/form-data request expected"}
+	}
+
+	return x.UploadMultipart(context.Background(), ctx.GetBodyStream(), params["boundary"])
+}
+
+// UploadMultipart streams files of a multipart body to storage, stored files are deleted if any part fails
+func (x *Uploader) UploadMultipart(goctx context.Context, body io.Reader, boundary string) (r *UploadResult, err error) {
+	r = &UploadResult{
+		Files:  make([]*UploadedFile, 0, 1),
+		Values: make(map[string][]string),
+	}
+	defer func() {
+		if errors.Is(err, fasthttp.ErrBodyTooLarge) {
+			// Reading beyond the body limit of the route, files and values are checked by their own limits
+			err = &UploadError{StatusCode: http.StatusRequestEntityTooLarge, Message: "request body too large"}
+		}
+		if err != nil {
+			for _, f := range r.Files {
+				xerr.LogError(x.storage.Delete(goctx, f.Key))
+			}
+			r = nil
+		}
+	}()
+
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return r, nil
+		}
+		if errors.Is(err, fasthttp.ErrBodyTooLarge) {
+			return r, err
+		}
+		if err != nil {
+			return r, &UploadError{StatusCode: http.StatusBadRequest, Message: "invalid multipart body"}
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, x.options.MaxValueSize+1))
+			if err != nil {
+				return r, xerr.WithStack(err)
+			}
+			if int64(len(value)) > x.options.MaxValueSize {
+				return r, &UploadError{StatusCode: http.StatusRequestEntityTooLarge, Field: part.FormName(), Message: "form value too large"}
+			}
+			r.Values[part.FormName()] = append(r.Values[part.FormName()], string(value))
+			continue
+		}
+
+		if x.options.MaxFiles > 0 && len(r.Files) >= x.options.MaxFiles {
+			return r, &UploadError{StatusCode: http.StatusBadRequest, Field: part.FormName(), FileName: part.FileName(), Message: "too many files"}
+		}
+
+		file, err := x.savePart(goctx, part)
+		if err != nil {
+			return r, err
+		}
+		r.Files = append(r.Files, file)
+	}
+}
+
+func (x *Uploader) savePart(goctx context.Context, part *multipart.Part) (*UploadedFile, error) {
+	r := &UploadedFile{
+		Field:        part.FormName(),
+		FileName:     filepath.Base(part.FileName()),
+		DeclaredType: part.Header.Get("Content-Type"),
+	}
+	newErr := func(statusCode int, msg string) error {
+		return &UploadError{StatusCode: statusCode, Field: r.Field, FileName: r.FileName, Message: msg}
+	}
+
+	ext := strings.ToLower(path.Ext(r.FileName))
+	if !x.isExtensionAllowed(ext) {
+		return nil, newErr(http.StatusUnsupportedMediaType, "file extension not allowed")
+	}
+
+	////////// sniff content type
+	head := make([]byte, _sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, xerr.WithStack(err)
+	}
+	head = head[:n]
+	r.ContentType = http.DetectContentType(head)
+	if !x.isTypeAllowed(r.ContentType) {
+		return nil, newErr(http.StatusUnsupportedMediaType, "file type not allowed")
+	}
+
+	////////// stream to storage
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	var content io.Reader = io.MultiReader(bytes.NewReader(head), part)
+	if x.options.MaxFileSize > 0 {
+		content = &sizeLimitReader{r: content, n: x.options.MaxFileSize}
+	}
+	counter := &countWriter{}
+	content = io.TeeReader(content, io.MultiWriter(md5Hash, sha256Hash, counter))
+
+	r.Key = x.newKey(ext)
+	if err = x.storage.Save(goctx, r.Key, content, r.ContentType); err != nil {
+		if errors.Is(err, _errTooLarge) {
+			return nil, newErr(http.StatusRequestEntityTooLarge, "file too large")
+		}
+		return nil, err
+	}
+
+	r.Size = counter.n
+	r.MD5 = hex.EncodeToString(md5Hash.Sum(nil))
+	r.SHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	return r, nil
+}
+
+func (x *Uploader) newKey(ext string) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	key := time.Now().UTC().Format("2006/01/02") + "/" + hex.EncodeToString(id) + ext
+	if x.options.KeyPrefix != "" {
+		key = strings.TrimSuffix(x.options.KeyPrefix, "/") + "/" + key
+	}
+	return key
+}
+
+func (x *Uploader) isExtensionAllowed(ext string) bool {
+	if len(x.options.AllowedExtensions) == 0 {
+		return true
+	}
+	for _, allowed := range x.options.AllowedExtensions {
+		if allowed == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *Uploader) isTypeAllowed(contentType string) bool {
+	if len(x.options.AllowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range x.options.AllowedTypes {
+		if allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleUploadErr writes UploadError with its status code and translated message, other errors are handled by host.HandleErr
+func HandleUploadErr(err error, ctx host.IHttpContext) bool {
+	var uploadErr *UploadError
+	if errors.As(err, &uploadErr) {
+		msg := ctx.T(uploadErr.Message)
+		if uploadErr.FileName != "" {
+			msg += ": " + uploadErr.FileName
+		}
+		ctx.SetStatusCode(uploadErr.StatusCode)
+		ctx.WriteString(msg)
+		return true
+	}
+	return host.HandleErr(err, ctx)
+}
+
+type sizeLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (x *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	x.n -= int64(n)
+	if x.n < 0 {
+		return n, _errTooLarge
+	}
+	return n, err
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (x *countWriter) Write(p []byte) (int, error) {
+	x.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,136 @@
+package hupload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+var _png = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+
+func newMultipart(t *testing.T, files map[string][]byte, values map[string]string) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for k, v := range values {
+		if err := w.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range files {
+		fw, err := w.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	w.Close()
+	return body, w.Boundary()
+}
+
+func TestUploadMultipart(t *testing.T) {
+	storage := NewMemoryStorage()
+	uploader := NewUploader(storage, &UploadOptions{
+		AllowedTypes:      []string{"image/*"},
+		AllowedExtensions: []string{"png"},
+		KeyPrefix:         "avatars/",
+	})
+
+	body, boundary := newMultipart(t, map[string][]byte{"a.png": _png}, map[string]string{"name": "test"})
+	r, err := uploader.UploadMultipart(context.Background(), body, boundary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files) != 1 || r.Values["name"][0] != "test" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	f := r.Files[0]
+	sum := sha256.Sum256(_png)
+	if f.ContentType != "image/png" || f.Size != int64(len(_png)) || f.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected file %+v", f)
+	}
+
+	rc, err := storage.Open(context.Background(), f.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); !bytes.Equal(data, _png) {
+		t.Fatal("stored content mismatch")
+	}
+}
+
+func TestUploadMultipartRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    *UploadOptions
+		files      map[string][]byte
+		statusCode int
+	}{
+		{"extension", &UploadOptions{AllowedExtensions: []string{".jpg"}}, map[string][]byte{"a.png": _png}, http.StatusUnsupportedMediaType},
+		{"renamed file", &UploadOptions{AllowedTypes: []string{"image/png"}}, map[string][]byte{"a.png": []byte("<html></html>")}, http.StatusUnsupportedMediaType},
+		{"size", &UploadOptions{MaxFileSize: 10}, map[string][]byte{"a.png": _png}, http.StatusRequestEntityTooLarge},
+		{"count", &UploadOptions{MaxFiles: 1}, map[string][]byte{"a.png": _png, "b.png": _png}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMemoryStorage().(*memoryStorage)
+			body, boundary := newMultipart(t, tt.files, nil)
+			_, err := NewUploader(storage, tt.options).UploadMultipart(context.Background(), body, boundary)
+
+			var uploadErr *UploadError
+			if !errors.As(err, &uploadErr) || uploadErr.StatusCode != tt.statusCode {
+				t.Fatalf("expected status %d, got %v", tt.statusCode, err)
+			}
+			if len(storage.files) != 0 {
+				t.Fatal("stored files are not cleaned up")
+			}
+		})
+	}
+}
+
+// limitedBody fails like the body stream of a route whose body limit is n
+type limitedBody struct {
+	r io.Reader
+	n int
+}
+
+func (x *limitedBody) Read(p []byte) (int, error) {
+	if x.n <= 0 {
+		return 0, fasthttp.ErrBodyTooLarge
+	}
+	if len(p) > x.n {
+		p = p[:x.n]
+	}
+	n, err := x.r.Read(p)
+	x.n -= n
+	return n, err
+}
+
+func TestUploadMultipartBodyTooLarge(t *testing.T) {
+	body, boundary := newMultipart(t, map[string][]byte{"a.png": _png}, nil)
+	size := body.Len()
+
+	for _, limit := range []int{10, size - 60, size - 1} { // In part headers, file content and the closing boundary
+		storage := NewMemoryStorage().(*memoryStorage)
+		body, boundary = newMultipart(t, map[string][]byte{"a.png": _png}, nil)
+		_, err := NewUploader(storage, nil).UploadMultipart(context.Background(), &limitedBody{r: body, n: limit}, boundary)
+
+		var uploadErr *UploadError
+		if !errors.As(err, &uploadErr) || uploadErr.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("limit %d of %d bytes: expected status 413, got %v", limit, size, err)
+		}
+		if len(storage.files) != 0 {
+			t.Errorf("limit %d of %d bytes: stored files are not cleaned up", limit, size)
+		}
+	}
+}
@@ -19,7 +19,7 @@ var (
 )
 
 type redisURLProvider struct {
-	client redis.Cmdable
+	client redis.UniversalClient
 	key    string
 }
 
@@ -41,6 +41,11 @@ func NewRedisURLProvider(key string, redisConfig *xredis.RedisConfig) (IURLProvi
 	}, nil
 }
 
+// Close closes the redis client
+func (x *redisURLProvider) Close() error {
+	return xerr.WithStack(x.client.Close())
+}
+
 // GetURL get url from redis
 func (x *redisURLProvider) GetURL(urlKey string) string {
 	if urlKey == "" {
@@ -0,0 +1,378 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DreamvatLab/go/xconv"
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	Ctx_I18n   = "i18n"
+	Ctx_Locale = "locale"
+
+	Plural_Zero  = "zero"
+	Plural_One   = "one"
+	Plural_Two   = "two"
+	Plural_Few   = "few"
+	Plural_Many  = "many"
+	Plural_Other = "other"
+)
+
+// PluralRule returns the plural category of n
+type PluralRule func(n int64) string
+
+var (
+	_pluralCategories = map[string]bool{Plural_Zero: true, Plural_One: true, Plural_Two: true, Plural_Few: true, Plural_Many: true, Plural_Other: true}
+	_pluralRules      = map[string]PluralRule{
+		"en": pluralOneIsOne, "de": pluralOneIsOne, "nl": pluralOneIsOne, "sv": pluralOneIsOne, "it": pluralOneIsOne, "es": pluralOneIsOne, "pt": pluralOneIsOne,
+		"fr": pluralZeroOrOne,
+		"zh": pluralOther, "ja": pluralOther, "ko": pluralOther, "vi": pluralOther, "th": pluralOther, "id": pluralOther,
+		"ru": pluralSlavic, "uk": pluralSlavic,
+	}
+	_pluralRulesLock sync.RWMutex
+)
+
+type (
+	// I18nOptions configures message catalogs and locale resolution.
+	// Catalogs are '{locale}.json' files under Dir, nested objects are flattened into dotted keys,
+	// an object with plural categories as keys is a plural message:
+	//
+	//	{"upload": {"title": "Upload", "files": {"one": "%d file", "other": "%d files"}}}
+	//
+	// Locale of a request is resolved by query, cookie, user claim, then Accept-Language
+	I18nOptions struct {
+		Dir           string // Catalog directory on disk, or in the fs given to NewI18n
+		DefaultLocale string // Fallback locale, default 'en'
+		QueryName     string // Default 'lang'
+		CookieName    string // Default 'lang'
+		ClaimName     string // Claim of user's locale, default 'locale'
+	}
+
+	// I18n holds message catalogs, it's read only after creation
+	I18n struct {
+		options  *I18nOptions
+		catalogs map[string]map[string]interface{} // locale -> key -> string or map[string]string
+		locales  []string
+	}
+)
+
+// RegisterPluralRule sets plural rule of a language, e.g. 'pl'
+func RegisterPluralRule(language string, rule PluralRule) {
+	_pluralRulesLock.Lock()
+	_pluralRules[strings.ToLower(language)] = rule
+	_pluralRulesLock.Unlock()
+}
+
+// NewI18n loads catalogs, fsys (e.g. embed.FS) can be nil to load from disk
+func NewI18n(options *I18nOptions, fsys fs.FS) (*I18n, error) {
+	if options == nil {
+		return nil, xerr.New("i18n options cannot be nil")
+	}
+	if options.DefaultLocale == "" {
+		options.DefaultLocale = "en"
+	}
+	if options.QueryName == "" {
+		options.QueryName = "lang"
+	}
+	if options.CookieName == "" {
+		options.CookieName = "lang"
+	}
+	if options.ClaimName == "" {
+		options.ClaimName = "locale"
+	}
+
+	dir := options.Dir
+	if fsys == nil {
+		if dir == "" {
+			return nil, xerr.New("i18n 'Dir' cannot be empty")
+		}
+		fsys = os.DirFS(dir)
+		dir = "."
+	} else if dir == "" {
+		dir = "."
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	r := &I18n{
+		options:  options,
+		catalogs: make(map[string]map[string]interface{}, len(entries)),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, xerr.WithStack(err)
+		}
+		var raw map[string]interface{}
+		if err = json.Unmarshal(data, &raw); err != nil {
+			return nil, xerr.WithMessage(xerr.WithStack(err), entry.Name())
+		}
+
+		locale := normalizeLocale(strings.TrimSuffix(entry.Name(), ".json"))
+		catalog := make(map[string]interface{})
+		if err = flattenCatalog(catalog, "", raw); err != nil {
+			return nil, xerr.WithMessage(err, entry.Name())
+		}
+		r.catalogs[locale] = catalog
+		r.locales = append(r.locales, locale)
+	}
+	sort.Strings(r.locales)
+
+	return r, nil
+}
+
+// GetLocales returns locales which have catalogs
+func (x *I18n) GetLocales() []string {
+	return x.locales
+}
+
+// Handler is the middleware which makes T available on the context, locale is resolved on first use
+// so claims set by later auth handlers are honored
+func (x *I18n) Handler(ctx IHttpContext) {
+	ctx.SetItem(Ctx_I18n, x)
+	ctx.Next()
+}
+
+// ResolveLocale picks a supported locale by query, cookie, user claim, then Accept-Language.
+// Only query args are read, the body is left to handlers
+func (x *I18n) ResolveLocale(ctx IHttpContext) string {
+	var query string
+	if u, err := url.Parse(ctx.RequestURL()); err == nil {
+		query = u.Query().Get(x.options.QueryName)
+	}
+
+	for _, candidate := range []string{
+		query,
+		ctx.GetCookieString(x.options.CookieName),
+		GetClaimString(ctx, x.options.ClaimName),
+	} {
+		if locale := x.Match(candidate); locale != "" {
+			return locale
+		}
+	}
+
+	for _, candidate := range ParseAcceptLanguage(ctx.GetHeader("Accept-Language")) {
+		if locale := x.Match(candidate); locale != "" {
+			return locale
+		}
+	}
+
+	return x.options.DefaultLocale
+}
+
+// Match returns the supported locale for a requested one, 'zh-TW' matches 'zh-tw', then 'zh', then the first 'zh-*'
+func (x *I18n) Match(requested string) string {
+	requested = normalizeLocale(requested)
+	if requested == "" {
+		return ""
+	}
+	if _, ok := x.catalogs[requested]; ok {
+		return requested
+	}
+
+	language := getLanguage(requested)
+	if _, ok := x.catalogs[language]; ok {
+		return language
+	}
+	for _, locale := range x.locales {
+		if strings.HasPrefix(locale, language+"-") {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Translate formats message of key by fmt.Sprintf, the first integer arg selects the plural form.
+// Missing keys fall back to the default locale, then to the key itself
+func (x *I18n) Translate(locale, key string, args ...interface{}) string {
+	msg, ok := x.catalogs[normalizeLocale(locale)][key]
+	if !ok {
+		locale = x.options.DefaultLocale
+		msg, ok = x.catalogs[locale][key]
+	}
+	if !ok {
+		return formatMessage(key, args)
+	}
+
+	switch v := msg.(type) {
+	case string:
+		return formatMessage(v, args)
+	case map[string]string:
+		form, ok := v[getPluralRule(locale)(getPluralCount(args))]
+		if !ok {
+			form = v[Plural_Other]
+		}
+		return formatMessage(form, args)
+	}
+	return key
+}
+
+// Translate translates key by the locale of the request, key is returned as is if there is no I18n middleware
+func Translate(ctx IHttpContext, key string, args ...interface{}) string {
+	i18n, ok := ctx.GetItem(Ctx_I18n).(*I18n)
+	if !ok {
+		return formatMessage(key, args)
+	}
+
+	locale := ctx.GetItemString(Ctx_Locale)
+	if locale == "" {
+		locale = i18n.ResolveLocale(ctx)
+		ctx.SetItem(Ctx_Locale, locale)
+	}
+	return i18n.Translate(locale, key, args...)
+}
+
+// ParseAcceptLanguage returns languages of Accept-Language header ordered by quality
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	r := make([]string, 0, len(langs))
+	for _, l := range langs {
+		r = append(r, l.tag)
+	}
+	return r
+}
+
+func flattenCatalog(catalog map[string]interface{}, prefix string, raw map[string]interface{}) error {
+	for k, v := range raw {
+		key := prefix + k
+		switch value := v.(type) {
+		case string:
+			catalog[key] = value
+		case map[string]interface{}:
+			if plural, ok := toPlural(value); ok {
+				catalog[key] = plural
+			} else if err := flattenCatalog(catalog, key+".", value); err != nil {
+				return err
+			}
+		default:
+			return xerr.Errorf("invalid message '%s'", key)
+		}
+	}
+	return nil
+}
+
+// toPlural converts an object whose keys are all plural categories, 'other' is required
+func toPlural(raw map[string]interface{}) (map[string]string, bool) {
+	if _, ok := raw[Plural_Other]; !ok {
+		return nil, false
+	}
+
+	r := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok || !_pluralCategories[k] {
+			return nil, false
+		}
+		r[k] = s
+	}
+	return r, true
+}
+
+func formatMessage(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+func getPluralCount(args []interface{}) int64 {
+	for _, arg := range args {
+		switch arg.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return xconv.ToInt64(arg)
+		}
+	}
+	return 0
+}
+
+func getPluralRule(locale string) PluralRule {
+	_pluralRulesLock.RLock()
+	rule, ok := _pluralRules[getLanguage(locale)]
+	_pluralRulesLock.RUnlock()
+	if ok {
+		return rule
+	}
+	return pluralOneIsOne
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func getLanguage(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
+
+func pluralOneIsOne(n int64) string {
+	if n == 1 {
+		return Plural_One
+	}
+	return Plural_Other
+}
+
+func pluralZeroOrOne(n int64) string {
+	if n == 0 || n == 1 {
+		return Plural_One
+	}
+	return Plural_Other
+}
+
+func pluralOther(n int64) string {
+	return Plural_Other
+}
+
+func pluralSlavic(n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	mod10, mod100 := n%10, n%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return Plural_One
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return Plural_Few
+	default:
+		return Plural_Many
+	}
+}
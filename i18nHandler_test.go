@@ -0,0 +1,43 @@
+package host_test
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestI18nResolveLocale(t *testing.T) {
+	i18n, err := host.NewI18n(&host.I18nOptions{}, fstest.MapFS{
+		"en.json":    {Data: []byte(`{"hello": "Hello"}`)},
+		"zh-CN.json": {Data: []byte(`{"hello": "你好"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(*hosttest.Context)
+		expected string
+	}{
+		{"query", func(ctx *hosttest.Context) { ctx.Query.Set("lang", "zh-CN") }, "你好"},
+		{"cookie", func(ctx *hosttest.Context) { ctx.Cookies["lang"] = "zh" }, "你好"},
+		{"accept language", func(ctx *hosttest.Context) { ctx.Headers.Set("Accept-Language", "fr, zh-TW;q=0.8") }, "你好"},
+		{"post form is not read", func(ctx *hosttest.Context) { ctx.Form.Set("lang", "zh-CN") }, "Hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual string
+			ctx := hosttest.NewContext(http.MethodPost, "/api/orders", i18n.Handler, func(ctx host.IHttpContext) {
+				actual = ctx.T("hello")
+			})
+			tt.setup(ctx)
+			if ctx.Run(); actual != tt.expected {
+				t.Errorf("T(hello) = %q, expected %q", actual, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package host
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestI18nTranslate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.json":    {Data: []byte(`{"hello": "Hello %s", "upload": {"files": {"one": "%d file", "other": "%d files"}}}`)},
+		"zh-CN.json": {Data: []byte(`{"hello": "你好 %s", "upload": {"files": {"other": "%d 个文件"}}}`)},
+		"ru.json":    {Data: []byte(`{"upload": {"files": {"one": "%d файл", "few": "%d файла", "many": "%d файлов", "other": "%d файла"}}}`)},
+	}
+	i18n, err := NewI18n(&I18nOptions{}, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		locale, key string
+		args        []interface{}
+		expected    string
+	}{
+		{"en", "hello", []interface{}{"Tom"}, "Hello Tom"},
+		{"zh-cn", "hello", []interface{}{"Tom"}, "你好 Tom"},
+		{"en", "upload.files", []interface{}{1}, "1 file"},
+		{"en", "upload.files", []interface{}{2}, "2 files"},
+		{"zh-cn", "upload.files", []interface{}{1}, "1 个文件"},
+		{"ru", "upload.files", []interface{}{21}, "21 файл"},
+		{"ru", "upload.files", []interface{}{3}, "3 файла"},
+		{"ru", "upload.files", []interface{}{11}, "11 файлов"},
+		{"ru", "hello", []interface{}{"Tom"}, "Hello Tom"}, // Falls back to default locale
+		{"en", "ip not allowed", nil, "ip not allowed"},
+	}
+	for _, tt := range tests {
+		if actual := i18n.Translate(tt.locale, tt.key, tt.args...); actual != tt.expected {
+			t.Errorf("Translate(%s, %s) = %s, expected %s", tt.locale, tt.key, actual, tt.expected)
+		}
+	}
+
+	for requested, expected := range map[string]string{"zh-CN": "zh-cn", "zh_TW": "zh-cn", "ru-RU": "ru", "fr": ""} {
+		if actual := i18n.Match(requested); actual != expected {
+			t.Errorf("Match(%s) = %s, expected %s", requested, actual, expected)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	actual := ParseAcceptLanguage("fr;q=0.5, zh-CN, en;q=0.8, *;q=0.1, de;q=0")
+	if expected := []string{"zh-CN", "en", "fr"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,227 @@
+package host
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	Header_IdempotencyKey      = "Idempotency-Key"
+	Header_IdempotencyReplayed = "Idempotent-Replayed"
+)
+
+type (
+	// IdempotencyOptions configures the Idempotency-Key middleware of POST/PUT/PATCH/DELETE requests.
+	// Keys are scoped by Ctx_UserID, or by the session cookie if the user is unknown, requests having neither skip the middleware
+	IdempotencyOptions struct {
+		KeyPrefix         string   // Default 'idempotency'
+		TTLSeconds        int      // How long responses are replayed, default 86400
+		LockSeconds       int      // Max processing time of the first request, default 60
+		Routes            []string // RouteKeys using the middleware, 'area_controller_' and 'area__' apply to the whole controller/area, empty means all
+		Required          bool     // Reject requests of Routes without the header or without user and session by 400
+		MaxKeyLength      int      // Default 255
+		SessionCookieName string   // Session ID cookie scoping keys of requests without user, web hosts set their session cookie
+	}
+
+	// Idempotency is the Idempotency-Key middleware backed by redis
+	Idempotency struct {
+		options *IdempotencyOptions
+		client  redis.UniversalClient
+		routes  map[string]bool
+	}
+
+	idempotencyRecord struct {
+		Fingerprint string
+		Response    *CachedResponse `json:",omitempty"` // nil while the first request is processing
+	}
+)
+
+// NewIdempotency creates the middleware
+func NewIdempotency(options *IdempotencyOptions, redisConfig *xredis.RedisConfig) (*Idempotency, error) {
+	if options == nil {
+		return nil, xerr.New("idempotency options cannot be nil")
+	}
+	if redisConfig == nil {
+		return nil, xerr.New("idempotency requires redis config")
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "idempotency"
+	}
+	if options.TTLSeconds <= 0 {
+		options.TTLSeconds = 86400
+	}
+	if options.LockSeconds <= 0 {
+		options.LockSeconds = 60
+	}
+	if options.MaxKeyLength <= 0 {
+		options.MaxKeyLength = 255
+	}
+
+	r := &Idempotency{
+		options: options,
+		client:  xredis.NewClient(redisConfig),
+		routes:  make(map[string]bool, len(options.Routes)),
+	}
+	for _, routeKey := range options.Routes {
+		r.routes[routeKey] = true
+	}
+	return r, nil
+}
+
+// Close closes the redis client
+func (x *Idempotency) Close() error {
+	return xerr.WithStack(x.client.Close())
+}
+
+// Handler is the middleware. The first request of a key runs, its response is replayed to retries,
+// a retry while the first is still running gets 409, reusing a key with a different payload gets 422
+func (x *Idempotency) Handler(ctx IHttpContext) {
+	switch ctx.RequestMethod() {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		ctx.Next()
+		return
+	}
+	if !x.matchRoute(ctx.GetRouteKey()) {
+		ctx.Next()
+		return
+	}
+
+	key := ctx.GetHeader(Header_IdempotencyKey)
+	if key == "" {
+		if x.options.Required {
+			ctx.SetStatusCode(http.StatusBadRequest)
+			ctx.WriteString(ctx.T("Idempotency-Key header is missing"))
+			return
+		}
+		ctx.Next()
+		return
+	}
+	if len(key) > x.options.MaxKeyLength {
+		ctx.SetStatusCode(http.StatusBadRequest)
+		ctx.WriteString(ctx.T("Idempotency-Key is too long"))
+		return
+	}
+
+	scope := x.getScope(ctx)
+	if scope == "" {
+		if x.options.Required {
+			ctx.SetStatusCode(http.StatusBadRequest)
+			ctx.WriteString(ctx.T("Idempotency-Key requires a signed in user or session"))
+			return
+		}
+		ctx.Next() // Anonymous clients would share keys
+		return
+	}
+
+	redisKey := x.getRedisKey(scope, key)
+	record := &idempotencyRecord{Fingerprint: getFingerprint(ctx)}
+
+	data, err := json.Marshal(record)
+	if HandleErr(xerr.WithStack(err), ctx) {
+		return
+	}
+	goctx := context.Background()
+	ok, err := x.client.SetNX(goctx, redisKey, data, time.Duration(x.options.LockSeconds)*time.Second).Result()
+	if HandleErr(xerr.WithStack(err), ctx) {
+		return
+	}
+
+	if !ok {
+		x.handleDuplicate(ctx, redisKey, record.Fingerprint)
+		return
+	}
+
+	ctx.Next()
+
+	if ctx.GetStatusCode() >= http.StatusInternalServerError {
+		// Let the client retry server errors
+		xerr.LogError(x.client.Del(goctx, redisKey).Err())
+		return
+	}
+
+	record.Response = NewCachedResponse(ctx)
+	data, err = json.Marshal(record)
+	if xerr.LogError(err) {
+		return
+	}
+	xerr.LogError(x.client.Set(goctx, redisKey, data, time.Duration(x.options.TTLSeconds)*time.Second).Err())
+}
+
+func (x *Idempotency) handleDuplicate(ctx IHttpContext, redisKey, fingerprint string) {
+	data, err := x.client.Get(context.Background(), redisKey).Bytes()
+	if err == redis.Nil {
+		// First request failed and released the key just now
+		ctx.SetStatusCode(http.StatusConflict)
+		ctx.WriteString(ctx.T("request with the same Idempotency-Key is in progress"))
+		return
+	}
+	if HandleErr(xerr.WithStack(err), ctx) {
+		return
+	}
+
+	record := new(idempotencyRecord)
+	if HandleErr(xerr.WithStack(json.Unmarshal(data, record)), ctx) {
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		ctx.SetStatusCode(http.StatusUnprocessableEntity)
+		ctx.WriteString(ctx.T("Idempotency-Key is already used by a different request"))
+		xlog.Warnf("idempotency key reused with a different payload from '%s' to '%s'", ctx.GetRealIP(), ctx.GetRouteKey())
+	case record.Response == nil:
+		ctx.SetStatusCode(http.StatusConflict)
+		ctx.WriteString(ctx.T("request with the same Idempotency-Key is in progress"))
+	default:
+		ctx.SetHeader(Header_IdempotencyReplayed, "true")
+		record.Response.Replay(ctx)
+	}
+}
+
+func (x *Idempotency) matchRoute(routeKey string) bool {
+	if len(x.routes) == 0 {
+		return true
+	}
+	if x.routes[routeKey] {
+		return true
+	}
+	area, controller, _ := GetRoutesByKey(routeKey)
+	return x.routes[area+Seperator_Route+controller+Seperator_Route] || x.routes[area+Seperator_Route+Seperator_Route]
+}
+
+// getScope returns 'user:{id}' or 'session:{id}', empty if the request has neither
+func (x *Idempotency) getScope(ctx IHttpContext) string {
+	if userID := ctx.GetItemString(Ctx_UserID); userID != "" {
+		return "user:" + userID
+	}
+	if x.options.SessionCookieName != "" {
+		if sessionID := ctx.GetCookieString(x.options.SessionCookieName); sessionID != "" {
+			return "session:" + sessionID
+		}
+	}
+	return ""
+}
+
+// getRedisKey scopes key by user or session, '{KeyPrefix}:{scope hash}:{key}'
+func (x *Idempotency) getRedisKey(scope, key string) string {
+	hash := sha256.Sum256([]byte(scope))
+	return x.options.KeyPrefix + ":" + hex.EncodeToString(hash[:8]) + ":" + key
+}
+
+// getFingerprint hashes method, path and body of the request
+func getFingerprint(ctx IHttpContext) string {
+	h := sha256.New()
+	h.Write([]byte(ctx.RequestMethod() + " " + ctx.RequestPath() + "\n"))
+	h.Write(ctx.GetBodyBytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package host_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestIdempotencyHandler(t *testing.T) {
+	type request struct {
+		key, body string
+		setup     func(*hosttest.Context)
+		status    int
+		replayed  bool // The handler only runs for requests which are answered 201 and not replayed
+	}
+	u1 := hosttest.AsUser("u1")
+	refreshedToken := func(token string) func(*hosttest.Context) {
+		return func(ctx *hosttest.Context) {
+			u1(ctx)
+			ctx.Headers.Set("Authorization", "Bearer "+token)
+		}
+	}
+	inSession := func(sessionID string) func(*hosttest.Context) {
+		return hosttest.WithCookie("sid", sessionID)
+	}
+
+	tests := []struct {
+		name     string
+		options  *host.IdempotencyOptions
+		requests []request
+	}{
+		{"replay", &host.IdempotencyOptions{}, []request{
+			{"k1", `{"qty":1}`, u1, http.StatusCreated, false},
+			{"k1", `{"qty":1}`, u1, http.StatusCreated, true},
+			{"k1", `{"qty":2}`, u1, http.StatusUnprocessableEntity, false},
+		}},
+		{"same user with refreshed token", &host.IdempotencyOptions{}, []request{
+			{"k1", "{}", refreshedToken("old"), http.StatusCreated, false},
+			{"k1", "{}", refreshedToken("new"), http.StatusCreated, true},
+		}},
+		{"different users", &host.IdempotencyOptions{}, []request{
+			{"k1", "{}", hosttest.AsUser("u2"), http.StatusCreated, false},
+			{"k1", "{}", hosttest.AsUser("u3"), http.StatusCreated, false},
+		}},
+		{"same session", &host.IdempotencyOptions{}, []request{
+			{"k1", "{}", inSession("s1"), http.StatusCreated, false},
+			{"k1", "{}", inSession("s1"), http.StatusCreated, true},
+		}},
+		{"different sessions", &host.IdempotencyOptions{}, []request{
+			{"k1", "{}", inSession("s2"), http.StatusCreated, false},
+			{"k1", "{}", inSession("s3"), http.StatusCreated, false},
+		}},
+		{"anonymous", &host.IdempotencyOptions{}, []request{
+			{"k1", "{}", nil, http.StatusCreated, false},
+			{"k1", "{}", nil, http.StatusCreated, false},
+		}},
+		{"required", &host.IdempotencyOptions{Required: true, Routes: []string{"api_orders_"}}, []request{
+			{"", "{}", u1, http.StatusBadRequest, false},
+			{"k1", "{}", nil, http.StatusBadRequest, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.SessionCookieName = "sid"
+			idempotency, err := host.NewIdempotency(tt.options, hosttest.NewRedisConfig(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idempotency.Close()
+			calls := 0
+			route := hosttest.NewRoute(http.MethodPost, "/api/orders", "api_orders_create", idempotency.Handler, func(ctx host.IHttpContext) {
+				calls++
+				ctx.SetStatusCode(http.StatusCreated)
+				ctx.WriteString("order " + strconv.Itoa(calls))
+			})
+
+			var previous string
+			for i, r := range tt.requests {
+				expectedCalls := calls
+				if r.status == http.StatusCreated && !r.replayed {
+					expectedCalls++
+				}
+				ctx := route.Run(hosttest.WithRequestHeader(host.Header_IdempotencyKey, r.key), hosttest.WithBody(r.body), r.setup)
+				replayed := ctx.ResponseHeaders.Get(host.Header_IdempotencyReplayed) == "true"
+				if ctx.StatusCode != r.status || replayed != r.replayed || calls != expectedCalls {
+					t.Fatalf("request %d = %d, replayed = %v, handler calls = %d, expected %d, replayed = %v, handler calls = %d",
+						i, ctx.StatusCode, replayed, calls, r.status, r.replayed, expectedCalls)
+				}
+				if r.replayed && ctx.ResponseBody.String() != previous {
+					t.Fatalf("request %d replayed %q, expected %q", i, ctx.ResponseBody.String(), previous)
+				}
+				previous = ctx.ResponseBody.String()
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package host
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// IPFilterRule allows or denies client ips by IPs/CIDRs, deny wins over allow
+	IPFilterRule struct {
+		Allow []string // Empty means any ip is allowed
+		Deny  []string
+	}
+
+	// IPFilterOptions configures the ip filter middleware.
+	// Redis sets under RedisKey are merged into the configured rules and reloaded periodically:
+	//   {RedisKey}:allow, {RedisKey}:deny                       global rules
+	//   {RedisKey}:routes                                       route keys which have route rules
+	//   {RedisKey}:allow:{RouteKey}, {RedisKey}:deny:{RouteKey} route rules
+	IPFilterOptions struct {
+		Global        *IPFilterRule
+		Routes        map[string]*IPFilterRule // Keyed by RouteKey, 'area_controller_' and 'area__' apply to the whole controller/area
+		RedisKey      string
+		ReloadSeconds int // Interval to reload rules from redis, default 60
+	}
+
+	IPFilter struct {
+		options *IPFilterOptions
+		redis   redis.UniversalClient
+		lock    sync.RWMutex
+		global  *ipRule
+		routes  map[string]*ipRule
+		stop    chan struct{}
+	}
+
+	ipRule struct {
+		allow []*net.IPNet
+		deny  []*net.IPNet
+	}
+)
+
+// NewIPFilter creates ip filter, redisConfig is only required when options.RedisKey is set
+func NewIPFilter(options *IPFilterOptions, redisConfig *xredis.RedisConfig) (*IPFilter, error) {
+	if options == nil {
+		return nil, xerr.New("ip filter options cannot be nil")
+	}
+
+	r := &IPFilter{
+		options: options,
+		stop:    make(chan struct{}),
+	}
+
+	if options.RedisKey != "" {
+		if redisConfig == nil {
+			return nil, xerr.New("ip filter requires redis config when 'RedisKey' is set")
+		}
+		r.redis = xredis.NewClient(redisConfig)
+		if options.ReloadSeconds <= 0 {
+			options.ReloadSeconds = 60
+		}
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if r.redis != nil {
+		go r.watch()
+	}
+
+	return r, nil
+}
+
+// Reload rebuilds rules from options and redis, current rules are kept if it fails
+func (x *IPFilter) Reload() error {
+	global := new(ipRule)
+	routes := make(map[string]*ipRule, len(x.options.Routes))
+
+	if x.options.Global != nil {
+		if err := global.add(x.options.Global.Allow, x.options.Global.Deny); err != nil {
+			return err
+		}
+	}
+	for routeKey, rule := range x.options.Routes {
+		if rule == nil {
+			continue
+		}
+		r := new(ipRule)
+		if err := r.add(rule.Allow, rule.Deny); err != nil {
+			return xerr.WithMessage(err, routeKey)
+		}
+		routes[routeKey] = r
+	}
+
+	if x.redis != nil {
+		if err := x.loadRedisRules(global, routes); err != nil {
+			return err
+		}
+	}
+
+	x.lock.Lock()
+	x.global = global
+	x.routes = routes
+	x.lock.Unlock()
+
+	return nil
+}
+
+func (x *IPFilter) loadRedisRules(global *ipRule, routes map[string]*ipRule) error {
+	goctx := context.Background()
+	key := x.options.RedisKey
+
+	allow, err := x.redis.SMembers(goctx, key+":allow").Result()
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	deny, err := x.redis.SMembers(goctx, key+":deny").Result()
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	if err = global.add(allow, deny); err != nil {
+		return xerr.WithMessage(err, key)
+	}
+
+	routeKeys, err := x.redis.SMembers(goctx, key+":routes").Result()
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	for _, routeKey := range routeKeys {
+		allow, err = x.redis.SMembers(goctx, key+":allow:"+routeKey).Result()
+		if err != nil {
+			return xerr.WithStack(err)
+		}
+		deny, err = x.redis.SMembers(goctx, key+":deny:"+routeKey).Result()
+		if err != nil {
+			return xerr.WithStack(err)
+		}
+
+		r, ok := routes[routeKey]
+		if !ok {
+			r = new(ipRule)
+			routes[routeKey] = r
+		}
+		if err = r.add(allow, deny); err != nil {
+			return xerr.WithMessage(err, routeKey)
+		}
+	}
+
+	return nil
+}
+
+func (x *IPFilter) watch() {
+	ticker := time.NewTicker(time.Duration(x.options.ReloadSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			xerr.LogError(x.Reload())
+		case <-x.stop:
+			return
+		}
+	}
+}
+
+// Close stops reloading rules from redis and closes the redis client
+func (x *IPFilter) Close() {
+	select {
+	case <-x.stop:
+	default:
+		close(x.stop)
+		if x.redis != nil {
+			xerr.LogError(x.redis.Close())
+		}
+	}
+}
+
+// IsAllowed checks ip against global rule and the best matching route rule.
+// A deny match in either rule rejects the ip, a route allow list overrides the global allow list.
+func (x *IPFilter) IsAllowed(routeKey, ip string) bool {
+	x.lock.RLock()
+	global := x.global
+	route := x.matchRoute(routeKey)
+	x.lock.RUnlock()
+
+	if ContainsIP(global.deny, ip) || (route != nil && ContainsIP(route.deny, ip)) {
+		return false
+	}
+
+	if route != nil && len(route.allow) > 0 {
+		return ContainsIP(route.allow, ip)
+	}
+	if len(global.allow) > 0 {
+		return ContainsIP(global.allow, ip)
+	}
+
+	return true
+}
+
+// matchRoute finds rule by 'area_controller_action', 'area_controller_' then 'area__'
+func (x *IPFilter) matchRoute(routeKey string) *ipRule {
+	if len(x.routes) == 0 || routeKey == "" {
+		return nil
+	}
+
+	if r, ok := x.routes[routeKey]; ok {
+		return r
+	}
+
+	area, controller, _ := GetRoutesByKey(routeKey)
+	if r, ok := x.routes[area+Seperator_Route+controller+Seperator_Route]; ok {
+		return r
+	}
+	if r, ok := x.routes[area+Seperator_Route+Seperator_Route]; ok {
+		return r
+	}
+
+	return nil
+}
+
+// Handler is the middleware, it responds 403 for rejected ips
+func (x *IPFilter) Handler(ctx IHttpContext) {
+	ip := ctx.GetRealIP()
+	if x.IsAllowed(ctx.GetRouteKey(), ip) {
+		ctx.Next()
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusForbidden)
+	ctx.WriteString(ctx.T("ip not allowed"))
+	xlog.Warnf("ip '%s' is not allowed to access '%s'", ip, ctx.GetRouteKey())
+}
+
+func (x *ipRule) add(allow, deny []string) error {
+	allowNets, err := ParseIPNets(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := ParseIPNets(deny)
+	if err != nil {
+		return err
+	}
+
+	x.allow = append(x.allow, allowNets...)
+	x.deny = append(x.deny, denyNets...)
+	return nil
+}
@@ -0,0 +1,120 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestIPFilterIsAllowed(t *testing.T) {
+	filter, err := NewIPFilter(&IPFilterOptions{
+		Global: &IPFilterRule{
+			Allow: []string{"10.0.0.0/8", "192.168.1.1"},
+			Deny:  []string{"10.0.9.0/24"},
+		},
+		Routes: map[string]*IPFilterRule{
+			"api_admin_users": {Allow: []string{"10.0.1.0/24"}},
+			"api_admin_":      {Allow: []string{"10.0.2.0/24"}, Deny: []string{"10.0.2.9"}},
+			"api__":           {Deny: []string{"10.0.3.0/24"}},
+			"web_home_index":  {Allow: []string{"10.0.9.1", "172.16.0.1"}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer filter.Close()
+
+	tests := []struct {
+		routeKey, ip string
+		expected     bool
+	}{
+		// Global rule
+		{"", "10.1.2.3", true},
+		{"", "192.168.1.1", true},
+		{"", "192.168.1.2", false},
+		{"", "10.0.9.1", false},
+		{"", "bad ip", false},
+		{"web_home_other", "10.1.2.3", true},
+		// Exact route allow list overrides the global allow list
+		{"api_admin_users", "10.0.1.5", true},
+		{"api_admin_users", "10.1.2.3", false},
+		// Controller rule when the action has none
+		{"api_admin_roles", "10.0.2.5", true},
+		{"api_admin_roles", "10.0.1.5", false},
+		{"api_admin_roles", "10.0.2.9", false},
+		// Area rule only denies, the global allow list applies
+		{"api_orders_list", "10.0.3.5", false},
+		{"api_orders_list", "10.0.4.5", true},
+		{"api_orders_list", "172.16.0.1", false},
+		// Global deny wins over route allow
+		{"web_home_index", "10.0.9.1", false},
+		{"web_home_index", "172.16.0.1", true},
+	}
+	for _, tt := range tests {
+		if actual := filter.IsAllowed(tt.routeKey, tt.ip); actual != tt.expected {
+			t.Errorf("IsAllowed(%s, %s) = %v, expected %v", tt.routeKey, tt.ip, actual, tt.expected)
+		}
+	}
+}
+
+func TestIPFilterMatchRoute(t *testing.T) {
+	action, controller, area := new(ipRule), new(ipRule), new(ipRule)
+	filter := &IPFilter{routes: map[string]*ipRule{
+		"api_admin_users": action,
+		"api_admin_":      controller,
+		"api__":           area,
+	}}
+
+	tests := []struct {
+		routeKey string
+		expected *ipRule
+	}{
+		{"api_admin_users", action},
+		{"api_admin_roles", controller},
+		{"api_orders_list", area},
+		{"web_home_index", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if actual := filter.matchRoute(tt.routeKey); actual != tt.expected {
+			t.Errorf("matchRoute(%s) = %p, expected %p", tt.routeKey, actual, tt.expected)
+		}
+	}
+
+	if (&IPFilter{}).matchRoute("api_admin_users") != nil {
+		t.Error("route matched without route rules")
+	}
+}
+
+func TestIPFilterRedisRules(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SAdd("ipf:deny", "10.0.0.1")
+	server.SAdd("ipf:routes", "api_admin_")
+	server.SAdd("ipf:allow:api_admin_", "10.0.2.0/24")
+
+	filter, err := NewIPFilter(&IPFilterOptions{
+		Global:   &IPFilterRule{Allow: []string{"10.0.0.0/8"}},
+		RedisKey: "ipf",
+	}, &xredis.RedisConfig{Addrs: []string{server.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer filter.Close()
+
+	if filter.IsAllowed("", "10.0.0.1") || !filter.IsAllowed("", "10.0.0.2") {
+		t.Error("global deny of redis is not merged")
+	}
+	if filter.IsAllowed("api_admin_users", "10.0.1.1") || !filter.IsAllowed("api_admin_users", "10.0.2.1") {
+		t.Error("route allow of redis is not merged")
+	}
+
+	// Bad rules keep the current ones
+	server.SAdd("ipf:allow", "not a cidr")
+	if err := filter.Reload(); err == nil {
+		t.Error("bad rule is loaded")
+	}
+	if !filter.IsAllowed("", "10.0.0.2") {
+		t.Error("current rules are dropped after a failed reload")
+	}
+}
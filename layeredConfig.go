@@ -0,0 +1,515 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xslice"
+)
+
+const (
+	Source_File = "file"
+	Source_Env  = "env"
+	Source_Flag = "flag"
+
+	_schemaFlag = "config-schema"
+)
+
+var _dumpedSchemas sync.Map // IConfigSchema => true, hosts sharing a config provider dump its schema once
+
+type (
+	// LayeredConfigOptions configures layers of LayeredConfigProvider, later layers win:
+	//  1. File, e.g. configs.json
+	//  2. Profile file of Env, e.g. configs.prod.json, skipped if missing
+	//  3. Environment variables with EnvPrefix, '__' separates keys, e.g. HOST__OAuth__ClientSecret
+	//  4. Flags like '--OAuth.ClientSecret=x', '--OAuth__ClientSecret x'
+	// Values of env vars and flags are parsed as json (numbers, bools, arrays, objects) unless the value they replace is a string.
+	// Keys match existing keys case-insensitively, array items are addressed by index like HOST__Listeners__0__Addr
+	LayeredConfigOptions struct {
+		File      string   // Default 'configs.json'
+		Env       string   // Profile name, defaults to flag '--Env' or env var '{EnvPrefix}Env'
+		EnvPrefix string   // Default 'HOST__'
+		Args      []string // Flags overriding keys, none by default, e.g. pass os.Args[1:] to opt in. '--config-schema' is read from os.Args anyway
+	}
+
+	// IConfigSchema is a config provider which dumps keys read by hosts
+	IConfigSchema interface {
+		SchemaRequested() bool
+		DumpSchema(w io.Writer) error
+		// RecordRead records a key read through a wrapping provider, value is the target of GetStruct or a value of the type read
+		RecordRead(key string, value interface{})
+	}
+
+	// ConfigSchemaForwarder implements IConfigSchema for config providers wrapping Base, e.g. secret or consul KV providers,
+	// so the schema is dumped whatever the wrapping order is. Wrappers call RecordRead in their getters
+	ConfigSchemaForwarder struct {
+		Base xconfig.IConfigProvider
+	}
+
+	// LayeredConfigProvider merges config layers and records keys read by hosts for the schema dump
+	LayeredConfigProvider struct {
+		*xconfig.JsonConfigProvider
+		options         *LayeredConfigOptions
+		sources         map[string]string // Key => source of its value
+		lock            sync.Mutex
+		reads           map[string]string // Key => type
+		schemaRequested bool
+	}
+
+	configFlag struct {
+		name  string
+		value string
+	}
+)
+
+// NewLayeredConfigProvider merges the layers, options can be nil
+func NewLayeredConfigProvider(options *LayeredConfigOptions) (*LayeredConfigProvider, error) {
+	if options == nil {
+		options = new(LayeredConfigOptions)
+	}
+	if options.File == "" {
+		options.File = "configs.json"
+	}
+	if options.EnvPrefix == "" {
+		options.EnvPrefix = "HOST__"
+	}
+
+	r := &LayeredConfigProvider{
+		options:         options,
+		sources:         make(map[string]string),
+		reads:           make(map[string]string),
+		schemaRequested: len(os.Args) > 1 && xslice.HasStr(os.Args[1:], "--"+_schemaFlag),
+	}
+
+	flags := r.parseFlags(options.Args)
+	if options.Env == "" {
+		for _, f := range flags {
+			if strings.EqualFold(f.name, "Env") {
+				options.Env = f.value
+			}
+		}
+	}
+	if options.Env == "" {
+		options.Env = os.Getenv(options.EnvPrefix + "Env")
+	}
+	if options.Env == "" {
+		options.Env = os.Getenv(options.EnvPrefix + "ENV")
+	}
+
+	////////// files
+	root := make(map[string]interface{})
+	if err := r.loadFile(root, options.File, false); err != nil {
+		return nil, err
+	}
+	if options.Env != "" {
+		ext := fp.Ext(options.File)
+		profile := strings.TrimSuffix(options.File, ext) + "." + options.Env + ext
+		if err := r.loadFile(root, profile, true); err != nil {
+			return nil, err
+		}
+	}
+
+	////////// env vars
+	environ := os.Environ()
+	sort.Strings(environ)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		path, ok := strings.CutPrefix(name, options.EnvPrefix)
+		if !ok || path == "" {
+			continue
+		}
+		if key := setConfigValue(root, strings.Split(path, "__"), value); key != "" {
+			r.sources[key] = Source_Env + ":" + name
+		}
+	}
+
+	////////// flags
+	for _, f := range flags {
+		segments := strings.Split(strings.ReplaceAll(f.name, "__", "."), ".")
+		if key := setConfigValue(root, segments, f.value); key != "" {
+			r.sources[key] = Source_Flag + ":--" + f.name
+		}
+	}
+
+	raw, err := json.Marshal(root)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	r.JsonConfigProvider = &xconfig.JsonConfigProvider{
+		RawJson:          raw,
+		MapConfiguration: xconfig.MapConfiguration(root),
+	}
+	return r, nil
+}
+
+func (x *LayeredConfigProvider) parseFlags(args []string) []*configFlag {
+	var r []*configFlag
+	for i := 0; i < len(args); i++ {
+		arg, ok := strings.CutPrefix(args[i], "--")
+		if !ok || arg == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name == _schemaFlag {
+			x.schemaRequested = true
+			continue
+		}
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+		r = append(r, &configFlag{name: name, value: value})
+	}
+	return r
+}
+
+func (x *LayeredConfigProvider) loadFile(root map[string]interface{}, path string, optional bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil
+		}
+		return xerr.WithMessage(err, "failed to read "+path)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return xerr.WithMessage(err, "invalid json of "+path)
+	}
+	MergeConfig(root, m)
+	flattenConfigKeys("", m, func(key string) {
+		x.sources[key] = Source_File + ":" + path
+	})
+	return nil
+}
+
+// GetEnv returns the profile name
+func (x *LayeredConfigProvider) GetEnv() string {
+	return x.options.Env
+}
+
+// GetSource returns where the value of key comes from, e.g. 'file:configs.json', 'env:HOST__Log__Level', empty if not set
+func (x *LayeredConfigProvider) GetSource(key string) string {
+	return x.sources[key]
+}
+
+// SchemaRequested returns true if flag '--config-schema' is given, Run of hosts dumps the schema and exits then
+func (x *LayeredConfigProvider) SchemaRequested() bool {
+	return x.schemaRequested
+}
+
+// DumpConfigSchema writes the schema of cp to stdout if it's requested, returns true if the caller should exit instead of serving.
+// Run of hosts calls it after building, so keys of all hosts sharing cp are dumped once
+func DumpConfigSchema(cp xconfig.IConfigProvider) (bool, error) {
+	schema, ok := cp.(IConfigSchema)
+	if !ok || !schema.SchemaRequested() {
+		return false, nil
+	}
+	if _, dumped := _dumpedSchemas.LoadOrStore(schema, true); dumped {
+		return true, nil
+	}
+	return true, schema.DumpSchema(os.Stdout)
+}
+
+// DumpSchema writes keys read so far with types, sources and env var names, values are not written
+func (x *LayeredConfigProvider) DumpSchema(w io.Writer) error {
+	x.lock.Lock()
+	keys := make([]string, 0, len(x.reads))
+	for k := range x.reads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tSOURCE\tENV")
+	for _, k := range keys {
+		source := x.sources[k]
+		if source == "" {
+			source = "-"
+		}
+		env := x.options.EnvPrefix + strings.ReplaceAll(strings.ReplaceAll(k, "[]", ".0"), ".", "__")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k, x.reads[k], source, strings.ReplaceAll(env, "*", "{name}"))
+	}
+	x.lock.Unlock()
+	return xerr.WithStack(tw.Flush())
+}
+
+func (x *LayeredConfigProvider) record(key, typ string) {
+	x.lock.Lock()
+	x.reads[key] = typ
+	x.lock.Unlock()
+}
+
+// recordType records keys of all fields of struct targets
+func (x *LayeredConfigProvider) recordType(key string, t reflect.Type, depth int) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if depth > 10 {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			x.record(key, "time")
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" && f.Anonymous {
+				x.recordType(key, f.Type, depth+1) // Embedded fields are flattened
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if !isConfigType(f.Type) {
+				continue
+			}
+			x.recordType(joinConfigKey(key, name), f.Type, depth+1)
+		}
+	case reflect.Map:
+		x.recordType(joinConfigKey(key, "*"), t.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		elem := t.Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		switch elem.Kind() {
+		case reflect.Struct, reflect.Map:
+			x.recordType(key+"[]", elem, depth+1)
+		case reflect.Interface, reflect.Func, reflect.Chan:
+		default:
+			x.record(key, "[]"+elem.Kind().String())
+		}
+	case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+	default:
+		if key != "" {
+			x.record(key, t.Kind().String())
+		}
+	}
+}
+
+// RecordRead records a key read through a wrapping provider, value is the target of GetStruct or a value of the type read
+func (x *LayeredConfigProvider) RecordRead(key string, value interface{}) {
+	if key == "@this" {
+		key = ""
+	}
+	x.recordType(key, reflect.TypeOf(value), 0)
+}
+
+func (x *LayeredConfigProvider) GetStruct(key string, target interface{}) error {
+	x.RecordRead(key, target)
+	return x.JsonConfigProvider.GetStruct(key, target)
+}
+
+// SchemaRequested returns true if Base is an IConfigSchema whose schema is requested
+func (x ConfigSchemaForwarder) SchemaRequested() bool {
+	schema, ok := x.Base.(IConfigSchema)
+	return ok && schema.SchemaRequested()
+}
+
+// DumpSchema writes the schema of Base
+func (x ConfigSchemaForwarder) DumpSchema(w io.Writer) error {
+	schema, ok := x.Base.(IConfigSchema)
+	if !ok {
+		return xerr.New("base config provider doesn't record keys")
+	}
+	return schema.DumpSchema(w)
+}
+
+// RecordRead records the key on Base if it's an IConfigSchema
+func (x ConfigSchemaForwarder) RecordRead(key string, value interface{}) {
+	if schema, ok := x.Base.(IConfigSchema); ok {
+		schema.RecordRead(key, value)
+	}
+}
+
+func (x *LayeredConfigProvider) GetString(key string) string {
+	x.record(key, "string")
+	return x.JsonConfigProvider.GetString(key)
+}
+
+func (x *LayeredConfigProvider) GetStringDefault(key string, defaultValue string) string {
+	x.record(key, "string")
+	return x.JsonConfigProvider.GetStringDefault(key, defaultValue)
+}
+
+func (x *LayeredConfigProvider) GetBool(key string) bool {
+	x.record(key, "bool")
+	return x.JsonConfigProvider.GetBool(key)
+}
+
+func (x *LayeredConfigProvider) GetFloat64(key string) float64 {
+	x.record(key, "float64")
+	return x.JsonConfigProvider.GetFloat64(key)
+}
+
+func (x *LayeredConfigProvider) GetInt(key string) int {
+	x.record(key, "int")
+	return x.JsonConfigProvider.GetInt(key)
+}
+
+func (x *LayeredConfigProvider) GetIntDefault(key string, defaultValue int) int {
+	x.record(key, "int")
+	return x.JsonConfigProvider.GetIntDefault(key, defaultValue)
+}
+
+func (x *LayeredConfigProvider) GetStringSlice(key string) []string {
+	x.record(key, "[]string")
+	return x.JsonConfigProvider.GetStringSlice(key)
+}
+
+func (x *LayeredConfigProvider) GetIntSlice(key string) []int {
+	x.record(key, "[]int")
+	return x.JsonConfigProvider.GetIntSlice(key)
+}
+
+// MergeConfig merges src into dst, nested maps are merged, other values are replaced
+func MergeConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok1 := v.(map[string]interface{})
+		dstMap, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			MergeConfig(dstMap, srcMap)
+		} else {
+			dst[k] = v
+		}
+	}
+}
+
+// setConfigValue sets raw at the path and returns the dotted key, empty if the path addresses a missing array item
+func setConfigValue(root map[string]interface{}, segments []string, raw string) string {
+	var container interface{} = root
+	keys := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		var next interface{}
+		switch c := container.(type) {
+		case map[string]interface{}:
+			key := matchConfigKey(c, segment)
+			keys = append(keys, key)
+			if last {
+				c[key] = parseConfigValue(c[key], raw)
+				break
+			}
+			next = c[key]
+			if !isConfigContainer(next) {
+				next = make(map[string]interface{})
+				c[key] = next
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(c) {
+				return ""
+			}
+			keys = append(keys, segment)
+			if last {
+				c[index] = parseConfigValue(c[index], raw)
+				break
+			}
+			next = c[index]
+			if !isConfigContainer(next) {
+				next = make(map[string]interface{})
+				c[index] = next
+			}
+		}
+		container = next
+	}
+	return strings.Join(keys, ".")
+}
+
+func matchConfigKey(m map[string]interface{}, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+// parseConfigValue keeps raw as string if it replaces a string, otherwise parses it as json if possible
+func parseConfigValue(existing interface{}, raw string) interface{} {
+	if _, ok := existing.(string); ok {
+		return raw
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return raw
+	}
+	return v
+}
+
+// isConfigType returns false for struct fields which are services rather than config, e.g. routers, keys and session managers:
+// structs of the standard library and structs with methods of other modules
+func isConfigType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.PkgPath() == "" || t == reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	if !strings.Contains(strings.Split(t.PkgPath(), "/")[0], ".") {
+		return false
+	}
+	return strings.HasPrefix(t.PkgPath(), "github.com/DreamvatLab/") || reflect.PointerTo(t).NumMethod() == 0
+}
+
+func isConfigContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func flattenConfigKeys(prefix string, v interface{}, f func(key string)) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		for k, item := range c {
+			flattenConfigKeys(joinConfigKey(prefix, k), item, f)
+		}
+	case []interface{}:
+		f(prefix)
+		for i, item := range c {
+			if isConfigContainer(item) {
+				flattenConfigKeys(joinConfigKey(prefix, strconv.Itoa(i)), item, f)
+			}
+		}
+	default:
+		f(prefix)
+	}
+}
+
+func joinConfigKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
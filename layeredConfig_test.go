@@ -0,0 +1,162 @@
+package host
+
+import (
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLayeredConfigProvider(t *testing.T) {
+	dir := t.TempDir()
+	file := fp.Join(dir, "configs.json")
+	os.WriteFile(file, []byte(`{"ListenAddr":":80","Name":"api","Port":"8080","Log":{"Level":"info"},"OAuth":{"ClientSecret":"dev","ValidIssuers":["a"]},"Listeners":[{"Addr":":81"}]}`), 0644)
+	os.WriteFile(fp.Join(dir, "configs.prod.json"), []byte(`{"Log":{"Level":"warn"},"Debug":true}`), 0644)
+
+	t.Setenv("HOST__Env", "prod")
+	t.Setenv("HOST__OAUTH__CLIENTSECRET", "from-env")
+	t.Setenv("HOST__OAuth__ValidIssuers", `["b","c"]`)
+	t.Setenv("HOST__Port", "9090")
+	t.Setenv("HOST__Listeners__0__Addr", ":82")
+	t.Setenv("HOST__Listeners__5__Addr", ":85")
+
+	cp, err := NewLayeredConfigProvider(&LayeredConfigOptions{
+		File: file,
+		Args: []string{"--Log.Level=debug", "--Name", "web", "--config-schema"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.GetEnv() != "prod" || !cp.GetBool("Debug") {
+		t.Error("profile file is not loaded")
+	}
+	tests := map[string]string{
+		"ListenAddr":         ":80",
+		"Log.Level":          "debug",
+		"Name":               "web",
+		"Port":               "9090",
+		"OAuth.ClientSecret": "from-env",
+	}
+	for key, expected := range tests {
+		if actual := cp.GetString(key); actual != expected {
+			t.Errorf("%s = %s, expected %s", key, actual, expected)
+		}
+	}
+	var listeners []struct{ Addr string }
+	if err := cp.GetStruct("Listeners", &listeners); err != nil || len(listeners) != 1 || listeners[0].Addr != ":82" {
+		t.Errorf("Listeners = %v, expected item replaced by index", listeners)
+	}
+	if actual := cp.GetStringSlice("OAuth.ValidIssuers"); len(actual) != 2 {
+		t.Errorf("OAuth.ValidIssuers = %v, expected json array of env var", actual)
+	}
+	if actual := cp.GetSource("OAuth.ClientSecret"); actual != "env:HOST__OAUTH__CLIENTSECRET" {
+		t.Errorf("source of OAuth.ClientSecret = %s", actual)
+	}
+	if !cp.SchemaRequested() {
+		t.Error("schema is not requested")
+	}
+
+	var options struct {
+		ListenAddr string
+		CORS       *CORSOptions
+		Secret     string `json:"-"`
+	}
+	if err := cp.GetStruct("@this", &options); err != nil {
+		t.Fatal(err)
+	}
+	var schema strings.Builder
+	cp.DumpSchema(&schema)
+	for _, expected := range []string{"CORS.AllowedOrigin", "HOST__CORS__AllowedOrigin", "Log.Level", "file:" + file} {
+		if !strings.Contains(schema.String(), expected) {
+			t.Errorf("schema does not contain %s:\n%s", expected, schema.String())
+		}
+	}
+	if strings.Contains(schema.String(), "\nSecret ") {
+		t.Error("schema contains ignored field")
+	}
+}
+
+func TestLayeredConfigProviderArgs(t *testing.T) {
+	file := fp.Join(t.TempDir(), "configs.json")
+	os.WriteFile(file, []byte(`{"Name":"api"}`), 0644)
+
+	args := os.Args
+	os.Args = []string{"app", "--Name", "web", "--config-schema"}
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0) // Schema is dumped to stdout
+	t.Cleanup(func() {
+		os.Args = args
+		os.Stdout.Close()
+		os.Stdout = stdout
+	})
+
+	// Flags of the process are not config keys unless the caller opts in
+	cp, err := NewLayeredConfigProvider(&LayeredConfigOptions{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := cp.GetString("Name"); actual != "api" {
+		t.Errorf("Name = %s, expected flags are ignored", actual)
+	}
+	if !cp.SchemaRequested() {
+		t.Error("schema is not requested")
+	}
+	if dump, err := DumpConfigSchema(cp); !dump || err != nil {
+		t.Errorf("schema is not dumped: %v", err)
+	}
+	if _, ok := _dumpedSchemas.Load(cp); !ok {
+		t.Error("schema is not marked as dumped")
+	}
+
+	cp, err = NewLayeredConfigProvider(&LayeredConfigOptions{File: file, Args: os.Args[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := cp.GetString("Name"); actual != "web" {
+		t.Errorf("Name = %s, expected flag of opted in args", actual)
+	}
+
+	if dump, _ := DumpConfigSchema(&SecretConfigProvider{}); dump {
+		t.Error("schema is dumped by a provider which doesn't record keys")
+	}
+}
+
+func TestSchemaOfWrappedProvider(t *testing.T) {
+	file := fp.Join(t.TempDir(), "configs.json")
+	os.WriteFile(file, []byte(`{"Name":"api","OAuth":{"ClientSecret":"secret://client-secret"}}`), 0644)
+	t.Setenv("SECRET_CLIENT_SECRET", "s3cret")
+
+	layered, err := NewLayeredConfigProvider(&LayeredConfigOptions{File: file, Args: []string{"--config-schema"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := NewSecretConfigProvider(layered, NewEnvSecretProvider(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	if !cp.SchemaRequested() {
+		t.Error("schema request of the base provider is not forwarded")
+	}
+	if actual := cp.GetString("OAuth.ClientSecret"); actual != "s3cret" {
+		t.Errorf("OAuth.ClientSecret = %s, expected resolved secret", actual)
+	}
+	var cors CORSOptions
+	cp.GetStruct("CORS", &cors)
+	cp.GetIntDefault("Port", 80)
+
+	var schema strings.Builder
+	if err := cp.DumpSchema(&schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"OAuth.ClientSecret", "CORS.AllowedOrigin", "Port"} {
+		if !strings.Contains(schema.String(), expected) {
+			t.Errorf("schema does not contain %s read through the wrapper:\n%s", expected, schema.String())
+		}
+	}
+	if strings.Contains(schema.String(), "s3cret") {
+		t.Error("schema contains a secret value")
+	}
+}
@@ -0,0 +1,179 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+)
+
+type (
+	// LifecycleHook runs at a stage of the host lifecycle, ctx is canceled when the hook times out
+	LifecycleHook func(ctx context.Context) error
+
+	// Lifecycle runs hooks in registration order at each stage and cleanups in reverse registration order after the host stops.
+	// Register hooks before the host runs
+	Lifecycle struct {
+		HookTimeoutSeconds int // Timeout of each hook and cleanup, default 30
+		hooks              *lifecycleHooks
+	}
+
+	lifecycleHooks struct {
+		lock     sync.Mutex
+		start    []*namedHook
+		ready    []*namedHook
+		stopping []*namedHook
+		stopped  []*namedHook
+		cleanups []*namedHook
+	}
+
+	namedHook struct {
+		name string
+		hook LifecycleHook
+	}
+)
+
+func (x *Lifecycle) getHooks() *lifecycleHooks {
+	if x.hooks == nil {
+		x.hooks = new(lifecycleHooks)
+	}
+	return x.hooks
+}
+
+// OnStart registers a hook which runs before listening, a failed hook aborts the host
+func (x *Lifecycle) OnStart(name string, hook LifecycleHook) {
+	x.add(&x.getHooks().start, name, hook)
+}
+
+// OnReady registers a hook which runs after the host starts listening, e.g. service registration
+func (x *Lifecycle) OnReady(name string, hook LifecycleHook) {
+	x.add(&x.getHooks().ready, name, hook)
+}
+
+// OnStopping registers a hook which runs before servers stop, e.g. service deregistration
+func (x *Lifecycle) OnStopping(name string, hook LifecycleHook) {
+	x.add(&x.getHooks().stopping, name, hook)
+}
+
+// OnStopped registers a hook which runs after servers stop and before cleanups
+func (x *Lifecycle) OnStopped(name string, hook LifecycleHook) {
+	x.add(&x.getHooks().stopped, name, hook)
+}
+
+// AddCleanup registers release of a resource, cleanups run in reverse order of registration after OnStopped hooks
+func (x *Lifecycle) AddCleanup(name string, cleanup LifecycleHook) {
+	x.add(&x.getHooks().cleanups, name, cleanup)
+}
+
+// AddCloser registers v.Close as cleanup if v implements io.Closer or Close(), returns false otherwise
+func (x *Lifecycle) AddCloser(name string, v interface{}) bool {
+	switch c := v.(type) {
+	case io.Closer:
+		x.AddCleanup(name, func(ctx context.Context) error {
+			return c.Close()
+		})
+	case interface{ Close() }:
+		x.AddCleanup(name, func(ctx context.Context) error {
+			c.Close()
+			return nil
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+func (x *Lifecycle) add(hooks *[]*namedHook, name string, hook LifecycleHook) {
+	h := x.getHooks()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	*hooks = append(*hooks, &namedHook{name: name, hook: hook})
+}
+
+// take returns hooks of a stage and removes them, so each stage runs once
+func (x *Lifecycle) take(hooks *[]*namedHook) []*namedHook {
+	h := x.getHooks()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	r := *hooks
+	*hooks = nil
+	return r
+}
+
+// RunStartHooks runs OnStart hooks in order and stops at the first error
+func (x *Lifecycle) RunStartHooks(ctx context.Context) error {
+	for _, h := range x.take(&x.getHooks().start) {
+		if err := x.run(ctx, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunReadyHooks runs OnReady hooks in order and stops at the first error
+func (x *Lifecycle) RunReadyHooks(ctx context.Context) error {
+	for _, h := range x.take(&x.getHooks().ready) {
+		if err := x.run(ctx, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunStoppingHooks runs all OnStopping hooks in order, errors are joined
+func (x *Lifecycle) RunStoppingHooks(ctx context.Context) error {
+	var errs []error
+	for _, h := range x.take(&x.getHooks().stopping) {
+		errs = append(errs, x.run(ctx, h))
+	}
+	return errors.Join(errs...)
+}
+
+// RunStoppedHooks runs all OnStopped hooks in order, then all cleanups in reverse order, errors are joined
+func (x *Lifecycle) RunStoppedHooks(ctx context.Context) error {
+	var errs []error
+	for _, h := range x.take(&x.getHooks().stopped) {
+		errs = append(errs, x.run(ctx, h))
+	}
+
+	cleanups := x.take(&x.getHooks().cleanups)
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		errs = append(errs, x.run(ctx, cleanups[i]))
+	}
+	return errors.Join(errs...)
+}
+
+// run runs a hook with timeout, panics are returned as errors
+func (x *Lifecycle) run(ctx context.Context, h *namedHook) error {
+	timeout := x.HookTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 30
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- xerr.Errorf("lifecycle hook '%s' panicked: %v", h.name, r)
+			}
+		}()
+		done <- h.hook(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return xerr.WithMessage(err, "lifecycle hook '"+h.name+"' failed")
+		}
+		xlog.Debugf("lifecycle hook '%s' done", h.name)
+		return nil
+	case <-ctx.Done():
+		return xerr.Errorf("lifecycle hook '%s' timed out: %v", h.name, ctx.Err())
+	}
+}
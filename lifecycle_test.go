@@ -0,0 +1,68 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLifecycleOrder(t *testing.T) {
+	var lifecycle Lifecycle
+	var actual []string
+	hook := func(name string, err error) LifecycleHook {
+		return func(ctx context.Context) error {
+			actual = append(actual, name)
+			return err
+		}
+	}
+
+	lifecycle.OnStart("start", hook("start", nil))
+	lifecycle.OnReady("ready", hook("ready", nil))
+	lifecycle.OnStopping("stopping1", hook("stopping1", errors.New("stopping1")))
+	lifecycle.OnStopping("stopping2", hook("stopping2", nil))
+	lifecycle.OnStopped("stopped", hook("stopped", nil))
+	lifecycle.AddCleanup("cleanup1", hook("cleanup1", nil))
+	lifecycle.AddCleanup("cleanup2", hook("cleanup2", nil))
+
+	ctx := context.Background()
+	if err := lifecycle.RunStartHooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := lifecycle.RunReadyHooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := lifecycle.RunStoppingHooks(ctx); err == nil {
+		t.Error("expected error of stopping1")
+	}
+	if err := lifecycle.RunStoppedHooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Each stage runs once
+	if err := lifecycle.RunStoppedHooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"start", "ready", "stopping1", "stopping2", "stopped", "cleanup2", "cleanup1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("hooks ran as %v, expected %v", actual, expected)
+	}
+}
+
+func TestLifecycleTimeoutAndPanic(t *testing.T) {
+	lifecycle := Lifecycle{HookTimeoutSeconds: 1}
+	lifecycle.OnStart("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	if err := lifecycle.RunStartHooks(context.Background()); err == nil {
+		t.Error("expected timeout error")
+	}
+
+	lifecycle.OnReady("panic", func(ctx context.Context) error {
+		panic("boom")
+	})
+	if err := lifecycle.RunReadyHooks(context.Background()); err == nil {
+		t.Error("expected panic error")
+	}
+}
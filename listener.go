@@ -0,0 +1,152 @@
+package host
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	Network_TCP     = "tcp"
+	Network_Unix    = "unix"
+	Network_Systemd = "systemd"
+
+	_systemdFirstFD = 3 // SD_LISTEN_FDS_START
+)
+
+var (
+	_systemdOnce      sync.Once
+	_systemdListeners map[string]net.Listener
+	_systemdErr       error
+)
+
+// ListenerOptions describes a listener
+//
+//	{"Network": "tcp", "Addr": ":8080"}
+//	{"Network": "unix", "Addr": "/run/app/app.sock", "Mode": "0660"}
+//	{"Network": "systemd", "Addr": "http"}	// FileDescriptorName of the socket unit, or the index of the inherited fd
+type ListenerOptions struct {
+	Network string // tcp (default), unix or systemd
+	Addr    string
+	Mode    string // Octal file mode of unix socket
+}
+
+func (x *ListenerOptions) String() string {
+	network := x.Network
+	if network == "" {
+		network = Network_TCP
+	}
+	return network + "://" + x.Addr
+}
+
+// NewListener creates listener by options
+func NewListener(options *ListenerOptions) (net.Listener, error) {
+	if options == nil {
+		return nil, xerr.New("listener options cannot be nil")
+	}
+
+	switch options.Network {
+	case "", Network_TCP, "tcp4", "tcp6":
+		network := options.Network
+		if network == "" {
+			network = Network_TCP
+		}
+		ln, err := net.Listen(network, options.Addr)
+		return ln, xerr.WithStack(err)
+	case Network_Unix:
+		return newUnixListener(options)
+	case Network_Systemd:
+		return getSystemdListener(options.Addr)
+	default:
+		return nil, xerr.Errorf("unsupported listener network '%s'", options.Network)
+	}
+}
+
+func newUnixListener(options *ListenerOptions) (net.Listener, error) {
+	if options.Addr == "" {
+		return nil, xerr.New("unix socket path cannot be empty")
+	}
+
+	// Remove stale socket left by previous process
+	if fi, err := os.Stat(options.Addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, xerr.Errorf("'%s' exists and is not a socket", options.Addr)
+		}
+		if err = os.Remove(options.Addr); err != nil {
+			return nil, xerr.WithStack(err)
+		}
+	}
+
+	ln, err := net.Listen(Network_Unix, options.Addr)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	if options.Mode != "" {
+		mode, err := strconv.ParseUint(options.Mode, 8, 32)
+		if err != nil {
+			ln.Close()
+			return nil, xerr.Errorf("invalid unix socket mode '%s'", options.Mode)
+		}
+		if err = os.Chmod(options.Addr, os.FileMode(mode)); err != nil {
+			ln.Close()
+			return nil, xerr.WithStack(err)
+		}
+	}
+
+	return ln, nil
+}
+
+// getSystemdListener returns a listener inherited by socket activation (LISTEN_PID, LISTEN_FDS, LISTEN_FDNAMES), by name or index
+func getSystemdListener(name string) (net.Listener, error) {
+	_systemdOnce.Do(func() {
+		_systemdListeners, _systemdErr = loadSystemdListeners(_systemdFirstFD)
+	})
+	if _systemdErr != nil {
+		return nil, _systemdErr
+	}
+
+	if ln, ok := _systemdListeners[name]; ok {
+		return ln, nil
+	}
+	return nil, xerr.Errorf("systemd listener '%s' not found", name)
+}
+
+// loadSystemdListeners takes over LISTEN_FDS fds starting from firstFD, which is always SD_LISTEN_FDS_START except in tests
+func loadSystemdListeners(firstFD int) (map[string]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, xerr.New("no sockets passed by systemd: LISTEN_PID does not match")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, xerr.New("no sockets passed by systemd: invalid LISTEN_FDS")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	r := make(map[string]net.Listener, count*2)
+	for i := 0; i < count; i++ {
+		fd := firstFD + i
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close() // FileListener dups the fd
+		if err != nil {
+			return nil, xerr.WithStack(err)
+		}
+
+		r[strconv.Itoa(i)] = ln
+		if i < len(names) && names[i] != "" {
+			r[names[i]] = ln
+		}
+	}
+
+	// Don't pass them to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	return r, nil
+}
@@ -0,0 +1,62 @@
+package host
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestLoadSystemdListeners(t *testing.T) {
+	const firstFD = 200 // Far from fds of the runtime and the test binary
+
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "2")
+	if _, err := loadSystemdListeners(firstFD); err == nil {
+		t.Error("sockets of another process are taken")
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "x")
+	if _, err := loadSystemdListeners(firstFD); err == nil {
+		t.Error("invalid LISTEN_FDS is accepted")
+	}
+
+	// Pass two sockets like systemd does, the second one is not named
+	addrs := make([]string, 2)
+	for i := range addrs {
+		ln, err := net.Listen(Network_TCP, "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = ln.Addr().String()
+		f, err := ln.(*net.TCPListener).File()
+		ln.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Dup3(int(f.Fd()), firstFD+i, syscall.O_CLOEXEC); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "http")
+
+	listeners, err := loadSystemdListeners(firstFD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listeners["0"].Close()
+	defer listeners["1"].Close()
+
+	if listeners["http"] != listeners["0"] || listeners["http"].Addr().String() != addrs[0] {
+		t.Errorf("named listener %v, expected the first fd on %s", listeners["http"], addrs[0])
+	}
+	if len(listeners) != 3 || listeners["1"].Addr().String() != addrs[1] {
+		t.Errorf("listeners %v, expected the second fd on %s by index", listeners, addrs[1])
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("LISTEN_FDS is passed to child processes")
+	}
+}
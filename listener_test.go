@@ -0,0 +1,78 @@
+package host
+
+import (
+	"net"
+	"os"
+	fp "path/filepath"
+	"testing"
+)
+
+func TestNewListener(t *testing.T) {
+	if _, err := NewListener(nil); err == nil {
+		t.Error("nil options are accepted")
+	}
+	if _, err := NewListener(&ListenerOptions{Network: "udp", Addr: ":0"}); err == nil {
+		t.Error("unsupported network is accepted")
+	}
+
+	ln, err := NewListener(&ListenerOptions{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	if ln.Addr().Network() != Network_TCP {
+		t.Errorf("network %s, expected tcp by default", ln.Addr().Network())
+	}
+}
+
+func TestUnixListener(t *testing.T) {
+	dir := t.TempDir()
+	path := fp.Join(dir, "app.sock")
+
+	if _, err := NewListener(&ListenerOptions{Network: Network_Unix}); err == nil {
+		t.Error("empty socket path is accepted")
+	}
+
+	ln, err := NewListener(&ListenerOptions{Network: Network_Unix, Addr: path, Mode: "0600"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("mode %v, expected socket of 0600", fi.Mode())
+	}
+
+	// A socket left by a crashed process is removed
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("stale socket is expected to exist")
+	}
+	ln, err = NewListener(&ListenerOptions{Network: Network_Unix, Addr: path})
+	if err != nil {
+		t.Fatalf("stale socket is not removed: %v", err)
+	}
+	conn, err := net.Dial(Network_Unix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	ln.Close()
+
+	// Other files are never removed
+	file := fp.Join(dir, "file")
+	os.WriteFile(file, []byte("data"), 0644)
+	if _, err := NewListener(&ListenerOptions{Network: Network_Unix, Addr: file}); err == nil {
+		t.Error("regular file is replaced by a socket")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "data" {
+		t.Error("regular file is removed")
+	}
+
+	if _, err := NewListener(&ListenerOptions{Network: Network_Unix, Addr: fp.Join(dir, "bad.sock"), Mode: "rw"}); err == nil {
+		t.Error("invalid mode is accepted")
+	}
+}
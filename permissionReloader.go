@@ -0,0 +1,267 @@
+package host
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/go/xsecurity"
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// PermissionReloadOptions configures live reload of route and permission data.
+	// Redis keyspace notifications require 'notify-keyspace-events' to contain 'Kh' on the server,
+	// publishing any message to Channel works without server changes.
+	PermissionReloadOptions struct {
+		Channel               string // Pub/sub channel which triggers reload, default '{PermissionKey}:changed'
+		KeyspaceNotifications bool   // Also reload on keyspace notifications of RouteKey and PermissionKey
+		DebounceMilliseconds  int    // Wait for more changes before reloading, default 500
+		ReloadSeconds         int    // Periodic full reload as safety net for missed messages, disabled if <= 0
+	}
+
+	// PermissionReloadMetrics counters of a ReloadablePermissionAuditor
+	PermissionReloadMetrics struct {
+		ReloadCount     uint64
+		FailureCount    uint64
+		LastReloadTime  time.Time
+		LastFailureTime time.Time
+		LastError       string
+	}
+
+	// ReloadablePermissionAuditor loads route and permission data into a new auditor and swaps it in when complete,
+	// a failed reload keeps serving the previous data
+	ReloadablePermissionAuditor struct {
+		routeProvider      xsecurity.IRouteProvider
+		permissionProvider xsecurity.IPermissionProvider
+		snapshot           atomic.Value // *permissionSnapshot
+		reloadLock         sync.Mutex
+		metricsLock        sync.RWMutex
+		metrics            PermissionReloadMetrics
+		stop               chan struct{}
+		stopOnce           sync.Once
+	}
+
+	// permissionSnapshot is the loaded data and the auditor built on it
+	permissionSnapshot struct {
+		auditor     xsecurity.IPermissionAuditor
+		routes      map[string]*xdto.Route
+		permissions map[string]*xdto.Permission
+	}
+
+	// memory providers feed loaded data to xsecurity's auditor, which only calls GetRoutes/GetPermissions
+	memoryRouteProvider struct {
+		xsecurity.IRouteProvider
+		routes map[string]*xdto.Route
+	}
+	memoryPermissionProvider struct {
+		xsecurity.IPermissionProvider
+		permissions map[string]*xdto.Permission
+	}
+)
+
+var _ IRoutePermissionAuditor = (*ReloadablePermissionAuditor)(nil)
+
+// NewReloadablePermissionAuditor creates auditor and loads data, routeProvider can be nil
+func NewReloadablePermissionAuditor(permissionProvider xsecurity.IPermissionProvider, routeProvider xsecurity.IRouteProvider) (*ReloadablePermissionAuditor, error) {
+	if permissionProvider == nil {
+		return nil, xerr.New("permission provider cannot be nil")
+	}
+
+	r := &ReloadablePermissionAuditor{
+		routeProvider:      routeProvider,
+		permissionProvider: permissionProvider,
+		stop:               make(chan struct{}),
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads route and permission data from providers
+func (x *ReloadablePermissionAuditor) Reload() error {
+	x.reloadLock.Lock()
+	defer x.reloadLock.Unlock()
+
+	err := x.reload()
+
+	x.metricsLock.Lock()
+	if err != nil {
+		x.metrics.FailureCount++
+		x.metrics.LastFailureTime = time.Now()
+		x.metrics.LastError = err.Error()
+	} else {
+		x.metrics.ReloadCount++
+		x.metrics.LastReloadTime = time.Now()
+	}
+	x.metricsLock.Unlock()
+
+	return err
+}
+
+func (x *ReloadablePermissionAuditor) reload() error {
+	snapshot := new(permissionSnapshot)
+	var routeProvider xsecurity.IRouteProvider
+	if x.routeProvider != nil {
+		routes, err := x.routeProvider.GetRoutes()
+		if err != nil {
+			return err
+		}
+		snapshot.routes = routes
+		routeProvider = &memoryRouteProvider{routes: routes}
+	}
+
+	permissions, err := x.permissionProvider.GetPermissions()
+	if err != nil {
+		return err
+	}
+	snapshot.permissions = permissions
+
+	// Memory providers never fail, so NewPermissionAuditor won't exit the process
+	snapshot.auditor = xsecurity.NewPermissionAuditor(&memoryPermissionProvider{permissions: permissions}, routeProvider)
+	x.snapshot.Store(snapshot)
+	return nil
+}
+
+// Metrics returns a snapshot of reload counters
+func (x *ReloadablePermissionAuditor) Metrics() PermissionReloadMetrics {
+	x.metricsLock.RLock()
+	defer x.metricsLock.RUnlock()
+	return x.metrics
+}
+
+// WatchRedis reloads data when a message is published to options.Channel or, if enabled,
+// when keyspace notifications of routeKey/permissionKey arrive
+func (x *ReloadablePermissionAuditor) WatchRedis(redisConfig *xredis.RedisConfig, routeKey, permissionKey string, options *PermissionReloadOptions) error {
+	if redisConfig == nil {
+		return xerr.New("redis config cannot be nil")
+	}
+	if options == nil {
+		options = new(PermissionReloadOptions)
+	}
+	if options.Channel == "" {
+		if permissionKey == "" {
+			return xerr.New("permission reload channel cannot be empty")
+		}
+		options.Channel = permissionKey + ":changed"
+	}
+	if options.DebounceMilliseconds <= 0 {
+		options.DebounceMilliseconds = 500
+	}
+
+	channels := []string{options.Channel}
+	if options.KeyspaceNotifications {
+		prefix := "__keyspace@" + strconv.Itoa(redisConfig.DB) + "__:"
+		if routeKey != "" {
+			channels = append(channels, prefix+routeKey)
+		}
+		if permissionKey != "" {
+			channels = append(channels, prefix+permissionKey)
+		}
+	}
+
+	client := xredis.NewClient(redisConfig)
+	pubsub := client.Subscribe(context.Background(), channels...)
+	// Make sure subscription is established before returning
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		pubsub.Close()
+		client.Close()
+		return xerr.WithStack(err)
+	}
+
+	go x.watch(client, pubsub, options)
+	return nil
+}
+
+func (x *ReloadablePermissionAuditor) watch(client redis.UniversalClient, pubsub *redis.PubSub, options *PermissionReloadOptions) {
+	defer func() {
+		xerr.LogError(pubsub.Close())
+		xerr.LogError(client.Close())
+	}()
+
+	debounce := time.Duration(options.DebounceMilliseconds) * time.Millisecond
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	var tickerC <-chan time.Time
+	if options.ReloadSeconds > 0 {
+		ticker := time.NewTicker(time.Duration(options.ReloadSeconds) * time.Second)
+		defer ticker.Stop()
+		tickerC = ticker.C
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			xlog.Debugf("route/permission change notified by '%s'", msg.Channel)
+			timer.Reset(debounce)
+		case <-timer.C:
+			x.reloadAndLog()
+		case <-tickerC:
+			x.reloadAndLog()
+		case <-x.stop:
+			return
+		}
+	}
+}
+
+func (x *ReloadablePermissionAuditor) reloadAndLog() {
+	if err := x.Reload(); err != nil {
+		xlog.Errorf("reload routes and permissions failed: %+v", err)
+		return
+	}
+	xlog.Info("routes and permissions reloaded")
+}
+
+// Close stops watching redis
+func (x *ReloadablePermissionAuditor) Close() {
+	x.stopOnce.Do(func() {
+		close(x.stop)
+	})
+}
+
+func (x *ReloadablePermissionAuditor) getAuditor() xsecurity.IPermissionAuditor {
+	return x.snapshot.Load().(*permissionSnapshot).auditor
+}
+
+// GetRoutePermission returns the permission of a route in loaded data, nil if the route is not protected
+func (x *ReloadablePermissionAuditor) GetRoutePermission(area, controller, action string) *xdto.Permission {
+	snapshot := x.snapshot.Load().(*permissionSnapshot)
+	return FindRoutePermission(snapshot.routes, snapshot.permissions, area, controller, action)
+}
+
+func (x *ReloadablePermissionAuditor) CheckPermission(permissionID string, userRoles int64, userScopes []string) bool {
+	return x.getAuditor().CheckPermission(permissionID, userRoles, userScopes)
+}
+func (x *ReloadablePermissionAuditor) CheckPermissionWithLevel(permissionID string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckPermissionWithLevel(permissionID, userRoles, userLevel, userScopes)
+}
+func (x *ReloadablePermissionAuditor) CheckRoute(area, controller, action string, userRoles int64, userScopes []string) bool {
+	return x.getAuditor().CheckRoute(area, controller, action, userRoles, userScopes)
+}
+func (x *ReloadablePermissionAuditor) CheckRouteWithLevel(area, controller, action string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckRouteWithLevel(area, controller, action, userRoles, userLevel, userScopes)
+}
+func (x *ReloadablePermissionAuditor) CheckRouteKeyWithLevel(routeKey string, userRoles int64, userLevel int32, userScopes []string) bool {
+	return x.getAuditor().CheckRouteKeyWithLevel(routeKey, userRoles, userLevel, userScopes)
+}
+
+func (x *memoryRouteProvider) GetRoutes() (map[string]*xdto.Route, error) {
+	return x.routes, nil
+}
+func (x *memoryPermissionProvider) GetPermissions() (map[string]*xdto.Permission, error) {
+	return x.permissions, nil
+}
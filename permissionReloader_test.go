@@ -0,0 +1,89 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestReloadablePermissionAuditor(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisConfig := &xredis.RedisConfig{Addrs: []string{server.Addr()}}
+	routes, err := NewRedisRouteProvider("routes", redisConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer routes.Close()
+	permissions, err := NewRedisPermissionProvider("permissions", redisConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer permissions.Close()
+	permissions.CreatePermission(&xdto.Permission{ID: "users", AllowedRoles: 2})
+
+	auditor, err := NewReloadablePermissionAuditor(permissions, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditor.Close()
+	baseHost := &BaseHost{PermissionAuditor: auditor}
+	if metrics, ok := baseHost.GetPermissionReloadMetrics(); !ok || metrics.ReloadCount != 1 {
+		t.Fatalf("metrics %+v, expected the initial load", metrics)
+	}
+	if !auditor.CheckPermission("users", 2, nil) {
+		t.Fatal("loaded permission is not checked")
+	}
+
+	////////// Reload
+	permissions.UpdatePermission(&xdto.Permission{ID: "users", AllowedRoles: 4})
+	if !auditor.CheckPermission("users", 2, nil) {
+		t.Error("changes are seen before reload")
+	}
+	if err := auditor.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if auditor.CheckPermission("users", 2, nil) || !auditor.CheckPermission("users", 4, nil) {
+		t.Error("changes are not seen after reload")
+	}
+
+	////////// Debounce, messages in a burst reload once
+	err = auditor.WatchRedis(redisConfig, "routes", "permissions", &PermissionReloadOptions{DebounceMilliseconds: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	permissions.UpdatePermission(&xdto.Permission{ID: "users", AllowedRoles: 8})
+	for i := 0; i < 3; i++ {
+		server.Publish("permissions:changed", "1")
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for auditor.Metrics().ReloadCount < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if count := auditor.Metrics().ReloadCount; count != 3 {
+		t.Errorf("reload count %d, expected one reload of the burst", count)
+	}
+	if !auditor.CheckPermission("users", 8, nil) {
+		t.Error("notified changes are not seen")
+	}
+
+	////////// Failures keep the previous data
+	server.Close()
+	if err := auditor.Reload(); err == nil {
+		t.Fatal("reload succeeded without redis")
+	}
+	metrics := auditor.Metrics()
+	if metrics.FailureCount != 1 || metrics.ReloadCount != 3 || metrics.LastError == "" || metrics.LastFailureTime.IsZero() {
+		t.Errorf("metrics %+v, expected one failure", metrics)
+	}
+	if !auditor.CheckPermission("users", 8, nil) {
+		t.Error("previous data is dropped after a failed reload")
+	}
+
+	if _, ok := (&BaseHost{}).GetPermissionReloadMetrics(); ok {
+		t.Error("metrics of a host without reloadable auditor")
+	}
+}
@@ -0,0 +1,204 @@
+package host
+
+import (
+	"net"
+	"strings"
+
+	"github.com/DreamvatLab/go/xerr"
+)
+
+const (
+	Header_Forwarded       = "Forwarded"
+	Header_XForwardedFor   = "X-Forwarded-For"
+	Header_XForwardedProto = "X-Forwarded-Proto"
+	Header_XForwardedHost  = "X-Forwarded-Host"
+	Header_XRealIP         = "X-Real-IP"
+)
+
+type (
+	// ProxyHeaders holds the forwarding related headers of a request, repeated headers are joined by ","
+	ProxyHeaders struct {
+		Forwarded       string
+		XForwardedFor   string
+		XForwardedProto string
+		XForwardedHost  string
+		XRealIP         string
+	}
+
+	// ForwardedInfo describes the original request as seen by the outermost trusted proxy,
+	// Proto and Host are empty when they cannot be resolved
+	ForwardedInfo struct {
+		IP    string
+		Proto string
+		Host  string
+	}
+
+	forwardedElement struct {
+		forAddr string
+		proto   string
+		host    string
+	}
+)
+
+// ParseIPNets parses CIDRs like "10.0.0.0/8", single IPs are treated as /32 (IPv4) or /128 (IPv6)
+func ParseIPNets(cidrs []string) ([]*net.IPNet, error) {
+	r := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, xerr.Errorf("invalid ip: '%s'", cidr)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				r = append(r, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+			} else {
+				r = append(r, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+			}
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, xerr.Errorf("invalid cidr: '%s'", cidr)
+		}
+		r = append(r, ipNet)
+	}
+	return r, nil
+}
+
+// ContainsIP checks if ip is in any of the nets, returns false for invalid ip
+func ContainsIP(nets []*net.IPNet, ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveForwarded resolves the client ip, scheme and host of a request.
+// Proxy headers are only honored when the remote ip is a trusted proxy. Forwarded (RFC 7239) takes precedence over
+// X-Forwarded-For, which takes precedence over X-Real-IP. The address lists are walked from right to left and the first
+// address which is not a trusted proxy is the client, so spoofed entries added by the client are ignored.
+func ResolveForwarded(remoteIP string, headers *ProxyHeaders, trustedProxies []*net.IPNet) *ForwardedInfo {
+	r := &ForwardedInfo{IP: remoteIP}
+	if headers == nil || len(trustedProxies) == 0 || !ContainsIP(trustedProxies, remoteIP) {
+		return r
+	}
+
+	if headers.Forwarded != "" {
+		elements := parseForwarded(headers.Forwarded)
+		if len(elements) > 0 {
+			index := resolveClientIndex(len(elements), func(i int) string { return elements[i].forAddr }, trustedProxies)
+			r.IP = elements[index].forAddr
+			r.Proto = strings.ToLower(elements[index].proto)
+			r.Host = elements[index].host
+			return r
+		}
+	}
+
+	if headers.XForwardedFor != "" {
+		addrs := splitHeaderList(headers.XForwardedFor)
+		if len(addrs) > 0 {
+			index := resolveClientIndex(len(addrs), func(i int) string { return addrs[i] }, trustedProxies)
+			r.IP = addrs[index]
+			r.Proto = strings.ToLower(pickListValue(headers.XForwardedProto, index, len(addrs)))
+			r.Host = pickListValue(headers.XForwardedHost, index, len(addrs))
+			return r
+		}
+	}
+
+	if ip := strings.TrimSpace(headers.XRealIP); ip != "" {
+		r.IP = ip
+	}
+	r.Proto = strings.ToLower(pickListValue(headers.XForwardedProto, 0, 1))
+	r.Host = pickListValue(headers.XForwardedHost, 0, 1)
+
+	return r
+}
+
+// resolveClientIndex walks from right to left and returns the index of the first address which is not a trusted proxy,
+// if every address is trusted the left most one is the client
+func resolveClientIndex(count int, getAddr func(int) string, trustedProxies []*net.IPNet) int {
+	for i := count - 1; i >= 0; i-- {
+		if !ContainsIP(trustedProxies, getAddr(i)) {
+			return i
+		}
+	}
+	return 0
+}
+
+// pickListValue picks the value matching the client entry of X-Forwarded-For when both lists have the same length,
+// otherwise the first value, which is what a single edge proxy sets
+func pickListValue(header string, index, count int) string {
+	values := splitHeaderList(header)
+	if len(values) == 0 {
+		return ""
+	}
+	if len(values) == count {
+		return values[index]
+	}
+	return values[0]
+}
+
+func splitHeaderList(header string) []string {
+	var r []string
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// parseForwarded parses 'for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"'
+func parseForwarded(header string) []*forwardedElement {
+	var r []*forwardedElement
+	for _, element := range strings.Split(header, ",") {
+		e := new(forwardedElement)
+		for _, pair := range strings.Split(element, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			v = strings.Trim(strings.TrimSpace(v), `"`)
+			switch strings.ToLower(strings.TrimSpace(k)) {
+			case "for":
+				e.forAddr = stripPort(v)
+			case "proto":
+				e.proto = v
+			case "host":
+				e.host = v
+			}
+		}
+		if e.forAddr != "" {
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+// stripPort removes port and brackets, '[2001:db8::1]:4711' -> '2001:db8::1', '192.0.2.43:47011' -> '192.0.2.43'
+func stripPort(addr string) string {
+	if strings.HasPrefix(addr, "[") {
+		if end := strings.Index(addr, "]"); end > 0 {
+			return addr[1:end]
+		}
+		return addr
+	}
+	if strings.Count(addr, ":") == 1 {
+		return addr[:strings.Index(addr, ":")]
+	}
+	return addr
+}
@@ -0,0 +1,88 @@
+package host
+
+import (
+	"testing"
+)
+
+func TestResolveForwarded(t *testing.T) {
+	trusted, err := ParseIPNets([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		remoteIP string
+		headers  *ProxyHeaders
+		want     ForwardedInfo
+	}{
+		{
+			name:     "untrusted remote ignores headers",
+			remoteIP: "203.0.113.9",
+			headers:  &ProxyHeaders{XForwardedFor: "1.1.1.1", XForwardedProto: "https"},
+			want:     ForwardedInfo{IP: "203.0.113.9"},
+		},
+		{
+			name:     "trusted remote without headers",
+			remoteIP: "10.0.0.2",
+			headers:  &ProxyHeaders{},
+			want:     ForwardedInfo{IP: "10.0.0.2"},
+		},
+		{
+			name:     "x-forwarded-for right to left skips trusted proxies",
+			remoteIP: "10.0.0.2",
+			headers:  &ProxyHeaders{XForwardedFor: "6.6.6.6, 198.51.100.7, 10.1.1.1", XForwardedProto: "https", XForwardedHost: "example.com"},
+			want:     ForwardedInfo{IP: "198.51.100.7", Proto: "https", Host: "example.com"},
+		},
+		{
+			name:     "x-forwarded-for all trusted picks left most",
+			remoteIP: "192.168.1.1",
+			headers:  &ProxyHeaders{XForwardedFor: "10.2.2.2,10.1.1.1"},
+			want:     ForwardedInfo{IP: "10.2.2.2"},
+		},
+		{
+			name:     "forwarded takes precedence",
+			remoteIP: "10.0.0.2",
+			headers: &ProxyHeaders{
+				Forwarded:     `for=6.6.6.6;proto=http, for="[2001:db8:cafe::17]:4711";proto=HTTPS;host=example.com, for=10.1.1.1:8080`,
+				XForwardedFor: "5.5.5.5",
+			},
+			want: ForwardedInfo{IP: "2001:db8:cafe::17", Proto: "https", Host: "example.com"},
+		},
+		{
+			name:     "x-real-ip fallback",
+			remoteIP: "fd00::1",
+			headers:  &ProxyHeaders{XRealIP: "198.51.100.7", XForwardedProto: "https"},
+			want:     ForwardedInfo{IP: "198.51.100.7", Proto: "https"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveForwarded(tt.remoteIP, tt.headers, trusted)
+			if *got != tt.want {
+				t.Errorf("ResolveForwarded() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIPNets(t *testing.T) {
+	nets, err := ParseIPNets([]string{"10.0.0.0/8", " 127.0.0.1 ", "::1", ""})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("Expected 3 nets, got %d", len(nets))
+	}
+	if !ContainsIP(nets, "10.20.30.40") || !ContainsIP(nets, "127.0.0.1") || !ContainsIP(nets, "::1") {
+		t.Error("Expected ips to be contained")
+	}
+	if ContainsIP(nets, "127.0.0.2") || ContainsIP(nets, "invalid") {
+		t.Error("Expected ips not to be contained")
+	}
+
+	if _, err = ParseIPNets([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected error for invalid cidr")
+	}
+}
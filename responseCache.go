@@ -0,0 +1,502 @@
+package host
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xhttp"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	CacheStore_Memory = "memory"
+	CacheStore_Redis  = "redis"
+
+	Header_CacheControl = "Cache-Control"
+	Header_XCache       = "X-Cache"
+)
+
+// Response headers kept in cache
+var _cachedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", Header_CacheControl, "ETag", "Last-Modified", "Vary", "Location"}
+
+type (
+	// ResponseCacheRule caches 200 responses of GET/HEAD requests
+	ResponseCacheRule struct {
+		TTLSeconds  int      // Default 60, 'max-age'/'s-maxage' of the response wins
+		QueryParams []string // Query params in cache key, '*' means all
+		VaryHeaders []string // Request headers in cache key, e.g. 'Accept-Language'
+		VaryByUser  bool     // Key by Ctx_UserID, requests without it are not cached. Requests with credentials are only cached by such rules
+		Tags        []string // Responses can be invalidated by ResponseCache.InvalidateTags
+	}
+
+	// ResponseCacheOptions configures the response cache middleware
+	ResponseCacheOptions struct {
+		Store                string                        // memory (default) or redis
+		KeyPrefix            string                        // Default 'rcache'
+		Routes               map[string]*ResponseCacheRule // Keyed by RouteKey, 'area_controller_' and 'area__' apply to the whole controller/area
+		LockWaitMilliseconds int                           // Concurrent misses of a key wait for the first one at most this long, default 3000
+		SessionCookieName    string                        // Requests with this cookie carry credentials, any cookie does if empty. Web hosts set their session cookie
+	}
+
+	CachedResponse struct {
+		StatusCode  int
+		Headers     map[string]string
+		Body        []byte
+		TagVersions map[string]int64 // Entry is stale once a tag's version changes
+		Created     time.Time
+	}
+
+	IResponseCacheStore interface {
+		Get(key string) (*CachedResponse, error) // nil if not found
+		Set(key string, value *CachedResponse, ttl time.Duration) error
+		GetTagVersions(tags []string) (map[string]int64, error)
+		InvalidateTags(tags ...string) error
+	}
+
+	// ResponseCache is the response cache middleware
+	ResponseCache struct {
+		options  *ResponseCacheOptions
+		store    IResponseCacheStore
+		lock     sync.Mutex
+		inflight map[string]chan struct{}
+	}
+
+	memoryResponseCacheStore struct {
+		cache    *cache.Cache
+		lock     sync.RWMutex
+		versions map[string]int64
+	}
+
+	redisResponseCacheStore struct {
+		client redis.UniversalClient
+		prefix string
+	}
+
+	cacheControl struct {
+		noStore, noCache, private bool
+		maxAge                    int // -1 if absent
+	}
+)
+
+// NewResponseCache creates response cache, redisConfig is only required by redis store
+func NewResponseCache(options *ResponseCacheOptions, redisConfig *xredis.RedisConfig) (*ResponseCache, error) {
+	if options == nil {
+		return nil, xerr.New("response cache options cannot be nil")
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "rcache"
+	}
+	if options.LockWaitMilliseconds <= 0 {
+		options.LockWaitMilliseconds = 3000
+	}
+	for _, rule := range options.Routes {
+		if rule != nil && rule.TTLSeconds <= 0 {
+			rule.TTLSeconds = 60
+		}
+	}
+
+	var store IResponseCacheStore
+	switch options.Store {
+	case "", CacheStore_Memory:
+		store = NewMemoryResponseCacheStore()
+	case CacheStore_Redis:
+		if redisConfig == nil {
+			return nil, xerr.New("redis response cache store requires redis config")
+		}
+		store = NewRedisResponseCacheStore(options.KeyPrefix, redisConfig)
+	default:
+		return nil, xerr.Errorf("unsupported response cache store '%s'", options.Store)
+	}
+
+	return NewResponseCacheWithStore(options, store), nil
+}
+
+// NewResponseCacheWithStore creates response cache with a custom store
+func NewResponseCacheWithStore(options *ResponseCacheOptions, store IResponseCacheStore) *ResponseCache {
+	return &ResponseCache{
+		options:  options,
+		store:    store,
+		inflight: make(map[string]chan struct{}),
+	}
+}
+
+// Close closes the store if it holds connections
+func (x *ResponseCache) Close() error {
+	if c, ok := x.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// InvalidateTags makes all cached responses of tags stale
+func (x *ResponseCache) InvalidateTags(tags ...string) error {
+	return x.store.InvalidateTags(tags...)
+}
+
+// Handler is the middleware, requests with 'Cache-Control: no-cache' skip cached responses, 'no-store' skips caching at all.
+// Requests with Authorization or session cookie skip caching unless the rule is VaryByUser, so users never share entries
+func (x *ResponseCache) Handler(ctx IHttpContext) {
+	method := ctx.RequestMethod()
+	rule := x.matchRule(ctx.GetRouteKey())
+	if rule == nil || (method != http.MethodGet && method != http.MethodHead) {
+		ctx.Next()
+		return
+	}
+	if rule.VaryByUser {
+		if ctx.GetItemString(Ctx_UserID) == "" {
+			ctx.Next() // User is unknown, run AuthHandler before this middleware
+			return
+		}
+	} else if x.hasCredentials(ctx) {
+		ctx.Next()
+		return
+	}
+
+	reqCC := parseCacheControl(ctx.GetHeader(Header_CacheControl))
+	if reqCC.noStore {
+		ctx.Next()
+		return
+	}
+
+	key := x.getKey(ctx, rule)
+	if !reqCC.noCache {
+		if x.serveCached(ctx, key) {
+			return
+		}
+
+		// Stampede protection, only the first miss of a key runs the handlers
+		wait, leader := x.acquire(key)
+		if leader {
+			defer x.release(key, wait)
+		} else {
+			select {
+			case <-wait:
+			case <-time.After(time.Duration(x.options.LockWaitMilliseconds) * time.Millisecond):
+			}
+			if x.serveCached(ctx, key) {
+				return
+			}
+		}
+	}
+
+	// Read versions before running handlers, so an invalidation during the request makes this entry stale
+	versions, err := x.store.GetTagVersions(rule.Tags)
+	if xerr.LogError(err) {
+		ctx.Next()
+		return
+	}
+
+	ctx.SetHeader(Header_XCache, "MISS")
+	ctx.Next()
+	x.save(ctx, key, rule, versions)
+}
+
+func (x *ResponseCache) serveCached(ctx IHttpContext, key string) bool {
+	entry, err := x.store.Get(key)
+	if xerr.LogError(err) || entry == nil {
+		return false
+	}
+
+	if len(entry.TagVersions) > 0 {
+		tags := make([]string, 0, len(entry.TagVersions))
+		for tag := range entry.TagVersions {
+			tags = append(tags, tag)
+		}
+		versions, err := x.store.GetTagVersions(tags)
+		if xerr.LogError(err) {
+			return false
+		}
+		for tag, version := range entry.TagVersions {
+			if versions[tag] != version {
+				return false
+			}
+		}
+	}
+
+	ctx.SetHeader(Header_XCache, "HIT")
+	ctx.SetHeader("Age", strconv.Itoa(int(time.Since(entry.Created).Seconds())))
+	entry.Replay(ctx)
+	return true
+}
+
+func (x *ResponseCache) save(ctx IHttpContext, key string, rule *ResponseCacheRule, versions map[string]int64) {
+	if ctx.GetStatusCode() != http.StatusOK {
+		return
+	}
+
+	ttl := time.Duration(rule.TTLSeconds) * time.Second
+	respCC := parseCacheControl(ctx.GetResponseHeader(Header_CacheControl))
+	if respCC.noStore || (respCC.private && !rule.VaryByUser) {
+		return
+	}
+	if respCC.maxAge >= 0 {
+		ttl = time.Duration(respCC.maxAge) * time.Second
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	entry := NewCachedResponse(ctx)
+	entry.TagVersions = versions
+	xerr.LogError(x.store.Set(key, entry, ttl))
+}
+
+func (x *ResponseCache) acquire(key string) (chan struct{}, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if wait, ok := x.inflight[key]; ok {
+		return wait, false
+	}
+	wait := make(chan struct{})
+	x.inflight[key] = wait
+	return wait, true
+}
+
+func (x *ResponseCache) release(key string, wait chan struct{}) {
+	x.lock.Lock()
+	delete(x.inflight, key)
+	x.lock.Unlock()
+	close(wait)
+}
+
+// matchRule finds rule by 'area_controller_action', 'area_controller_' then 'area__'
+func (x *ResponseCache) matchRule(routeKey string) *ResponseCacheRule {
+	if len(x.options.Routes) == 0 || routeKey == "" {
+		return nil
+	}
+	if r, ok := x.options.Routes[routeKey]; ok {
+		return r
+	}
+	area, controller, _ := GetRoutesByKey(routeKey)
+	if r, ok := x.options.Routes[area+Seperator_Route+controller+Seperator_Route]; ok {
+		return r
+	}
+	return x.options.Routes[area+Seperator_Route+Seperator_Route]
+}
+
+func (x *ResponseCache) getKey(ctx IHttpContext, rule *ResponseCacheRule) string {
+	var sb strings.Builder
+	sb.WriteString(ctx.RequestMethod())
+	sb.WriteString(" ")
+	sb.WriteString(ctx.RequestPath())
+
+	if len(rule.QueryParams) > 0 {
+		query := make(url.Values)
+		if u, err := url.Parse(ctx.RequestURL()); err == nil {
+			query = u.Query()
+		}
+		if rule.QueryParams[0] != "*" {
+			selected := make(url.Values, len(rule.QueryParams))
+			for _, name := range rule.QueryParams {
+				if v, ok := query[name]; ok {
+					selected[name] = v
+				}
+			}
+			query = selected
+		}
+		sb.WriteString("?")
+		sb.WriteString(query.Encode()) // Sorted by key
+	}
+
+	for _, name := range rule.VaryHeaders {
+		sb.WriteString("\n" + name + ":" + ctx.GetHeader(name))
+	}
+
+	if rule.VaryByUser {
+		sb.WriteString("\nuser:" + ctx.GetItemString(Ctx_UserID))
+	}
+
+	hash := sha256.Sum256([]byte(sb.String()))
+	return ctx.GetRouteKey() + ":" + hex.EncodeToString(hash[:])
+}
+
+func (x *ResponseCache) hasCredentials(ctx IHttpContext) bool {
+	if ctx.GetHeader(xhttp.HEADER_AUTH) != "" {
+		return true
+	}
+	if x.options.SessionCookieName == "" {
+		return ctx.GetHeader("Cookie") != ""
+	}
+	return ctx.GetCookieString(x.options.SessionCookieName) != ""
+}
+
+// NewCachedResponse copies status code, body and _cachedHeaders of current response
+func NewCachedResponse(ctx IHttpContext) *CachedResponse {
+	r := &CachedResponse{
+		StatusCode: ctx.GetStatusCode(),
+		Headers:    make(map[string]string, len(_cachedHeaders)),
+		Body:       append([]byte(nil), ctx.GetResponseBody()...),
+		Created:    time.Now(),
+	}
+	for _, k := range _cachedHeaders {
+		if v := ctx.GetResponseHeader(k); v != "" {
+			r.Headers[k] = v
+		}
+	}
+	return r
+}
+
+// Replay writes the response to ctx
+func (x *CachedResponse) Replay(ctx IHttpContext) {
+	for k, v := range x.Headers {
+		ctx.SetHeader(k, v)
+	}
+	ctx.SetStatusCode(x.StatusCode)
+	ctx.WriteBytes(x.Body)
+}
+
+func parseCacheControl(header string) *cacheControl {
+	r := &cacheControl{maxAge: -1}
+	sMaxAge := -1
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			r.noStore = true
+		case "no-cache":
+			r.noCache = true
+		case "private":
+			r.private = true
+		case "max-age":
+			if v, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				r.maxAge = v
+			}
+		case "s-maxage":
+			if v, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				sMaxAge = v
+			}
+		}
+	}
+	if sMaxAge >= 0 {
+		r.maxAge = sMaxAge // Shared caches prefer s-maxage
+	}
+	return r
+}
+
+// NewMemoryResponseCacheStore creates in-process store
+func NewMemoryResponseCacheStore() IResponseCacheStore {
+	return &memoryResponseCacheStore{
+		cache:    cache.New(time.Minute, 10*time.Minute),
+		versions: make(map[string]int64),
+	}
+}
+
+func (x *memoryResponseCacheStore) Get(key string) (*CachedResponse, error) {
+	if v, ok := x.cache.Get(key); ok {
+		return v.(*CachedResponse), nil
+	}
+	return nil, nil
+}
+
+func (x *memoryResponseCacheStore) Set(key string, value *CachedResponse, ttl time.Duration) error {
+	x.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (x *memoryResponseCacheStore) GetTagVersions(tags []string) (map[string]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	r := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		r[tag] = x.versions[tag]
+	}
+	return r, nil
+}
+
+func (x *memoryResponseCacheStore) InvalidateTags(tags ...string) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for _, tag := range tags {
+		x.versions[tag]++
+	}
+	return nil
+}
+
+// NewRedisResponseCacheStore creates store shared by all instances, entries are '{prefix}:{RouteKey}:{hash}',
+// tag versions are '{prefix}:tag:{tag}'
+func NewRedisResponseCacheStore(prefix string, redisConfig *xredis.RedisConfig) IResponseCacheStore {
+	return &redisResponseCacheStore{
+		client: xredis.NewClient(redisConfig),
+		prefix: prefix,
+	}
+}
+
+func (x *redisResponseCacheStore) Close() error {
+	return xerr.WithStack(x.client.Close())
+}
+
+func (x *redisResponseCacheStore) Get(key string) (*CachedResponse, error) {
+	data, err := x.client.Get(context.Background(), x.prefix+":"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	r := new(CachedResponse)
+	err = json.Unmarshal(data, r)
+	return r, xerr.WithStack(err)
+}
+
+func (x *redisResponseCacheStore) Set(key string, value *CachedResponse, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	return xerr.WithStack(x.client.Set(context.Background(), x.prefix+":"+key, data, ttl).Err())
+}
+
+// GetTagVersions reads versions by a pipeline, which also works in cluster mode
+func (x *redisResponseCacheStore) GetTagVersions(tags []string) (map[string]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(tags))
+	_, err := x.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			cmds[i] = pipe.Get(context.Background(), x.prefix+":tag:"+tag)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	r := make(map[string]int64, len(tags))
+	for i, tag := range tags {
+		v, err := cmds[i].Int64()
+		if err != nil && err != redis.Nil {
+			return nil, xerr.WithStack(err)
+		}
+		r[tag] = v
+	}
+	return r, nil
+}
+
+func (x *redisResponseCacheStore) InvalidateTags(tags ...string) error {
+	_, err := x.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.Incr(context.Background(), x.prefix+":tag:"+tag)
+		}
+		return nil
+	})
+	return xerr.WithStack(err)
+}
@@ -0,0 +1,113 @@
+package host_test
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/host"
+	"github.com/DreamvatLab/host/hosttest"
+)
+
+func TestResponseCacheHandler(t *testing.T) {
+	bearer := func(userID string) func(*hosttest.Context) {
+		return func(ctx *hosttest.Context) {
+			ctx.Headers.Set("Authorization", "Bearer "+userID)
+			if userID != "" {
+				ctx.SetItem(host.Ctx_UserID, userID)
+			}
+		}
+	}
+	noCache := hosttest.WithRequestHeader(host.Header_CacheControl, "no-cache")
+	session := hosttest.WithCookie("sid", "s1")
+
+	tests := []struct {
+		name     string
+		rule     *host.ResponseCacheRule
+		requests []func(*hosttest.Context) // nil is an anonymous request
+		xCache   []string                  // Expected X-Cache of each request, the handler only runs if it's not 'HIT'
+	}{
+		{"anonymous", &host.ResponseCacheRule{}, []func(*hosttest.Context){nil, nil, noCache}, []string{"MISS", "HIT", "MISS"}},
+		// Responses of credentialed requests are neither cached nor served to anonymous requests
+		{"authorization", &host.ResponseCacheRule{}, []func(*hosttest.Context){bearer("a"), bearer("a"), nil}, []string{"", "", "MISS"}},
+		{"session cookie", &host.ResponseCacheRule{}, []func(*hosttest.Context){session, session, nil}, []string{"", "", "MISS"}},
+		{"vary by user without user", &host.ResponseCacheRule{VaryByUser: true}, []func(*hosttest.Context){bearer(""), bearer("")}, []string{"", ""}},
+		{"vary by user", &host.ResponseCacheRule{VaryByUser: true}, []func(*hosttest.Context){bearer("a"), bearer("a"), bearer("b")}, []string{"MISS", "HIT", "MISS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := host.NewResponseCache(&host.ResponseCacheOptions{
+				Routes:            map[string]*host.ResponseCacheRule{"api_products_": tt.rule},
+				SessionCookieName: "sid",
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			route := hosttest.NewRoute("GET", "/api/products", "api_products_list", cache.Handler, func(ctx host.IHttpContext) {
+				calls++
+				ctx.WriteString("products " + ctx.GetItemString(host.Ctx_UserID) + " " + strconv.Itoa(calls))
+			})
+
+			var previous string
+			for i, setup := range tt.requests {
+				expectedCalls := calls + 1
+				if tt.xCache[i] == "HIT" {
+					expectedCalls = calls
+				}
+				ctx := route.Run(setup)
+				if actual := ctx.ResponseHeaders.Get(host.Header_XCache); actual != tt.xCache[i] {
+					t.Fatalf("request %d: X-Cache = %q, expected %q", i, actual, tt.xCache[i])
+				}
+				if calls != expectedCalls {
+					t.Fatalf("request %d: handler calls = %d, expected %d", i, calls, expectedCalls)
+				}
+				if body := ctx.ResponseBody.String(); (body == previous) != (tt.xCache[i] == "HIT") {
+					t.Fatalf("request %d: body = %q, previous body = %q", i, body, previous)
+				}
+				previous = ctx.ResponseBody.String()
+			}
+		})
+	}
+}
+
+func TestResponseCacheStampede(t *testing.T) {
+	cache, err := host.NewResponseCache(&host.ResponseCacheOptions{
+		Routes: map[string]*host.ResponseCacheRule{"api_products_": {}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	release := make(chan struct{})
+	route := hosttest.NewRoute("GET", "/api/products", "api_products_list", cache.Handler, func(ctx host.IHttpContext) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.WriteString("products")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = route.Run().ResponseBody.String()
+		}(i)
+	}
+
+	time.Sleep(100 * time.Millisecond) // Let all requests miss while the first one is running
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("handler calls = %d, expected 1", calls)
+	}
+	for _, body := range bodies {
+		if body != "products" {
+			t.Fatalf("body = %q, expected 'products'", body)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected cacheControl
+	}{
+		{"", cacheControl{maxAge: -1}},
+		{"no-store", cacheControl{noStore: true, maxAge: -1}},
+		{"private, max-age=30", cacheControl{private: true, maxAge: 30}},
+		{"max-age=30, s-maxage=120", cacheControl{maxAge: 120}},
+		{"No-Cache, max-age=\"5\"", cacheControl{noCache: true, maxAge: 5}},
+	}
+	for _, tt := range tests {
+		if actual := parseCacheControl(tt.header); *actual != tt.expected {
+			t.Errorf("parseCacheControl(%q) = %+v, expected %+v", tt.header, *actual, tt.expected)
+		}
+	}
+}
+
+func TestMemoryResponseCacheStoreTags(t *testing.T) {
+	store := NewMemoryResponseCacheStore()
+	versions, _ := store.GetTagVersions([]string{"products"})
+	store.Set("k", &CachedResponse{StatusCode: 200, TagVersions: versions}, time.Minute)
+
+	cache := NewResponseCacheWithStore(&ResponseCacheOptions{}, store)
+	if err := cache.InvalidateTags("products"); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := store.GetTagVersions([]string{"products"})
+	if current["products"] == versions["products"] {
+		t.Fatal("tag version is not changed by invalidation")
+	}
+}
+
+func TestRedisResponseCacheStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisResponseCacheStore("rcache", &xredis.RedisConfig{Addrs: []string{server.Addr()}})
+	defer store.(*redisResponseCacheStore).Close()
+
+	if entry, err := store.Get("missing"); err != nil || entry != nil {
+		t.Fatalf("Get(missing) = %v, %v, expected nil", entry, err)
+	}
+
+	versions, err := store.GetTagVersions([]string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("k", &CachedResponse{StatusCode: 200, Body: []byte("ok"), TagVersions: versions}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("rcache:k") {
+		t.Fatal("entry is not prefixed")
+	}
+
+	entry, err := store.Get("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || string(entry.Body) != "ok" || entry.StatusCode != 200 {
+		t.Fatalf("Get(k) = %+v", entry)
+	}
+
+	if err := store.InvalidateTags("products"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := store.GetTagVersions([]string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current["products"] == entry.TagVersions["products"] {
+		t.Fatal("tag version is not changed by invalidation")
+	}
+
+	server.FastForward(2 * time.Minute)
+	if entry, _ := store.Get("k"); entry != nil {
+		t.Fatal("entry outlives its ttl")
+	}
+}
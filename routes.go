@@ -0,0 +1,105 @@
+package host
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xsecurity"
+)
+
+type (
+	// RouteInfo describes a registered route
+	RouteInfo struct {
+		Method           string
+		Path             string
+		RouteKey         string
+		Area             string
+		Controller       string
+		Action           string
+		Handlers         []string
+		Permission       *xdto.Permission  `json:",omitempty"` // Effective permission, nil if permission data is unavailable or missing
+		ActionPermission *ActionPermission `json:"-"`          // Declared permission of the action, it wins over the auditor's data
+	}
+
+	// IRoutePermissionAuditor is implemented by permission auditors which expose the permission effective for a route
+	IRoutePermissionAuditor interface {
+		xsecurity.IPermissionAuditor
+		GetRoutePermission(area, controller, action string) *xdto.Permission
+	}
+)
+
+// NewRouteInfo creates route info, handlers can be functions of any signature, only their names are kept
+func NewRouteInfo(method, path, routeKey string, handlers ...interface{}) *RouteInfo {
+	area, controller, action := GetRoutesByKey(routeKey)
+	r := &RouteInfo{
+		Method:     method,
+		Path:       path,
+		RouteKey:   routeKey,
+		Area:       area,
+		Controller: controller,
+		Action:     action,
+		Handlers:   make([]string, 0, len(handlers)),
+	}
+	for _, h := range handlers {
+		r.Handlers = append(r.Handlers, GetHandlerName(h))
+	}
+	return r
+}
+
+// GetHandlerName returns the full function name of a handler, e.g. 'github.com/x/api.(*UserController).Get-fm'
+func GetHandlerName(handler interface{}) string {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// FillRoutePermissions sets the permission each route is checked against: the declared permission of its action,
+// or the permission auditor resolves by 'area_controller_action', then 'area_controller_', then 'area__'.
+// Auditors which don't implement IRoutePermissionAuditor only contribute declared permissions
+func FillRoutePermissions(routes []*RouteInfo, auditor xsecurity.IPermissionAuditor) {
+	routeAuditor, _ := auditor.(IRoutePermissionAuditor)
+	for _, r := range routes {
+		if r.ActionPermission != nil {
+			r.Permission = r.ActionPermission.ToPermission(r.RouteKey)
+		} else if routeAuditor != nil {
+			r.Permission = routeAuditor.GetRoutePermission(r.Area, r.Controller, r.Action)
+		}
+	}
+}
+
+// FindRoutePermission looks up the permission of a route in loaded data the same way as xsecurity's permission auditor
+func FindRoutePermission(routes map[string]*xdto.Route, permissions map[string]*xdto.Permission, area, controller, action string) *xdto.Permission {
+	for _, key := range []string{
+		area + Seperator_Route + controller + Seperator_Route + action,
+		area + Seperator_Route + controller + Seperator_Route,
+		area + Seperator_Route + Seperator_Route,
+	} {
+		if route, ok := routes[key]; ok {
+			return permissions[route.Permission_ID]
+		}
+	}
+	return nil
+}
+
+// NewRoutesHandler creates a handler which dumps all routes of webHost as json, auditor is optional
+func NewRoutesHandler(webHost IWebHost, auditor xsecurity.IPermissionAuditor) RequestHandler {
+	return func(ctx IHttpContext) {
+		routes := webHost.GetRoutes()
+		FillRoutePermissions(routes, auditor)
+
+		data, err := json.Marshal(routes)
+		if HandleErr(err, ctx) {
+			return
+		}
+		ctx.SetStatusCode(http.StatusOK)
+		ctx.WriteJsonBytes(data)
+	}
+}
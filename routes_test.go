@@ -0,0 +1,50 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/DreamvatLab/go/xdto"
+)
+
+func TestFillRoutePermissions(t *testing.T) {
+	auditor, err := NewReloadablePermissionAuditor(
+		&memoryPermissionProvider{permissions: map[string]*xdto.Permission{
+			"orders": {ID: "orders", AllowedRoles: 2},
+			"admin":  {ID: "admin", AllowedRoles: 4},
+		}},
+		&memoryRouteProvider{routes: map[string]*xdto.Route{
+			"api_orders_":  {ID: "api_orders_", Permission_ID: "orders"},
+			"api__":        {ID: "api__", Permission_ID: "admin"},
+			"api_orders_x": {ID: "api_orders_x", Permission_ID: "missing"},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []*RouteInfo{
+		NewRouteInfo("GET", "/orders", "api_orders_list"),
+		NewRouteInfo("GET", "/users", "api_users_list"),
+		NewRouteInfo("GET", "/orders/x", "api_orders_x"),
+		NewRouteInfo("GET", "/health", "health"),
+		NewRouteInfo("POST", "/orders", "api_orders_create"),
+	}
+	routes[4].ActionPermission = &ActionPermission{AllowAnyUser: true}
+	FillRoutePermissions(routes, auditor)
+
+	expected := []string{"orders", "admin", "", "", "api_orders_create"}
+	for i, r := range routes {
+		var actual string
+		if r.Permission != nil {
+			actual = r.Permission.ID
+		}
+		if actual != expected[i] {
+			t.Errorf("permission of %s = %q, expected %q", r.RouteKey, actual, expected[i])
+		}
+	}
+	if !routes[4].Permission.IsAllowAnyUser {
+		t.Error("declared permission is not preferred")
+	}
+
+	FillRoutePermissions(routes[:1], nil) // Auditors without route lookup are skipped
+}
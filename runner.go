@@ -0,0 +1,124 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+)
+
+// Runner runs several hosts in one process, e.g. a web host and a grpc host built from one config provider by NewSectionConfigProvider.
+// When a signal arrives, the context is done or any host exits, all hosts are shut down gracefully
+type Runner struct {
+	ShutdownTimeoutSeconds int         // Timeout of shutting down all hosts, default 30
+	Signals                []os.Signal // Signals which stop the hosts, default SIGINT and SIGTERM
+	hosts                  []IHost
+}
+
+func NewRunner(hosts ...IHost) *Runner {
+	return &Runner{hosts: hosts}
+}
+
+// RunHosts runs hosts until a signal arrives or any host exits
+func RunHosts(hosts ...IHost) error {
+	return NewRunner(hosts...).Run()
+}
+
+func (x *Runner) Add(hosts ...IHost) *Runner {
+	x.hosts = append(x.hosts, hosts...)
+	return x
+}
+
+func (x *Runner) Run() error {
+	return x.RunContext(context.Background())
+}
+
+// RunContext runs all hosts concurrently, returns errors of hosts which exited by themselves or failed to shut down
+func (x *Runner) RunContext(ctx context.Context) error {
+	if len(x.hosts) == 0 {
+		return xerr.New("no host to run")
+	}
+
+	signals := x.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ctx, stop := signal.NotifyContext(ctx, signals...)
+	defer stop()
+
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan *result, len(x.hosts))
+	for i, h := range x.hosts {
+		go func(i int, h IHost) {
+			results <- &result{index: i, err: h.Run()}
+		}(i, h)
+	}
+
+	var errs []error
+	remaining := len(x.hosts)
+	hostErr := func(r *result) {
+		remaining--
+		if r.err != nil {
+			errs = append(errs, xerr.WithMessage(r.err, x.hostName(r.index)+" exited"))
+		}
+	}
+
+	////////// Wait for the first stop reason
+	select {
+	case <-ctx.Done():
+		xlog.Info("Shutting down hosts")
+	case r := <-results:
+		hostErr(r)
+		xlog.Warnf("%s exited, shutting down other hosts", x.hostName(r.index))
+	}
+
+	////////// Shut down all hosts in parallel, shutting down an exited host only waits for its own shutdown
+	timeout := x.ShutdownTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 30
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for i, h := range x.hosts {
+		wg.Add(1)
+		go func(i int, h IHost) {
+			defer wg.Done()
+			if err := h.Shutdown(shutdownCtx); err != nil {
+				lock.Lock()
+				errs = append(errs, xerr.WithMessage(err, x.hostName(i)+" shutdown failed"))
+				lock.Unlock()
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	////////// Collect hosts still running, they return after shutdown
+	for remaining > 0 {
+		select {
+		case r := <-results:
+			hostErr(r)
+		case <-shutdownCtx.Done():
+			errs = append(errs, xerr.Errorf("%d host(s) did not exit: %v", remaining, shutdownCtx.Err()))
+			remaining = 0
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (x *Runner) hostName(index int) string {
+	return fmt.Sprintf("host %d (%T)", index, x.hosts[index])
+}
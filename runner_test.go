@@ -0,0 +1,93 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DreamvatLab/go/xconfig"
+)
+
+type testHost struct {
+	Lifecycle
+	runErr error
+	stop   chan struct{}
+}
+
+func newTestHost(runErr error) *testHost {
+	return &testHost{runErr: runErr, stop: make(chan struct{})}
+}
+
+func (x *testHost) Run() error {
+	if x.runErr != nil {
+		return x.runErr
+	}
+	<-x.stop
+	return nil
+}
+
+func (x *testHost) Shutdown(ctx context.Context) error {
+	select {
+	case <-x.stop:
+	default:
+		close(x.stop)
+	}
+	return nil
+}
+
+func (x *testHost) GetLifecycle() *Lifecycle {
+	return &x.Lifecycle
+}
+
+func TestRunnerFailFast(t *testing.T) {
+	failed := errors.New("listen failed")
+	running := newTestHost(nil)
+	err := NewRunner(running, newTestHost(failed)).Run()
+	if !errors.Is(err, failed) {
+		t.Errorf("expected error of the failed host, got %v", err)
+	}
+	select {
+	case <-running.stop:
+	default:
+		t.Error("running host was not shut down")
+	}
+}
+
+func TestRunnerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRunner(newTestHost(nil), newTestHost(nil)).RunContext(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSectionConfigProvider(t *testing.T) {
+	cp := &xconfig.JsonConfigProvider{
+		RawJson: []byte(`{"ListenAddr":":80","Log":{"Level":"info","File":"a.log"},"GRPC":{"ListenAddr":":90","Log":{"Level":"debug"}}}`),
+	}
+	if err := json.Unmarshal(cp.RawJson, &cp.MapConfiguration); err != nil {
+		t.Fatal(err)
+	}
+	grpc := NewSectionConfigProvider(cp, "GRPC")
+
+	if actual := grpc.GetString("ListenAddr"); actual != ":90" {
+		t.Errorf("ListenAddr = %s, expected :90", actual)
+	}
+
+	var log struct{ Level, File string }
+	if err := grpc.GetStruct("Log", &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Level != "debug" || log.File != "a.log" {
+		t.Errorf("Log = %+v, expected section level over root file", log)
+	}
+
+	var this struct{ ListenAddr string }
+	if err := grpc.GetStruct("@this", &this); err != nil {
+		t.Fatal(err)
+	}
+	if this.ListenAddr != ":90" {
+		t.Errorf("@this ListenAddr = %s, expected :90", this.ListenAddr)
+	}
+}
@@ -0,0 +1,336 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+)
+
+// SecretScheme prefixes config values which are resolved by secret providers, e.g. "HashKey": "secret://cookie-hash-key"
+const SecretScheme = "secret://"
+
+var ErrSecretNotFound = xerr.New("secret not found")
+
+type (
+	// ISecretProvider returns secret values by name, ErrSecretNotFound is returned if the provider doesn't have it
+	ISecretProvider interface {
+		GetSecret(name string) ([]byte, error)
+	}
+
+	// ISecretRotator is implemented by secret providers which detect changed secrets
+	ISecretRotator interface {
+		// OnRotate registers a callback fired with the name of a secret whose value changed
+		OnRotate(callback func(name string))
+	}
+
+	// ISecretFile is implemented by secret providers which keep secrets in files, their paths are used by '...Path' config keys
+	ISecretFile interface {
+		GetSecretPath(name string) (string, error)
+	}
+
+	// ISecretConfigProvider is implemented by config providers resolving secrets, the admin config dump redacts their secret keys
+	ISecretConfigProvider interface {
+		xconfig.IConfigProvider
+		GetSecretKeys() []string
+	}
+
+	// SecretConfigProvider resolves 'secret://name' values of a base provider by secret providers tried in order.
+	// Values of keys ending with 'Path' (e.g. PrivateKeyPath) are resolved to a file holding the secret, other values to the secret itself.
+	// Callbacks of keys referencing a secret are fired when the secret rotates.
+	// Reads are recorded by the base provider, so it can wrap a LayeredConfigProvider or be wrapped by other providers
+	SecretConfigProvider struct {
+		providers []ISecretProvider
+		lock      sync.RWMutex
+		current   *xconfig.JsonConfigProvider
+		refs      map[string][]string // Secret name => config keys referencing it
+		tempDir   string
+		ConfigChangeNotifier
+		ConfigSchemaForwarder
+	}
+)
+
+var (
+	_ IConfigWatcher        = (*SecretConfigProvider)(nil)
+	_ ISecretConfigProvider = (*SecretConfigProvider)(nil)
+	_ IConfigSchema         = (*SecretConfigProvider)(nil)
+)
+
+// NewSecretConfigProvider resolves secrets of cp, at least one provider is required
+func NewSecretConfigProvider(cp xconfig.IConfigProvider, providers ...ISecretProvider) (*SecretConfigProvider, error) {
+	if len(providers) == 0 {
+		return nil, xerr.New("secret providers cannot be empty")
+	}
+
+	r := &SecretConfigProvider{
+		providers:             providers,
+		ConfigSchemaForwarder: ConfigSchemaForwarder{Base: cp},
+	}
+	if err := r.reload(); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	for _, p := range providers {
+		if rotator, ok := p.(ISecretRotator); ok {
+			rotator.OnRotate(r.rotate)
+		}
+	}
+	if watcher, ok := cp.(IConfigWatcher); ok {
+		watcher.OnChange("", func(xconfig.IConfigProvider) {
+			if err := r.reload(); err != nil {
+				xlog.Errorf("failed to resolve secrets of changed config, current config is kept: %+v", err)
+			}
+		})
+	}
+	return r, nil
+}
+
+// GetSecret returns the secret from the first provider which has it
+func (x *SecretConfigProvider) GetSecret(name string) ([]byte, error) {
+	for _, p := range x.providers {
+		v, err := p.GetSecret(name)
+		if err == nil {
+			return v, nil
+		}
+		if !xerr.Is(err, ErrSecretNotFound) {
+			return nil, err
+		}
+	}
+	return nil, xerr.WithMessage(ErrSecretNotFound, name)
+}
+
+// Close removes files of secrets written for '...Path' keys
+func (x *SecretConfigProvider) Close() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.tempDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(x.tempDir)
+	x.tempDir = ""
+	return xerr.WithStack(err)
+}
+
+// GetSecretKeys returns config keys referencing secrets, e.g. 'OAuth.ClientSecret'
+func (x *SecretConfigProvider) GetSecretKeys() []string {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	var r []string
+	for _, keys := range x.refs {
+		r = append(r, keys...)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (x *SecretConfigProvider) rotate(name string) {
+	x.lock.RLock()
+	keys := x.refs[name]
+	x.lock.RUnlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	xlog.Infof("secret '%s' rotated, referenced by %v", name, keys)
+	if err := x.reload(keys...); err != nil {
+		xlog.Errorf("failed to resolve rotated secret '%s', current config is kept: %+v", name, err)
+	}
+}
+
+// reload resolves all secrets and notifies changes, changedKeys are notified even if their values stay the same
+func (x *SecretConfigProvider) reload(changedKeys ...string) error {
+	root := make(map[string]interface{})
+	if err := x.Base.GetStruct("@this", &root); err != nil {
+		return err
+	}
+
+	refs := make(map[string][]string)
+	resolved, err := x.resolve("", root, refs)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(resolved)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	current := &xconfig.JsonConfigProvider{
+		RawJson:          raw,
+		MapConfiguration: xconfig.MapConfiguration(resolved.(map[string]interface{})),
+	}
+
+	x.lock.Lock()
+	previous := x.current
+	x.current = current
+	x.refs = refs
+	x.lock.Unlock()
+
+	if previous != nil && (len(changedKeys) > 0 || !bytes.Equal(previous.RawJson, current.RawJson)) {
+		x.Notify(x, previous.RawJson, current.RawJson, changedKeys...)
+	}
+	return nil
+}
+
+func (x *SecretConfigProvider) resolve(key string, v interface{}, refs map[string][]string) (interface{}, error) {
+	switch a := v.(type) {
+	case map[string]interface{}:
+		for k, item := range a {
+			r, err := x.resolve(joinConfigKey(key, k), item, refs)
+			if err != nil {
+				return nil, err
+			}
+			a[k] = r
+		}
+		return a, nil
+	case []interface{}:
+		for i, item := range a {
+			r, err := x.resolve(joinConfigKey(key, strconv.Itoa(i)), item, refs)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = r
+		}
+		return a, nil
+	case string:
+		name, ok := strings.CutPrefix(a, SecretScheme)
+		if !ok {
+			return a, nil
+		}
+		refs[name] = append(refs[name], key)
+		if strings.HasSuffix(key, "Path") {
+			path, err := x.getSecretPath(name)
+			return path, xerr.WithMessage(err, "failed to resolve '"+key+"'")
+		}
+		secret, err := x.GetSecret(name)
+		if err != nil {
+			return nil, xerr.WithMessage(err, "failed to resolve '"+key+"'")
+		}
+		return string(secret), nil
+	default:
+		return v, nil
+	}
+}
+
+// FindUnresolvedSecrets returns config keys whose values are still 'secret://' references
+func FindUnresolvedSecrets(cp xconfig.IConfigProvider) []string {
+	root := make(map[string]interface{})
+	if cp.GetStruct("@this", &root) != nil {
+		return nil
+	}
+	var r []string
+	findSecretRefs("", root, func(key string) {
+		r = append(r, key)
+	})
+	sort.Strings(r)
+	return r
+}
+
+func findSecretRefs(key string, v interface{}, f func(key string)) {
+	switch a := v.(type) {
+	case map[string]interface{}:
+		for k, item := range a {
+			findSecretRefs(joinConfigKey(key, k), item, f)
+		}
+	case []interface{}:
+		for i, item := range a {
+			findSecretRefs(joinConfigKey(key, strconv.Itoa(i)), item, f)
+		}
+	case string:
+		if strings.HasPrefix(a, SecretScheme) {
+			f(key)
+		}
+	}
+}
+
+// getSecretPath returns the file of providers keeping secrets in files, or writes the secret to a private temp file
+func (x *SecretConfigProvider) getSecretPath(name string) (string, error) {
+	for _, p := range x.providers {
+		if f, ok := p.(ISecretFile); ok {
+			path, err := f.GetSecretPath(name)
+			if err == nil {
+				return path, nil
+			}
+			if !xerr.Is(err, ErrSecretNotFound) {
+				return "", err
+			}
+		}
+	}
+
+	secret, err := x.GetSecret(name)
+	if err != nil {
+		return "", err
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.tempDir == "" {
+		if x.tempDir, err = os.MkdirTemp("", "host-secrets-"); err != nil {
+			return "", xerr.WithStack(err)
+		}
+	}
+	path := fp.Join(x.tempDir, secretFileName(name))
+	// Rewritten in place, so the path stays the same after rotation
+	if err := os.WriteFile(path, secret, 0600); err != nil {
+		return "", xerr.WithStack(err)
+	}
+	return path, nil
+}
+
+func (x *SecretConfigProvider) get() *xconfig.JsonConfigProvider {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.current
+}
+
+func (x *SecretConfigProvider) GetStruct(key string, target interface{}) error {
+	x.RecordRead(key, target)
+	return x.get().GetStruct(key, target)
+}
+
+func (x *SecretConfigProvider) GetString(key string) string {
+	x.RecordRead(key, "")
+	return x.get().GetString(key)
+}
+
+func (x *SecretConfigProvider) GetStringDefault(key string, defaultValue string) string {
+	x.RecordRead(key, "")
+	return x.get().GetStringDefault(key, defaultValue)
+}
+
+func (x *SecretConfigProvider) GetBool(key string) bool {
+	x.RecordRead(key, false)
+	return x.get().GetBool(key)
+}
+
+func (x *SecretConfigProvider) GetFloat64(key string) float64 {
+	x.RecordRead(key, float64(0))
+	return x.get().GetFloat64(key)
+}
+
+func (x *SecretConfigProvider) GetInt(key string) int {
+	x.RecordRead(key, 0)
+	return x.get().GetInt(key)
+}
+
+func (x *SecretConfigProvider) GetIntDefault(key string, defaultValue int) int {
+	x.RecordRead(key, 0)
+	return x.get().GetIntDefault(key, defaultValue)
+}
+
+func (x *SecretConfigProvider) GetStringSlice(key string) []string {
+	x.RecordRead(key, []string(nil))
+	return x.get().GetStringSlice(key)
+}
+
+func (x *SecretConfigProvider) GetIntSlice(key string) []int {
+	x.RecordRead(key, []int(nil))
+	return x.get().GetIntSlice(key)
+}
@@ -0,0 +1,357 @@
+package host
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+)
+
+type (
+	// FileSecretProvider reads secrets from files named by secret names under a directory, e.g. docker or kubernetes secrets mounted at /run/secrets.
+	// Files of secrets read before are checked periodically for rotation
+	FileSecretProvider struct {
+		dir string
+		secretRotation
+	}
+
+	// EnvSecretProvider reads secrets from env vars, the name is upper cased with non-alphanumeric characters replaced by '_' and prefixed, e.g. 'cookie-hash-key' => 'SECRET_COOKIE_HASH_KEY'
+	EnvSecretProvider struct {
+		prefix string
+	}
+
+	// EncryptedFileSecretProvider reads secrets from a json file like {"name":"<EncryptSecret output>"} encrypted by AES-GCM,
+	// so the file can be shipped with images while the key comes from the environment. The file is checked periodically for rotation
+	EncryptedFileSecretProvider struct {
+		path    string
+		aead    cipher.AEAD
+		lock    sync.RWMutex
+		secrets map[string][]byte
+		modTime time.Time
+		secretRotation
+	}
+
+	// secretRotation runs check periodically after the first OnRotate and fires callbacks with names it returns
+	secretRotation struct {
+		interval  time.Duration
+		check     func() []string
+		lock      sync.Mutex
+		callbacks []func(name string)
+		hashes    map[string][32]byte
+		once      sync.Once
+		stop      chan struct{}
+	}
+)
+
+// NewFileSecretProvider creates the provider, dir defaults to /run/secrets, rotateSeconds defaults to 60
+func NewFileSecretProvider(dir string, rotateSeconds int) *FileSecretProvider {
+	if dir == "" {
+		dir = "/run/secrets"
+	}
+	r := &FileSecretProvider{dir: dir}
+	r.secretRotation.init(rotateSeconds, r.checkRotation)
+	return r
+}
+
+func (x *FileSecretProvider) GetSecretPath(name string) (string, error) {
+	path, err := x.getPath(name)
+	if err != nil {
+		return "", err
+	}
+	// Tracked so rotation is also checked for secrets used by path
+	if _, err := x.read(name, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (x *FileSecretProvider) GetSecret(name string) ([]byte, error) {
+	path, err := x.getPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return x.read(name, path)
+}
+
+func (x *FileSecretProvider) getPath(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") || fp.IsAbs(name) {
+		return "", xerr.Errorf("invalid secret name '%s'", name)
+	}
+	path := fp.Join(x.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", xerr.WithMessage(ErrSecretNotFound, name)
+		}
+		return "", xerr.WithStack(err)
+	}
+	return path, nil
+}
+
+func (x *FileSecretProvider) read(name, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	data = []byte(strings.TrimRight(string(data), "\r\n")) // Files written by editors or echo end with a newline
+	x.track(name, data)
+	return data, nil
+}
+
+func (x *FileSecretProvider) checkRotation() []string {
+	var r []string
+	for _, name := range x.names() {
+		path, err := x.getPath(name)
+		if err != nil {
+			continue // Removed secrets keep their last values
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if x.changed(name, []byte(strings.TrimRight(string(data), "\r\n"))) {
+			r = append(r, name)
+		}
+	}
+	return r
+}
+
+// NewEnvSecretProvider creates the provider, prefix defaults to 'SECRET_'
+func NewEnvSecretProvider(prefix string) *EnvSecretProvider {
+	if prefix == "" {
+		prefix = "SECRET_"
+	}
+	return &EnvSecretProvider{prefix: prefix}
+}
+
+func (x *EnvSecretProvider) GetSecret(name string) ([]byte, error) {
+	envName := x.prefix + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+	v, ok := os.LookupEnv(envName)
+	if !ok {
+		return nil, xerr.WithMessage(ErrSecretNotFound, name)
+	}
+	return []byte(v), nil
+}
+
+// NewEncryptedFileSecretProvider loads the file, key is 16, 24 or 32 bytes, rotateSeconds defaults to 60
+func NewEncryptedFileSecretProvider(path string, key []byte, rotateSeconds int) (*EncryptedFileSecretProvider, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	r := &EncryptedFileSecretProvider{
+		path: path,
+		aead: aead,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.secretRotation.init(rotateSeconds, r.checkRotation)
+	return r, nil
+}
+
+func (x *EncryptedFileSecretProvider) GetSecret(name string) ([]byte, error) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	v, ok := x.secrets[name]
+	if !ok {
+		return nil, xerr.WithMessage(ErrSecretNotFound, name)
+	}
+	x.track(name, v)
+	return v, nil
+}
+
+func (x *EncryptedFileSecretProvider) load() error {
+	info, err := os.Stat(x.path)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	data, err := os.ReadFile(x.path)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	var encrypted map[string]string
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return xerr.WithMessage(err, "invalid json of "+x.path)
+	}
+
+	secrets := make(map[string][]byte, len(encrypted))
+	for name, v := range encrypted {
+		secrets[name], err = decryptSecret(x.aead, v)
+		if err != nil {
+			return xerr.WithMessage(err, "failed to decrypt secret '"+name+"'")
+		}
+	}
+
+	x.lock.Lock()
+	x.secrets = secrets
+	x.modTime = info.ModTime()
+	x.lock.Unlock()
+	return nil
+}
+
+func (x *EncryptedFileSecretProvider) checkRotation() []string {
+	info, err := os.Stat(x.path)
+	if err != nil {
+		return nil
+	}
+	x.lock.RLock()
+	modified := !info.ModTime().Equal(x.modTime)
+	x.lock.RUnlock()
+	if !modified {
+		return nil
+	}
+	if err := x.load(); err != nil {
+		xlog.Errorf("failed to reload secrets, current secrets are kept: %+v", err)
+		return nil
+	}
+
+	var r []string
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	for _, name := range x.names() {
+		if v, ok := x.secrets[name]; ok && x.changed(name, v) {
+			r = append(r, name)
+		}
+	}
+	return r
+}
+
+// EncryptSecret encrypts a secret for EncryptedFileSecretProvider files
+func EncryptSecret(key, secret []byte) (string, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", xerr.WithStack(err)
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, secret, nil)), nil
+}
+
+func decryptSecret(aead cipher.AEAD, encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, xerr.New("encrypted secret is too short")
+	}
+	r, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	return r, xerr.WithStack(err)
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, xerr.WithStack(err)
+}
+
+// secretFileName makes a file name of a secret name
+func secretFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (x *secretRotation) init(rotateSeconds int, check func() []string) {
+	if rotateSeconds <= 0 {
+		rotateSeconds = 60
+	}
+	x.interval = time.Duration(rotateSeconds) * time.Second
+	x.check = check
+	x.hashes = make(map[string][32]byte)
+	x.stop = make(chan struct{})
+}
+
+// OnRotate registers a callback fired with the name of a secret whose value changed, only secrets read before are checked
+func (x *secretRotation) OnRotate(callback func(name string)) {
+	x.lock.Lock()
+	x.callbacks = append(x.callbacks, callback)
+	x.lock.Unlock()
+	x.once.Do(func() {
+		go x.watch()
+	})
+}
+
+// Close stops checking rotation
+func (x *secretRotation) Close() {
+	select {
+	case <-x.stop:
+	default:
+		close(x.stop)
+	}
+}
+
+func (x *secretRotation) watch() {
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.stop:
+			return
+		case <-ticker.C:
+		}
+
+		names := x.check()
+		x.lock.Lock()
+		callbacks := x.callbacks
+		x.lock.Unlock()
+		for _, name := range names {
+			for _, callback := range callbacks {
+				callback(name)
+			}
+		}
+	}
+}
+
+// track records the hash of a secret value read by a caller
+func (x *secretRotation) track(name string, value []byte) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.hashes[name] = sha256.Sum256(value)
+}
+
+// changed updates the hash of a secret and returns true if it differs
+func (x *secretRotation) changed(name string, value []byte) bool {
+	hash := sha256.Sum256(value)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.hashes[name] == hash {
+		return false
+	}
+	x.hashes[name] = hash
+	return true
+}
+
+func (x *secretRotation) names() []string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	r := make([]string, 0, len(x.hashes))
+	for name := range x.hashes {
+		r = append(r, name)
+	}
+	return r
+}
@@ -0,0 +1,176 @@
+package host
+
+import (
+	"encoding/json"
+	"os"
+	fp "path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xconfig"
+	"github.com/DreamvatLab/go/xerr"
+)
+
+func newTestConfigProvider(t *testing.T, raw string) *xconfig.JsonConfigProvider {
+	cp := &xconfig.JsonConfigProvider{RawJson: []byte(raw)}
+	if err := json.Unmarshal(cp.RawJson, &cp.MapConfiguration); err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
+
+func TestSecretConfigProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(fp.Join(dir, "client-secret"), []byte("s3cret\n"), 0600)
+	os.WriteFile(fp.Join(dir, "jwt.pem"), []byte("PEM"), 0600)
+	t.Setenv("SECRET_COOKIE_HASH_KEY", "hash")
+	t.Setenv("SECRET_SIGNING_KEY", "signing")
+
+	files := NewFileSecretProvider(dir, 0)
+	defer files.Close()
+	cp, err := NewSecretConfigProvider(newTestConfigProvider(t, `{
+		"Name":"api",
+		"OAuth":{"ClientSecret":"secret://client-secret"},
+		"HashKey":"secret://cookie-hash-key",
+		"PrivateKeyPath":"secret://jwt.pem",
+		"SigningKeyPath":"secret://signing-key",
+		"Hosts":[{"Token":"secret://client-secret"}]
+	}`), files, NewEnvSecretProvider(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	tests := map[string]string{
+		"Name":               "api",
+		"OAuth.ClientSecret": "s3cret",
+		"HashKey":            "hash",
+		"PrivateKeyPath":     fp.Join(dir, "jwt.pem"),
+	}
+	for key, want := range tests {
+		if v := cp.GetString(key); v != want {
+			t.Errorf("%s = %q, want %q", key, v, want)
+		}
+	}
+
+	var hosts []struct{ Token string }
+	if err := cp.GetStruct("Hosts", &hosts); err != nil || len(hosts) != 1 || hosts[0].Token != "s3cret" {
+		t.Errorf("secret in array is not resolved: %v %+v", err, hosts)
+	}
+
+	// Env secrets have no files, they are written to private temp files
+	path := cp.GetString("SigningKeyPath")
+	if data, err := os.ReadFile(path); err != nil || string(data) != "signing" {
+		t.Errorf("SigningKeyPath = %q: %v", path, err)
+	}
+	cp.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("temp secret file is not removed")
+	}
+
+	_, err = NewSecretConfigProvider(newTestConfigProvider(t, `{"Key":"secret://missing"}`), files)
+	if !xerr.Is(err, ErrSecretNotFound) {
+		t.Errorf("missing secret: %v", err)
+	}
+	if _, err := files.GetSecret("../client-secret"); err == nil || xerr.Is(err, ErrSecretNotFound) {
+		t.Errorf("names escaping the dir must be rejected: %v", err)
+	}
+}
+
+func TestEncryptedFileSecretProvider(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	path := fp.Join(t.TempDir(), "secrets.json")
+	write := func(secrets map[string]string) {
+		encrypted := make(map[string]string)
+		for name, v := range secrets {
+			var err error
+			if encrypted[name], err = EncryptSecret(key, []byte(v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, _ := json.Marshal(encrypted)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(map[string]string{"client-secret": "v1", "other": "o"})
+
+	if _, err := NewEncryptedFileSecretProvider(path, []byte("0123456789abcdef0123456789abcdeX"), 1); err == nil {
+		t.Error("wrong key must fail")
+	}
+
+	secrets, err := NewEncryptedFileSecretProvider(path, key, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secrets.Close()
+	cp, err := NewSecretConfigProvider(newTestConfigProvider(t, `{"OAuth":{"ClientSecret":"secret://client-secret"},"Name":"api"}`), secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if v := cp.GetString("OAuth.ClientSecret"); v != "v1" {
+		t.Fatalf("ClientSecret = %q", v)
+	}
+
+	changed := make(chan string, 4)
+	cp.OnChange("OAuth", func(cp xconfig.IConfigProvider) {
+		changed <- cp.GetString("OAuth.ClientSecret")
+	})
+	cp.OnChange("Name", func(xconfig.IConfigProvider) {
+		changed <- "Name"
+	})
+
+	time.Sleep(10 * time.Millisecond) // Keeps mod time different on coarse file systems
+	write(map[string]string{"client-secret": "v2", "other": "o"})
+	os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	select {
+	case v := <-changed:
+		if v != "v2" {
+			t.Errorf("rotated ClientSecret = %q", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation is not notified")
+	}
+	select {
+	case v := <-changed:
+		t.Errorf("unexpected notification %q", v)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSecretsRedactedAndRequired(t *testing.T) {
+	t.Setenv("SECRET_DB", "postgres://u:p@db")
+	t.Setenv("SECRET_WEBHOOK", "https://hooks/x")
+	cp, err := NewSecretConfigProvider(newTestConfigProvider(t, `{
+		"Name":"api",
+		"ConnectionStrings":{"Main":"secret://db"},
+		"Webhooks":["secret://webhook","https://public"]
+	}`), NewEnvSecretProvider(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	admin, err := NewAdmin(&AdminOptions{AllowedIPs: []string{"127.0.0.1"}}, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.redactKeys = []string{"nothing"} // Only secret references are redacted
+	var config map[string]interface{}
+	if err := cp.GetStruct("@this", &config); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(admin.Redact(config))
+	expected := `{"ConnectionStrings":{"Main":"******"},"Name":"api","Webhooks":["******","https://public"]}`
+	if string(data) != expected {
+		t.Errorf("Redact = %s, expected %s", data, expected)
+	}
+
+	host := &BaseHost{ConfigProvider: newTestConfigProvider(t, `{"Log":{"Level":"warn"},"OAuth":{"ClientSecret":"secret://client-secret"}}`)}
+	err = host.BuildBaseHostE()
+	var errs ConfigErrors
+	if !xerr.As(err, &errs) || len(errs) != 1 || errs[0].Key != "OAuth.ClientSecret" {
+		t.Fatalf("BuildBaseHostE() = %v, expected unresolved OAuth.ClientSecret", err)
+	}
+}
@@ -0,0 +1,516 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xsecurity"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// FileRouteProvider keeps routes in a json or yaml file (by extension) keyed by route ID like the redis hash of RouteKey,
+	// e.g. {"GET/api/users": {"Permission_ID": "users"}}, IDs default to their keys
+	FileRouteProvider struct {
+		securityFile
+	}
+
+	// FilePermissionProvider keeps permissions in a json or yaml file (by extension) keyed by permission ID like the redis hash of PermissionKey,
+	// e.g. {"users": {"Name": "Users", "AllowedRoles": 3}}, IDs default to their keys
+	FilePermissionProvider struct {
+		securityFile
+	}
+
+	// securityFile loads, saves and watches a file of json objects keyed by ID, a missing file is empty until the first write
+	securityFile struct {
+		path      string
+		yaml      bool
+		lock      sync.RWMutex
+		items     map[string]json.RawMessage
+		modTime   time.Time
+		interval  time.Duration
+		callbacks []func()
+		once      sync.Once
+		stop      chan struct{}
+	}
+)
+
+var (
+	_ xsecurity.IRouteProvider      = (*FileRouteProvider)(nil)
+	_ xsecurity.IPermissionProvider = (*FilePermissionProvider)(nil)
+)
+
+// NewFileRouteProvider loads routes of path, the file is checked for changes every watchSeconds once OnFileChange is called, default 2
+func NewFileRouteProvider(path string, watchSeconds int) (*FileRouteProvider, error) {
+	r := new(FileRouteProvider)
+	if err := r.init(path, watchSeconds); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (x *FileRouteProvider) CreateRoute(in *xdto.Route) error {
+	return x.UpdateRoute(in)
+}
+func (x *FileRouteProvider) GetRoute(id string) (*xdto.Route, error) {
+	r := new(xdto.Route)
+	if err := x.get(id, r); err != nil {
+		return nil, err
+	}
+	if r.ID == "" {
+		r.ID = id
+	}
+	return r, nil
+}
+func (x *FileRouteProvider) UpdateRoute(in *xdto.Route) error {
+	if in == nil || in.ID == "" {
+		return xerr.New("route id cannot be empty")
+	}
+	return x.set(in.ID, in)
+}
+func (x *FileRouteProvider) RemoveRoute(id string) error {
+	return x.remove(id)
+}
+func (x *FileRouteProvider) GetRoutes() (map[string]*xdto.Route, error) {
+	items := x.all()
+	r := make(map[string]*xdto.Route, len(items))
+	for id, raw := range items {
+		route := new(xdto.Route)
+		if err := json.Unmarshal(raw, route); err != nil {
+			return nil, xerr.WithMessage(err, "invalid route '"+id+"'")
+		}
+		if route.ID == "" {
+			route.ID = id
+		}
+		r[id] = route
+	}
+	return r, nil
+}
+
+// SetRoutes replaces all routes with one write
+func (x *FileRouteProvider) SetRoutes(routes map[string]*xdto.Route) error {
+	items := make(map[string]interface{}, len(routes))
+	for id, route := range routes {
+		items[id] = route
+	}
+	return x.replace(items)
+}
+
+// NewFilePermissionProvider loads permissions of path, the file is checked for changes every watchSeconds once OnFileChange is called, default 2
+func NewFilePermissionProvider(path string, watchSeconds int) (*FilePermissionProvider, error) {
+	r := new(FilePermissionProvider)
+	if err := r.init(path, watchSeconds); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (x *FilePermissionProvider) CreatePermission(in *xdto.Permission) error {
+	return x.UpdatePermission(in)
+}
+func (x *FilePermissionProvider) GetPermission(id string) (*xdto.Permission, error) {
+	r := new(xdto.Permission)
+	if err := x.get(id, r); err != nil {
+		return nil, err
+	}
+	if r.ID == "" {
+		r.ID = id
+	}
+	return r, nil
+}
+func (x *FilePermissionProvider) UpdatePermission(in *xdto.Permission) error {
+	if in == nil || in.ID == "" {
+		return xerr.New("permission id cannot be empty")
+	}
+	return x.set(in.ID, in)
+}
+func (x *FilePermissionProvider) RemovePermission(id string) error {
+	return x.remove(id)
+}
+func (x *FilePermissionProvider) GetPermissions() (map[string]*xdto.Permission, error) {
+	items := x.all()
+	r := make(map[string]*xdto.Permission, len(items))
+	for id, raw := range items {
+		permission := new(xdto.Permission)
+		if err := json.Unmarshal(raw, permission); err != nil {
+			return nil, xerr.WithMessage(err, "invalid permission '"+id+"'")
+		}
+		if permission.ID == "" {
+			permission.ID = id
+		}
+		r[id] = permission
+	}
+	return r, nil
+}
+
+// SetPermissions replaces all permissions with one write
+func (x *FilePermissionProvider) SetPermissions(permissions map[string]*xdto.Permission) error {
+	items := make(map[string]interface{}, len(permissions))
+	for id, permission := range permissions {
+		items[id] = permission
+	}
+	return x.replace(items)
+}
+
+// CopyRoutes writes routes of src to dst, routes only in dst are removed if prune is true
+func CopyRoutes(dst, src xsecurity.IRouteProvider, prune bool) error {
+	routes, err := src.GetRoutes()
+	if err != nil {
+		return err
+	}
+
+	// File providers write all items at once
+	if setter, ok := dst.(interface {
+		SetRoutes(map[string]*xdto.Route) error
+	}); ok {
+		merged := make(map[string]*xdto.Route, len(routes))
+		if !prune {
+			existing, err := dst.GetRoutes()
+			if err != nil {
+				return err
+			}
+			for id, route := range existing {
+				merged[id] = route
+			}
+		}
+		for id, route := range routes {
+			merged[id] = route
+		}
+		return setter.SetRoutes(merged)
+	}
+
+	for _, route := range routes {
+		if err := dst.UpdateRoute(route); err != nil {
+			return err
+		}
+	}
+	if !prune {
+		return nil
+	}
+	existing, err := dst.GetRoutes()
+	if err != nil {
+		return err
+	}
+	for id := range existing {
+		if _, ok := routes[id]; !ok {
+			if err := dst.RemoveRoute(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CopyPermissions writes permissions of src to dst, permissions only in dst are removed if prune is true
+func CopyPermissions(dst, src xsecurity.IPermissionProvider, prune bool) error {
+	permissions, err := src.GetPermissions()
+	if err != nil {
+		return err
+	}
+
+	// File providers write all items at once
+	if setter, ok := dst.(interface {
+		SetPermissions(map[string]*xdto.Permission) error
+	}); ok {
+		merged := make(map[string]*xdto.Permission, len(permissions))
+		if !prune {
+			existing, err := dst.GetPermissions()
+			if err != nil {
+				return err
+			}
+			for id, permission := range existing {
+				merged[id] = permission
+			}
+		}
+		for id, permission := range permissions {
+			merged[id] = permission
+		}
+		return setter.SetPermissions(merged)
+	}
+
+	for _, permission := range permissions {
+		if err := dst.UpdatePermission(permission); err != nil {
+			return err
+		}
+	}
+	if !prune {
+		return nil
+	}
+	existing, err := dst.GetPermissions()
+	if err != nil {
+		return err
+	}
+	for id := range existing {
+		if _, ok := permissions[id]; !ok {
+			if err := dst.RemovePermission(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *securityFile) init(path string, watchSeconds int) error {
+	if path == "" {
+		return xerr.New("file path cannot be empty")
+	}
+	if watchSeconds <= 0 {
+		watchSeconds = 2
+	}
+	x.path = path
+	x.yaml = isYamlFile(path)
+	x.interval = time.Duration(watchSeconds) * time.Second
+	x.stop = make(chan struct{})
+	_, err := x.load()
+	return err
+}
+
+// OnFileChange registers a callback fired after the file is changed by others and reloaded, invalid files are logged and ignored
+func (x *securityFile) OnFileChange(callback func()) {
+	x.lock.Lock()
+	x.callbacks = append(x.callbacks, callback)
+	x.lock.Unlock()
+	x.once.Do(func() {
+		go x.watch()
+	})
+}
+
+// Close stops watching the file
+func (x *securityFile) Close() {
+	select {
+	case <-x.stop:
+	default:
+		close(x.stop)
+	}
+}
+
+func (x *securityFile) watch() {
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.stop:
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := x.load()
+		if err != nil {
+			xlog.Errorf("failed to reload '%s', current data is kept: %+v", x.path, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		xlog.Infof("'%s' reloaded", x.path)
+		x.lock.RLock()
+		callbacks := x.callbacks
+		x.lock.RUnlock()
+		for _, callback := range callbacks {
+			callback()
+		}
+	}
+}
+
+// load reads the file if its mod time changed
+func (x *securityFile) load() (bool, error) {
+	info, err := os.Stat(x.path)
+	if os.IsNotExist(err) {
+		// Editors may remove the file while saving, so data loaded before is kept
+		x.lock.Lock()
+		defer x.lock.Unlock()
+		if x.items == nil {
+			x.items = make(map[string]json.RawMessage)
+		}
+		return false, nil
+	} else if err != nil {
+		return false, xerr.WithStack(err)
+	}
+
+	x.lock.RLock()
+	modified := !info.ModTime().Equal(x.modTime)
+	x.lock.RUnlock()
+	if !modified {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(x.path)
+	if err != nil {
+		return false, xerr.WithStack(err)
+	}
+	items, err := x.decode(data)
+	if err != nil {
+		return false, xerr.WithMessage(err, "invalid file '"+x.path+"'")
+	}
+
+	x.lock.Lock()
+	x.items = items
+	x.modTime = info.ModTime()
+	x.lock.Unlock()
+	return true, nil
+}
+
+func (x *securityFile) decode(data []byte) (map[string]json.RawMessage, error) {
+	r := make(map[string]json.RawMessage)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return r, nil
+	}
+	if !x.yaml {
+		return r, xerr.WithStack(json.Unmarshal(data, &r))
+	}
+
+	var items map[string]interface{}
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	for id, item := range items {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, xerr.WithMessage(err, "invalid item '"+id+"'")
+		}
+		r[id] = raw
+	}
+	return r, nil
+}
+
+func (x *securityFile) encode(items map[string]json.RawMessage) ([]byte, error) {
+	if !x.yaml {
+		data, err := json.MarshalIndent(items, "", "  ")
+		return data, xerr.WithStack(err)
+	}
+
+	tree := make(map[string]interface{}, len(items))
+	for id, raw := range items {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber() // Keeps int64 role flags exact
+		var item interface{}
+		if err := decoder.Decode(&item); err != nil {
+			return nil, xerr.WithStack(err)
+		}
+		tree[id] = yamlNumbers(item)
+	}
+	data, err := yaml.Marshal(tree)
+	return data, xerr.WithStack(err)
+}
+
+func (x *securityFile) get(id string, target interface{}) error {
+	x.lock.RLock()
+	raw, ok := x.items[id]
+	x.lock.RUnlock()
+	if !ok {
+		return xerr.Errorf("'%s' not found in '%s'", id, x.path)
+	}
+	return xerr.WithStack(json.Unmarshal(raw, target))
+}
+
+func (x *securityFile) all() map[string]json.RawMessage {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	r := make(map[string]json.RawMessage, len(x.items))
+	for id, raw := range x.items {
+		r[id] = raw
+	}
+	return r
+}
+
+func (x *securityFile) set(id string, item interface{}) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	return x.update(func(items map[string]json.RawMessage) {
+		items[id] = raw
+	})
+}
+
+func (x *securityFile) remove(id string) error {
+	return x.update(func(items map[string]json.RawMessage) {
+		delete(items, id)
+	})
+}
+
+func (x *securityFile) replace(in map[string]interface{}) error {
+	raws := make(map[string]json.RawMessage, len(in))
+	for id, item := range in {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return xerr.WithStack(err)
+		}
+		raws[id] = raw
+	}
+	return x.update(func(items map[string]json.RawMessage) {
+		for id := range items {
+			delete(items, id)
+		}
+		for id, raw := range raws {
+			items[id] = raw
+		}
+	})
+}
+
+// update changes a copy of items and writes the file, items are swapped in after the file is written
+func (x *securityFile) update(change func(items map[string]json.RawMessage)) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	items := make(map[string]json.RawMessage, len(x.items)+1)
+	for id, raw := range x.items {
+		items[id] = raw
+	}
+	change(items)
+
+	data, err := x.encode(items)
+	if err != nil {
+		return err
+	}
+	// Written to a temp file then renamed, so watchers never read a partial file
+	temp := x.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return xerr.WithStack(err)
+	}
+	if err := os.Rename(temp, x.path); err != nil {
+		os.Remove(temp)
+		return xerr.WithStack(err)
+	}
+
+	x.items = items
+	if info, err := os.Stat(x.path); err == nil {
+		x.modTime = info.ModTime() // Own writes don't fire callbacks
+	}
+	return nil
+}
+
+func isYamlFile(path string) bool {
+	ext := strings.ToLower(fp.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// yamlNumbers converts json.Number to int64 or float64, yaml writes json.Number as a quoted string
+func yamlNumbers(v interface{}) interface{} {
+	switch a := v.(type) {
+	case map[string]interface{}:
+		for k, item := range a {
+			a[k] = yamlNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range a {
+			a[i] = yamlNumbers(item)
+		}
+	case json.Number:
+		if i, err := a.Int64(); err == nil {
+			return i
+		}
+		f, _ := a.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,98 @@
+package host
+
+import (
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DreamvatLab/go/xdto"
+)
+
+func TestFileSecurityProviders(t *testing.T) {
+	dir := t.TempDir()
+	routeFile := fp.Join(dir, "routes.yaml")
+	permissionFile := fp.Join(dir, "permissions.json")
+	os.WriteFile(routeFile, []byte("GET/api/users:\n  Permission_ID: users\n"), 0644)
+	os.WriteFile(permissionFile, []byte(`{"users":{"Name":"Users","AllowedRoles":2}}`), 0644)
+
+	host := &BaseHost{
+		ConfigProvider: newTestConfigProvider(t, `{"Log":{"Level":"warn"}}`),
+		RouteFile:      routeFile,
+		PermissionFile: permissionFile,
+	}
+	if err := host.BuildBaseHostE(); err != nil {
+		t.Fatal(err)
+	}
+	defer host.RunStoppedHooks(t.Context())
+
+	route, err := host.RouteProvider.GetRoute("GET/api/users")
+	if err != nil || route.ID != "GET/api/users" || route.Permission_ID != "users" {
+		t.Fatalf("route = %+v: %v", route, err)
+	}
+	if !host.PermissionAuditor.CheckRouteKeyWithLevel("GET/api/users", 2, 0, nil) || host.PermissionAuditor.CheckRouteKeyWithLevel("GET/api/users", 1, 0, nil) {
+		t.Error("file data is not used by the auditor")
+	}
+
+	// Changes by others are reloaded into the auditor
+	time.Sleep(10 * time.Millisecond)
+	os.WriteFile(permissionFile, []byte(`{"users":{"Name":"Users","AllowedRoles":1}}`), 0644)
+	os.Chtimes(permissionFile, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for !host.PermissionAuditor.CheckRouteKeyWithLevel("GET/api/users", 1, 0, nil) {
+		if time.Now().After(deadline) {
+			t.Fatal("changed file is not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCopySecurityData(t *testing.T) {
+	dir := t.TempDir()
+	src, err := NewFilePermissionProvider(fp.Join(dir, "src.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.CreatePermission(&xdto.Permission{ID: "users", Name: "Users", AllowedRoles: 1 << 62, Scopes: []string{"api"}})
+	src.CreatePermission(&xdto.Permission{ID: "orders", IsAllowAnyUser: true})
+
+	dstFile := fp.Join(dir, "dst.yaml")
+	os.WriteFile(dstFile, []byte("old:\n  Name: Old\n"), 0644)
+	dst, err := NewFilePermissionProvider(dstFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyPermissions(dst, src, false); err != nil {
+		t.Fatal(err)
+	}
+	if permissions, _ := dst.GetPermissions(); len(permissions) != 3 {
+		t.Errorf("copy without prune: %d permissions", len(permissions))
+	}
+	if err := CopyPermissions(dst, src, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written yaml is loaded back exactly
+	data, _ := os.ReadFile(dstFile)
+	if strings.Contains(string(data), "old:") || !strings.Contains(string(data), "AllowedRoles: 4611686018427387904") {
+		t.Errorf("unexpected yaml:\n%s", data)
+	}
+	reloaded, err := NewFilePermissionProvider(dstFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	permissions, _ := reloaded.GetPermissions()
+	if len(permissions) != 2 || permissions["users"].AllowedRoles != 1<<62 || permissions["users"].Scopes[0] != "api" || !permissions["orders"].IsAllowAnyUser {
+		t.Errorf("permissions = %v", permissions)
+	}
+
+	routes, err := NewFileRouteProvider(fp.Join(dir, "missing.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, err := routes.GetRoutes(); err != nil || len(items) != 0 {
+		t.Errorf("missing file must be empty: %v %v", items, err)
+	}
+}
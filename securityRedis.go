@@ -0,0 +1,162 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/go/xlog"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/DreamvatLab/go/xsecurity"
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// RedisRouteProvider keeps routes in the redis hash of RouteKey like xsecurity's provider, and closes its client on Close
+	RedisRouteProvider struct {
+		redisSecurityHash
+	}
+
+	// RedisPermissionProvider keeps permissions in the redis hash of PermissionKey like xsecurity's provider, and closes its client on Close
+	RedisPermissionProvider struct {
+		redisSecurityHash
+	}
+
+	// redisSecurityHash is a redis hash of json objects keyed by ID
+	redisSecurityHash struct {
+		client redis.UniversalClient
+		key    string
+	}
+)
+
+var (
+	_ xsecurity.IRouteProvider      = (*RedisRouteProvider)(nil)
+	_ xsecurity.IPermissionProvider = (*RedisPermissionProvider)(nil)
+)
+
+// NewRedisRouteProvider creates provider of the redis hash routeKey
+func NewRedisRouteProvider(routeKey string, redisConfig *xredis.RedisConfig) (*RedisRouteProvider, error) {
+	r := new(RedisRouteProvider)
+	if err := r.init(routeKey, redisConfig); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (x *RedisRouteProvider) CreateRoute(in *xdto.Route) error {
+	return x.set(in.ID, in)
+}
+func (x *RedisRouteProvider) GetRoute(id string) (*xdto.Route, error) {
+	r := new(xdto.Route)
+	if err := x.get(id, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+func (x *RedisRouteProvider) UpdateRoute(in *xdto.Route) error {
+	return x.set(in.ID, in)
+}
+func (x *RedisRouteProvider) RemoveRoute(id string) error {
+	return x.remove(id)
+}
+func (x *RedisRouteProvider) GetRoutes() (map[string]*xdto.Route, error) {
+	items, err := x.all()
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]*xdto.Route, len(items))
+	for id, data := range items {
+		route := new(xdto.Route)
+		if err := json.Unmarshal([]byte(data), route); err != nil {
+			xlog.Errorf("invalid route '%s' in '%s': %v", id, x.key, err)
+			continue
+		}
+		r[id] = route
+	}
+	return r, nil
+}
+
+// NewRedisPermissionProvider creates provider of the redis hash permissionKey
+func NewRedisPermissionProvider(permissionKey string, redisConfig *xredis.RedisConfig) (*RedisPermissionProvider, error) {
+	r := new(RedisPermissionProvider)
+	if err := r.init(permissionKey, redisConfig); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (x *RedisPermissionProvider) CreatePermission(in *xdto.Permission) error {
+	return x.set(in.ID, in)
+}
+func (x *RedisPermissionProvider) GetPermission(id string) (*xdto.Permission, error) {
+	r := new(xdto.Permission)
+	if err := x.get(id, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+func (x *RedisPermissionProvider) UpdatePermission(in *xdto.Permission) error {
+	return x.set(in.ID, in)
+}
+func (x *RedisPermissionProvider) RemovePermission(id string) error {
+	return x.remove(id)
+}
+func (x *RedisPermissionProvider) GetPermissions() (map[string]*xdto.Permission, error) {
+	items, err := x.all()
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]*xdto.Permission, len(items))
+	for id, data := range items {
+		permission := new(xdto.Permission)
+		if err := json.Unmarshal([]byte(data), permission); err != nil {
+			xlog.Errorf("invalid permission '%s' in '%s': %v", id, x.key, err)
+			continue
+		}
+		r[id] = permission
+	}
+	return r, nil
+}
+
+func (x *redisSecurityHash) init(key string, redisConfig *xredis.RedisConfig) error {
+	if key == "" {
+		return xerr.New("redis key cannot be empty")
+	}
+	if redisConfig == nil {
+		return xerr.New("redis config cannot be nil")
+	}
+	x.key = key
+	x.client = xredis.NewClient(redisConfig)
+	return nil
+}
+
+// Close closes the redis client
+func (x *redisSecurityHash) Close() error {
+	return xerr.WithStack(x.client.Close())
+}
+
+func (x *redisSecurityHash) get(id string, target interface{}) error {
+	data, err := x.client.HGet(context.Background(), x.key, id).Bytes()
+	if err != nil {
+		return err // redis.Nil if not found, as xsecurity's providers return
+	}
+	return xerr.WithStack(json.Unmarshal(data, target))
+}
+
+func (x *redisSecurityHash) all() (map[string]string, error) {
+	r, err := x.client.HGetAll(context.Background(), x.key).Result()
+	return r, xerr.WithStack(err)
+}
+
+func (x *redisSecurityHash) set(id string, item interface{}) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	return xerr.WithStack(x.client.HSet(context.Background(), x.key, id, data).Err())
+}
+
+func (x *redisSecurityHash) remove(id string) error {
+	return xerr.WithStack(x.client.HDel(context.Background(), x.key, id).Err())
+}
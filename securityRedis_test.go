@@ -0,0 +1,56 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/DreamvatLab/go/xdto"
+	"github.com/DreamvatLab/go/xredis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisSecurityProviders(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisConfig := &xredis.RedisConfig{Addrs: []string{server.Addr()}}
+	routes, err := NewRedisRouteProvider("routes", redisConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	permissions, err := NewRedisPermissionProvider("permissions", redisConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := routes.CreateRoute(&xdto.Route{ID: "GET/api/users", Permission_ID: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := permissions.CreatePermission(&xdto.Permission{ID: "users", AllowedRoles: 2}); err != nil {
+		t.Fatal(err)
+	}
+	server.HSet("permissions", "broken", "{")
+
+	if route, err := routes.GetRoute("GET/api/users"); err != nil || route.Permission_ID != "users" {
+		t.Fatalf("GetRoute() = %+v, %v", route, err)
+	}
+	if _, err := routes.GetRoute("missing"); err == nil {
+		t.Fatal("missing route is found")
+	}
+	all, err := permissions.GetPermissions()
+	if err != nil || len(all) != 1 || all["users"].AllowedRoles != 2 {
+		t.Fatalf("GetPermissions() = %v, %v, invalid items are expected to be skipped", all, err)
+	}
+	if err := permissions.RemovePermission("users"); err != nil || server.HGet("permissions", "users") != "" {
+		t.Fatalf("RemovePermission() = %v", err)
+	}
+
+	if err := routes.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := routes.GetRoutes(); err == nil {
+		t.Fatal("client is not closed")
+	}
+	permissions.Close()
+
+	if _, err := NewRedisRouteProvider("", redisConfig); err == nil {
+		t.Fatal("empty key is accepted")
+	}
+}
@@ -0,0 +1,302 @@
+package host
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/DreamvatLab/go/xerr"
+	"github.com/DreamvatLab/host/hmodel"
+	"github.com/DreamvatLab/host/hurl"
+)
+
+const _viewContentName = "content"
+
+type (
+	// ViewOptions configures html template rendering. Templates are named by their path without extension,
+	// e.g. 'home/index'. Layouts render the view by {{template "content" .}} and may declare {{block}}s for views to fill,
+	// layouts and partials are visible to every view
+	ViewOptions struct {
+		Dir                string // Template root on disk, or in the fs given to the view engine
+		Extension          string // Default '.html'
+		Layout             string // Default layout, e.g. 'layouts/main', empty means views render without layout
+		LayoutsDir         string // Default 'layouts'
+		PartialsDir        string // Default 'partials'
+		UserJsonSessionKey string // Session key of current user, default 'USERJSON' which is used by OAuthClientHost
+		Reload             bool   // Parse templates on every render, turned on by BaseHost.Debug
+	}
+
+	// ViewEngine renders html/template views with layouts and partials
+	ViewEngine struct {
+		options     *ViewOptions
+		fsys        fs.FS
+		urlProvider hurl.IURLProvider
+		funcs       template.FuncMap
+		lock        sync.RWMutex
+		views       map[string]*template.Template
+	}
+
+	// ViewContext is the data of a template ('.'), pass it to partials by {{template "partials/nav" .}}
+	ViewContext struct {
+		Model  interface{}
+		ctx    IHttpContext
+		engine *ViewEngine
+	}
+)
+
+// NewViewEngine creates view engine and loads templates, fsys (e.g. embed.FS) can be nil to load from disk, urlProvider is optional
+func NewViewEngine(options *ViewOptions, fsys fs.FS, urlProvider hurl.IURLProvider) (*ViewEngine, error) {
+	if options == nil {
+		return nil, xerr.New("view options cannot be nil")
+	}
+	if options.Extension == "" {
+		options.Extension = ".html"
+	}
+	if options.LayoutsDir == "" {
+		options.LayoutsDir = "layouts"
+	}
+	if options.PartialsDir == "" {
+		options.PartialsDir = "partials"
+	}
+	if options.UserJsonSessionKey == "" {
+		options.UserJsonSessionKey = "USERJSON"
+	}
+
+	if fsys == nil {
+		if options.Dir == "" {
+			return nil, xerr.New("view 'Dir' cannot be empty")
+		}
+		fsys = os.DirFS(options.Dir)
+	} else if options.Dir != "" && options.Dir != "." {
+		var err error
+		fsys, err = fs.Sub(fsys, options.Dir)
+		if err != nil {
+			return nil, xerr.WithStack(err)
+		}
+	}
+
+	r := &ViewEngine{
+		options:     options,
+		fsys:        fsys,
+		urlProvider: urlProvider,
+	}
+	r.funcs = template.FuncMap{
+		"url":       r.getURL,
+		"renderURL": r.renderURL,
+	}
+
+	if err := r.Load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// AddFuncs adds template functions and reloads templates, it's safe to call while rendering
+func (x *ViewEngine) AddFuncs(funcs template.FuncMap) error {
+	x.lock.Lock()
+	merged := make(template.FuncMap, len(x.funcs)+len(funcs)) // Copied, so parsing templates reads the map without lock
+	for k, v := range x.funcs {
+		merged[k] = v
+	}
+	for k, v := range funcs {
+		merged[k] = v
+	}
+	x.funcs = merged
+	x.lock.Unlock()
+	return x.Load()
+}
+
+func (x *ViewEngine) getFuncs() template.FuncMap {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.funcs
+}
+
+// Load parses all templates
+func (x *ViewEngine) Load() error {
+	base, err := x.parseShared()
+	if err != nil {
+		return err
+	}
+
+	views := make(map[string]*template.Template)
+	err = fs.WalkDir(x.fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(filePath, x.options.Extension) || x.isShared(filePath) {
+			return nil
+		}
+
+		name := strings.TrimSuffix(filePath, x.options.Extension)
+		views[name], err = x.parseView(base, name)
+		return err
+	})
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+
+	x.lock.Lock()
+	x.views = views
+	x.lock.Unlock()
+	return nil
+}
+
+// Render renders view by name with default layout, data is available as '.Model'
+func (x *ViewEngine) Render(w io.Writer, ctx IHttpContext, name string, data interface{}) error {
+	return x.RenderLayout(w, ctx, x.options.Layout, name, data)
+}
+
+// RenderLayout renders view by name with layout, empty layout renders the view alone
+func (x *ViewEngine) RenderLayout(w io.Writer, ctx IHttpContext, layout, name string, data interface{}) error {
+	t, err := x.getView(name)
+	if err != nil {
+		return err
+	}
+
+	entry := name
+	if layout != "" {
+		entry = layout
+	}
+
+	viewCtx := &ViewContext{
+		Model:  data,
+		ctx:    ctx,
+		engine: x,
+	}
+	return xerr.WithStack(t.ExecuteTemplate(w, entry, viewCtx))
+}
+
+func (x *ViewEngine) getView(name string) (*template.Template, error) {
+	if x.options.Reload {
+		base, err := x.parseShared()
+		if err != nil {
+			return nil, err
+		}
+		return x.parseView(base, name)
+	}
+
+	x.lock.RLock()
+	t, ok := x.views[name]
+	x.lock.RUnlock()
+	if !ok {
+		return nil, xerr.Errorf("view '%s' not found", name)
+	}
+	return t, nil
+}
+
+// parseShared parses layouts and partials, views get their own clones so their blocks don't conflict
+func (x *ViewEngine) parseShared() (*template.Template, error) {
+	base := template.New("").Funcs(x.getFuncs())
+	for _, dir := range []string{x.options.LayoutsDir, x.options.PartialsDir} {
+		err := fs.WalkDir(x.fsys, dir, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(filePath, x.options.Extension) {
+				return nil
+			}
+			return x.parseFile(base, filePath, strings.TrimSuffix(filePath, x.options.Extension))
+		})
+		if err != nil && !xerr.Is(err, fs.ErrNotExist) {
+			return nil, xerr.WithStack(err)
+		}
+	}
+	return base, nil
+}
+
+func (x *ViewEngine) parseView(base *template.Template, name string) (*template.Template, error) {
+	t, err := base.Clone()
+	if err != nil {
+		return nil, xerr.WithStack(err)
+	}
+
+	if err = x.parseFile(t, name+x.options.Extension, name); err != nil {
+		return nil, err
+	}
+	// Layouts refer to the view by a fixed name
+	if _, err = t.AddParseTree(_viewContentName, t.Lookup(name).Tree); err != nil {
+		return nil, xerr.WithStack(err)
+	}
+	return t, nil
+}
+
+func (x *ViewEngine) parseFile(t *template.Template, filePath, name string) error {
+	data, err := fs.ReadFile(x.fsys, filePath)
+	if err != nil {
+		return xerr.WithStack(err)
+	}
+	_, err = t.New(name).Parse(string(data))
+	return xerr.WithStack(err)
+}
+
+func (x *ViewEngine) isShared(filePath string) bool {
+	dir := path.Dir(filePath) + "/"
+	return strings.HasPrefix(dir, x.options.LayoutsDir+"/") || strings.HasPrefix(dir, x.options.PartialsDir+"/")
+}
+
+func (x *ViewEngine) getURL(urlKey string) string {
+	if x.urlProvider == nil {
+		return ""
+	}
+	return x.urlProvider.GetURLCache(urlKey)
+}
+
+func (x *ViewEngine) renderURL(url string) string {
+	if x.urlProvider == nil {
+		return url
+	}
+	return x.urlProvider.RenderURLCache(url)
+}
+
+// RenderView renders view into a buffer first, so nothing is written if rendering fails
+func RenderView(ctx IHttpContext, engine *ViewEngine, name string, data interface{}) error {
+	if engine == nil {
+		return xerr.New("view engine is not configured")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := engine.Render(buf, ctx, name, data); err != nil {
+		return err
+	}
+
+	ctx.SetContentType("text/html; charset=utf-8")
+	_, err := ctx.WriteBytes(buf.Bytes())
+	return err
+}
+
+// CSRFToken returns the csrf token of current session
+func (x *ViewContext) CSRFToken() string {
+	return GetCSRFToken(x.ctx)
+}
+
+// CSRFField returns a hidden input carrying the csrf token
+func (x *ViewContext) CSRFField() template.HTML {
+	return template.HTML(`<input type="hidden" name="` + Form_CSRFToken + `" value="` + template.HTMLEscapeString(x.CSRFToken()) + `">`)
+}
+
+// User returns current user, nil if not signed in
+func (x *ViewContext) User() *hmodel.User {
+	return GetUser(x.ctx, x.engine.options.UserJsonSessionKey)
+}
+
+// T translates key by the locale of the request
+func (x *ViewContext) T(key string, args ...interface{}) string {
+	return Translate(x.ctx, key, args...)
+}
+
+// URL returns url by key from url provider
+func (x *ViewContext) URL(urlKey string) string {
+	return x.engine.getURL(urlKey)
+}
+
+// Ctx returns current http context
+func (x *ViewContext) Ctx() IHttpContext {
+	return x.ctx
+}
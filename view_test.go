@@ -0,0 +1,80 @@
+package host
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestViewEngine(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/layouts/main.html": {Data: []byte(`<title>{{block "title" .}}Default{{end}}</title>{{template "partials/nav" .}}<main>{{template "content" .}}</main>`)},
+		"views/partials/nav.html": {Data: []byte(`<nav>{{.Model.User}}</nav>`)},
+		"views/home/index.html":   {Data: []byte(`{{define "title"}}Home{{end}}<p>{{.Model.Text}}</p>`)},
+		"views/home/about.html":   {Data: []byte(`<p>about</p>`)},
+		"views/layouts/bare.html": {Data: []byte(`{{template "content" .}}`)},
+		"views/home/readme.txt":   {Data: []byte(`ignored`)},
+	}
+
+	for _, reload := range []bool{false, true} {
+		engine, err := NewViewEngine(&ViewOptions{Dir: "views", Layout: "layouts/main", Reload: reload}, fsys, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := new(bytes.Buffer)
+		model := map[string]string{"User": "tom", "Text": "<b>"}
+		if err = engine.Render(buf, nil, "home/index", model); err != nil {
+			t.Fatal(err)
+		}
+		if expected := `<title>Home</title><nav>tom</nav><main><p>&lt;b&gt;</p></main>`; buf.String() != expected {
+			t.Fatalf("expected %s, got %s", expected, buf.String())
+		}
+
+		// Blocks of one view don't leak into another
+		buf.Reset()
+		if err = engine.Render(buf, nil, "home/about", model); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), "<title>Default</title>") {
+			t.Fatalf("unexpected output %s", buf.String())
+		}
+
+		if err = engine.Render(buf, nil, "home/missing", nil); err == nil {
+			t.Fatal("expected error for missing view")
+		}
+	}
+}
+
+func TestViewEngineAddFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home/index.html": {Data: []byte(`{{.Model}}`)},
+	}
+	engine, err := NewViewEngine(&ViewOptions{Dir: ".", Reload: true}, fsys, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renders of reload mode parse templates while funcs are added
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			engine.Render(new(bytes.Buffer), nil, "home/index", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := engine.AddFuncs(template.FuncMap{"upper": strings.ToUpper}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	fsys["home/upper.html"] = &fstest.MapFile{Data: []byte(`{{upper .Model}}`)}
+	buf := new(bytes.Buffer)
+	if err := engine.Render(buf, nil, "home/upper", "a"); err != nil || buf.String() != "A" {
+		t.Errorf("rendered %s, %v, expected added func", buf.String(), err)
+	}
+}